@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"binance-tick-store/internal/backup"
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+)
+
+// runBackup snapshots the database, which may be in use by a running server.
+//
+//	server backup -out ./.data/backup -keep 7
+func runBackup(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := fs.String("out", cfg.BackupDir, "snapshot directory")
+	keep := fs.Int("keep", cfg.BackupKeep, "snapshots to keep (0 keeps all)")
+	compress := fs.Bool("gzip", cfg.BackupCompress, "gzip the snapshot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	backuper, ok := store.(database.Backuper)
+	if !ok {
+		return fmt.Errorf("the %s backend does not support online backups", cfg.Storage)
+	}
+
+	res, err := backup.NewManager(backuper, backup.Options{Dir: *dir, Keep: *keep, Compress: *compress}).Run()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s  %d bytes  %s\n", res.Path, res.Size, res.Duration)
+	for _, path := range res.Removed {
+		fmt.Printf("removed %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"binance-tick-store/internal/config"
+)
+
+// runCommand dispatches a command-line subcommand.
+func runCommand(cfg config.Config, name string, args []string) error {
+	switch name {
+	case "export":
+		return runExport(cfg, args)
+	case "import":
+		return runImport(cfg, args)
+	case "backup":
+		return runBackup(cfg, args)
+	case "convert":
+		return runConvert(cfg, args)
+	case "verify":
+		return runVerify(cfg, args)
+	case "maintain":
+		return runMaintain(cfg, args)
+	case "retire":
+		return runRetire(cfg, args)
+	case "rename":
+		return runRename(cfg, args)
+	case "config":
+		return runConfig(cfg, args)
+	default:
+		return fmt.Errorf("unknown command (available: export, import, backup, convert, verify, maintain, retire, rename, config)")
+	}
+}
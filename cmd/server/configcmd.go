@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"binance-tick-store/internal/config"
+)
+
+// runConfig shows the effective configuration and where each value came
+// from. Settings are loaded and validated before any command runs, so
+// reaching it means the configuration is valid.
+//
+//	server [-config server.yaml] [flags] config print
+func runConfig(cfg config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("usage: server config print")
+	}
+
+	if cfg.File != "" {
+		fmt.Printf("# config file: %s\n", cfg.File)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range cfg.Settings() {
+		source := string(s.Source)
+		switch s.Source {
+		case config.SourceEnv:
+			source += " " + s.Env
+		case config.SourceFlag:
+			source += " " + s.Flag
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database/ticklog"
+)
+
+// runConvert copies price tables into the tick log at TICKLOG_DIR, along
+// with each symbol's enabled setting. Ticks are read from the configured
+// backend, or from SQLite when the server already runs on the tick log.
+//
+//	server convert -symbol BTCUSDT,ETHUSDT
+func runConvert(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	symbolFlag := fs.String("symbol", "", "comma-separated symbols (default: all configured)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	srcCfg := cfg
+	if srcCfg.Storage == "ticklog" {
+		srcCfg.Storage = "sqlite"
+	}
+	src, err := openStore(srcCfg)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := openTickLog(cfg)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	symbols, err := exportSymbols(src, *symbolFlag)
+	if err != nil {
+		return err
+	}
+	settings, err := src.GetSymbolSettings()
+	if err != nil {
+		return err
+	}
+	enabled := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		enabled[s.Symbol] = s.Enabled
+	}
+
+	for _, symbol := range symbols {
+		n, err := ticklog.Convert(src, dst, symbol)
+		if err != nil {
+			return fmt.Errorf("%s: %w", symbol, err)
+		}
+		if err := dst.SetSymbolEnabled(symbol, enabled[symbol]); err != nil {
+			return err
+		}
+		fmt.Printf("%-12s %d ticks\n", symbol, n)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/export"
+)
+
+// runExport writes ticks to Parquet files.
+//
+//	server export -symbol BTCUSDT,ETHUSDT -from 2024-01-01 -to 2024-01-31
+//	server export -pending
+func runExport(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	symbols := fs.String("symbol", "", "comma-separated symbols (default: all configured)")
+	from := fs.String("from", "", "first UTC day, YYYY-MM-DD")
+	to := fs.String("to", "", "last UTC day, YYYY-MM-DD (default: same as -from)")
+	pending := fs.Bool("pending", false, "export all closed days not yet in the export log")
+	dir := fs.String("out", cfg.ExportDir, "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*pending && *from == "" {
+		return fmt.Errorf("either -from or -pending is required")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	list, err := exportSymbols(store, *symbols)
+	if err != nil {
+		return err
+	}
+
+	exporter := export.New(store, *dir)
+	for _, symbol := range list {
+		var records []database.ExportRecord
+		if *pending {
+			records, err = exporter.ExportPending(symbol)
+		} else {
+			var start, end time.Time
+			start, end, err = parseDayRange(*from, *to)
+			if err != nil {
+				return err
+			}
+			records, err = exporter.ExportRange(symbol, start, end)
+		}
+
+		for _, rec := range records {
+			fmt.Printf("%-12s %s  %10d rows  %s\n", rec.Symbol, rec.Day, rec.Rows, rec.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportSymbols(store database.Store, flagValue string) ([]string, error) {
+	if flagValue != "" {
+		var symbols []string
+		for _, s := range strings.Split(flagValue, ",") {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			if err := database.ValidateSymbol(s); err != nil {
+				return nil, err
+			}
+			symbols = append(symbols, s)
+		}
+		return symbols, nil
+	}
+
+	settings, err := store.GetSymbolSettings()
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(settings))
+	for _, s := range settings {
+		symbols = append(symbols, s.Symbol)
+	}
+	return symbols, nil
+}
+
+func parseDayRange(from, to string) (time.Time, time.Time, error) {
+	if to == "" {
+		to = from
+	}
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -from: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -to: %w", err)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-to is before -from")
+	}
+	return start, end, nil
+}
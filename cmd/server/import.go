@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/importer"
+)
+
+// runImport loads Binance aggTrades archives from local disk.
+//
+//	server import BTCUSDT-aggTrades-2024-01-15.zip BTCUSDT-aggTrades-2024-01-16.zip
+//	server import -symbol BTCUSDT trades.csv
+func runImport(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	symbolFlag := fs.String("symbol", "", "symbol for all files (default: derived from each file name)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no files given")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	im := importer.New(store)
+	for _, path := range fs.Args() {
+		symbol := strings.ToUpper(*symbolFlag)
+		if symbol == "" {
+			if symbol, err = importer.SymbolFromFilename(path); err != nil {
+				return err
+			}
+		} else if err := database.ValidateSymbol(symbol); err != nil {
+			return err
+		}
+
+		res, err := im.ImportFile(path, symbol)
+		fmt.Printf("%-12s %s  read %d, inserted %d, duplicates %d\n",
+			res.Symbol, res.File, res.Read, res.Inserted, res.Duplicates)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,61 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
-	"time"
 
+	"binance-tick-store/internal/backup"
 	"binance-tick-store/internal/config"
 	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/exchange"
+	"binance-tick-store/internal/export"
 	httpHandler "binance-tick-store/internal/http"
+	"binance-tick-store/internal/logging"
 	"binance-tick-store/internal/settings"
 	"binance-tick-store/internal/websocket"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
 
-	// Configure logger
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: cfg.LogLevel,
-	}))
+	if len(args) > 0 {
+		if err := runCommand(cfg, args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Configure logger; the levels can be changed by a reload
+	levels := logging.NewLevels(cfg.LogLevel, cfg.LogLevels)
+	logger, logFile, err := logging.New(logging.Options{
+		Format:    cfg.LogFormat,
+		File:      cfg.LogFile,
+		MaxSizeMB: cfg.LogMaxSizeMB,
+		MaxFiles:  cfg.LogMaxFiles,
+		RateLimit: cfg.LogRateLimit,
+	}, levels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
 	slog.SetDefault(logger)
 
 	slog.Info("starting binance last price store")
-	slog.Info("config loaded", "db_path", cfg.DBPath, "http_port", cfg.HTTPPort, "log_level", cfg.LogLevel.String())
+	slog.Info("config loaded", "file", cfg.File, "storage", cfg.Storage, "db_path", cfg.DBPath, "http_port", cfg.HTTPPort, "log_level", cfg.LogLevel.String())
+
+	// Stream connections, through the proxy if one is set
+	dialer, err := websocket.NewDialer(websocket.DialerOptions{
+		Proxy:            cfg.StreamProxy,
+		CAFile:           cfg.StreamCAFile,
+		HandshakeTimeout: cfg.HandshakeTimeout,
+		Compression:      cfg.StreamCompression,
+		Header:           cfg.StreamHeader(),
+	})
+	if err != nil {
+		slog.Error("failed to configure stream connections", "error", err)
+		os.Exit(1)
+	}
 
-	store, err := database.Open(cfg.DBPath)
+	store, err := openStore(cfg)
 	if err != nil {
 		slog.Error("failed to open database", "error", err)
 		os.Exit(1)
 	}
-	defer store.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	app := newApp(store)
+	// Symbol listings are cached for enabling symbols and refreshed by the
+	// monitor, which also stops capturing symbols the exchange has delisted
+	var listings *exchange.Listings
+	if cfg.CheckListings {
+		listings = exchange.NewListings(exchange.NewClient(cfg.ExchangeURL), cfg.ListingInterval)
+		exchange.NewMonitor(store, listings, cfg.ListingInterval).Start(ctx)
+	}
+
+	// Reconnect policy and connection rate limit, shared by all clients
+	backoff, limiter := websocket.NewBackoff(cfg.ReconnectMin, cfg.ReconnectMax), &websocket.ConnLimiter{}
+	applyReconnect(cfg, backoff, limiter)
+	app := newApp(store, listings, dialer, websocket.Options{
+		URL:           cfg.StreamURL,
+		StandbyURLs:   cfg.StandbyURLs,
+		Backoff:       backoff,
+		Limiter:       limiter,
+		RotateAfter:   cfg.RotateAfter,
+		RotateOverlap: cfg.RotateOverlap,
+
+		QuarantinePanics: cfg.QuarantinePanics,
+		QuarantineWindow: cfg.QuarantineWindow,
+	})
 
 	// Start settings watcher
-	watcher := settings.New(store, 60*time.Second)
+	watcher := settings.New(store, cfg.WatcherInterval)
+	app.watcher = watcher
 	changes := watcher.Start(ctx)
 
 	// Process settings changes
 	go app.handleChanges(ctx, changes)
 
+	// Periodically correct drift in the maintained tick counts
+	database.NewStatsReconciler(store, cfg.StatsInterval).Start(ctx)
+
+	// Checkpoint, vacuum and analyze or compact backends that need it
+	if m, ok := store.(database.Maintainer); ok {
+		database.NewMaintenanceScheduler(m, maintenanceOptions(cfg)).Start(ctx)
+	}
+	if c, ok := store.(database.Compactor); ok {
+		database.StartCompaction(ctx, c, cfg.MaintInterval)
+	}
+
+	// Archive closed days to Parquet
+	if cfg.ArchiveInterval > 0 {
+		archiver := export.NewArchiver(store, export.New(store, cfg.ExportDir), cfg.ArchiveInterval)
+		archiver.Start(ctx)
+		slog.Info("archiver started", "dir", cfg.ExportDir, "interval", cfg.ArchiveInterval)
+	}
+
+	// Online backups are only offered by backends that support them
+	var backups *backup.Manager
+	if backuper, ok := store.(database.Backuper); ok {
+		backups = backup.NewManager(backuper, backup.Options{
+			Dir:      cfg.BackupDir,
+			Keep:     cfg.BackupKeep,
+			Compress: cfg.BackupCompress,
+		})
+	}
+
+	reloader := &reloader{
+		args:    os.Args[1:],
+		levels:  levels,
+		watcher: watcher,
+		backoff: backoff,
+		limiter: limiter,
+		backups: backups,
+		cfg:     cfg,
+	}
+
 	// Start HTTP server with timeouts
-	handler := httpHandler.NewHandler(store, app)
+	opts := httpHandler.Options{
+		Backups:      backups,
+		Archive:      export.New(store, cfg.ExportDir),
+		Reloader:     reloader,
+		VerifyReport: cfg.VerifyReport,
+		AdminToken:   cfg.AdminToken,
+	}
+	if listings != nil {
+		opts.Listings = listings
+	}
+	handler := httpHandler.NewHandler(store, app, opts)
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.HTTPPort),
 		Handler:      handler,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
 	}
 
 	go func() {
@@ -66,36 +181,97 @@ func main() {
 		}
 	}()
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, reloading the config on SIGHUP
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigCh {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloader.Reload() // logs the outcome
+	}
 
+	// Background jobs and the settings watcher stop first, so no client
+	// starts during shutdown. Then requests and streams finish, in-flight
+	// inserts drain and the store is made durable before it closes.
 	slog.Info("shutting down...")
 	cancel()
+	shutdown(shutdownPhases(cfg, server, app, store))
+}
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer shutdownCancel()
-
-	server.Shutdown(shutdownCtx)
-	app.stopAll()
-
-	slog.Info("shutdown complete")
+// shutdownPhases lists the steps of a graceful shutdown in order. Phases a
+// backend does not need are left out.
+func shutdownPhases(cfg config.Config, server *http.Server, app *app, store database.Store) []shutdownPhase {
+	phases := []shutdownPhase{
+		{name: "http", timeout: cfg.ShutdownTimeout, run: server.Shutdown},
+		{name: "streams", timeout: cfg.ShutdownTimeout, run: app.stopAll},
+		{name: "drain", timeout: cfg.ShutdownTimeout, run: app.drain},
+	}
+	if f, ok := store.(database.Flusher); ok {
+		phases = append(phases, shutdownPhase{name: "flush", timeout: cfg.ShutdownStoreTimeout, run: func(context.Context) error {
+			return f.Flush()
+		}})
+	}
+	if m, ok := store.(database.Maintainer); ok {
+		phases = append(phases, shutdownPhase{name: "checkpoint", timeout: cfg.ShutdownStoreTimeout, run: func(context.Context) error {
+			res, err := m.Checkpoint()
+			if err == nil && res.Busy {
+				return fmt.Errorf("WAL not truncated, %d of %d frames checkpointed", res.Checkpointed, res.Frames)
+			}
+			return err
+		}})
+	}
+	// An insert still in progress after a failed drain would race with
+	// Close, so the store is left open unless it drains in time
+	return append(phases, shutdownPhase{name: "close", timeout: cfg.ShutdownStoreTimeout, run: func(ctx context.Context) error {
+		select {
+		case <-app.drained:
+		case <-ctx.Done():
+			return fmt.Errorf("inserts still in progress, store left open: %w", ctx.Err())
+		}
+		return store.Close()
+	}})
 }
 
 // app manages WebSocket clients for symbols.
 type app struct {
-	store   database.Store
-	dialer  websocket.Dialer
-	clients map[string]context.CancelFunc
-	mu      sync.RWMutex
+	store      database.Store
+	listings   *exchange.Listings // nil skips the listing check
+	watcher    settings.Watcher   // told about clients stopped without it; may be nil
+	dialer     websocket.Dialer
+	streamOpts websocket.Options
+	clients    map[string]*runningClient
+	retiring   map[*runningClient]string // cancelled clients still closing, by symbol
+	stopping   bool                      // set by stopAll; no client starts after it
+	quarantine map[string]string         // why symbols were paused after repeated panics
+	mu         sync.RWMutex
+
+	// Inserts hold writes for reading; drain takes it to wait for them and
+	// close the store to ticks, which are then counted as lost
+	writes  sync.RWMutex
+	closed  bool
+	drained chan struct{} // closed once closed is set
+	lost    atomic.Int64
+}
+
+// runningClient is the client capturing a symbol.
+type runningClient struct {
+	client      websocket.Client
+	cancel      context.CancelFunc
+	done        chan struct{} // closed when Run returns
+	connections int
 }
 
-func newApp(store database.Store) *app {
+func newApp(store database.Store, listings *exchange.Listings, dialer websocket.Dialer, streamOpts websocket.Options) *app {
 	return &app{
-		store:   store,
-		dialer:  &websocket.DefaultDialer{},
-		clients: make(map[string]context.CancelFunc),
+		store:      store,
+		listings:   listings,
+		dialer:     dialer,
+		streamOpts: streamOpts,
+		clients:    make(map[string]*runningClient),
+		retiring:   make(map[*runningClient]string),
+		quarantine: make(map[string]string),
+		drained:    make(chan struct{}),
 	}
 }
 
@@ -121,7 +297,7 @@ func (a *app) handleChanges(ctx context.Context, changes <-chan settings.SymbolC
 				return
 			}
 			if change.Enabled {
-				a.startClient(ctx, change.Symbol)
+				a.startClient(ctx, change.Symbol, change.Connections)
 			} else {
 				a.stopClient(change.Symbol)
 			}
@@ -129,13 +305,34 @@ func (a *app) handleChanges(ctx context.Context, changes <-chan settings.SymbolC
 	}
 }
 
-func (a *app) startClient(ctx context.Context, symbol string) {
+// startClient starts capturing symbol with the given number of redundant
+// connections, restarting its client if it runs with another number.
+func (a *app) startClient(ctx context.Context, symbol string, connections int) {
+	// Symbols enabled directly in the database (make enable) are checked
+	// here; a typo would otherwise reconnect to an empty stream forever
+	if a.listings != nil {
+		if err := a.listings.Check(ctx, symbol); err != nil {
+			slog.Error("symbol not started, pausing it", "symbol", symbol, "error", err)
+			if err := a.store.SetSymbolEnabled(symbol, false); err != nil {
+				slog.Error("failed to pause symbol", "symbol", symbol, "error", err)
+			}
+			return
+		}
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	if _, exists := a.clients[symbol]; exists {
+	if a.stopping {
 		return
 	}
+	if running, exists := a.clients[symbol]; exists {
+		if running.connections == connections {
+			return
+		}
+		a.retire(symbol, running)
+		slog.Info("client stopped to change connections", "symbol", symbol, "from", running.connections, "to", connections)
+	}
 
 	if err := a.store.EnsurePriceTable(symbol); err != nil {
 		slog.Error("failed to create price table", "symbol", symbol, "error", err)
@@ -143,38 +340,171 @@ func (a *app) startClient(ctx context.Context, symbol string) {
 	}
 
 	clientCtx, cancel := context.WithCancel(ctx)
-	a.clients[symbol] = cancel
 
-	handler := func(tick websocket.Tick) {
-		if err := a.store.InsertPrice(tick.Symbol, tick.Timestamp, tick.Price); err != nil {
-			slog.Error("failed to insert price", "symbol", tick.Symbol, "error", err)
+	opts := a.streamOpts
+	opts.Connections = connections
+	client := websocket.NewClient(symbol, a.dialer, a.insert, opts)
+	running := &runningClient{client: client, cancel: cancel, done: make(chan struct{}), connections: connections}
+	a.clients[symbol] = running
+	delete(a.quarantine, symbol)
+	go func() {
+		defer close(running.done)
+		defer a.retired(running)
+		if err := client.Run(clientCtx); err != nil {
+			a.quarantined(symbol, running, err)
 		}
-	}
+	}()
+
+	slog.Info("client started", "symbol", symbol, "connections", connections)
+}
 
-	client := websocket.NewClient(symbol, a.dialer, handler)
-	go client.Run(clientCtx)
+// quarantined pauses symbol after its client stopped on repeated panics, so
+// that it stays stopped until it is activated again.
+func (a *app) quarantined(symbol string, running *runningClient, err error) {
+	a.mu.Lock()
+	if a.clients[symbol] == running {
+		delete(a.clients, symbol)
+	}
+	a.quarantine[symbol] = err.Error()
+	a.mu.Unlock()
+	running.cancel()
 
-	slog.Info("client started", "symbol", symbol)
+	slog.Error("symbol quarantined, pausing it", "symbol", symbol, "error", err)
+	if err := a.store.SetSymbolEnabled(symbol, false); err != nil {
+		slog.Error("failed to pause symbol", "symbol", symbol, "error", err)
+	}
+	a.forget(symbol)
 }
 
 func (a *app) stopClient(symbol string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	if cancel, exists := a.clients[symbol]; exists {
-		cancel()
-		delete(a.clients, symbol)
+	if running, exists := a.clients[symbol]; exists {
+		a.retire(symbol, running)
 		slog.Info("client stopped", "symbol", symbol)
 	}
 }
 
-func (a *app) stopAll() {
+// retire cancels a running client and keeps it for stopAll to wait on until
+// it has closed. a.mu must be held.
+func (a *app) retire(symbol string, running *runningClient) {
+	running.cancel()
+	delete(a.clients, symbol)
+	a.retiring[running] = symbol
+}
+
+// retired forgets a client once its Run has returned.
+func (a *app) retired(running *runningClient) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	delete(a.retiring, running)
+}
 
-	for symbol, cancel := range a.clients {
-		cancel()
-		slog.Info("client stopped", "symbol", symbol)
+// StopSymbol stops the client of a symbol that is being paused or retired,
+// without waiting for the settings watcher to notice.
+func (a *app) StopSymbol(symbol string) {
+	a.stopClient(symbol)
+	a.forget(symbol)
+}
+
+// forget has the watcher report symbol's settings at its next check, so an
+// activation soon after a stop it did not see restarts the client.
+func (a *app) forget(symbol string) {
+	if a.watcher != nil {
+		a.watcher.Forget(symbol)
+	}
+}
+
+// SymbolConnections reports on the connections of symbol's client, or nil
+// if it is not running.
+func (a *app) SymbolConnections(symbol string) []websocket.ConnectionStats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if running, ok := a.clients[symbol]; ok {
+		return running.client.Connections()
+	}
+	return nil
+}
+
+// insert stores a tick received by a client.
+func (a *app) insert(tick websocket.Tick) {
+	a.writes.RLock()
+	defer a.writes.RUnlock()
+
+	if a.closed {
+		a.lost.Add(1)
+		return
+	}
+	if err := a.store.InsertPrice(tick.Symbol, tick.AggID, tick.Timestamp, tick.Price); err != nil {
+		slog.Error("failed to insert price", "symbol", tick.Symbol, "error", err)
+	}
+}
+
+// SymbolQuarantine returns why symbol was quarantined, or "" if it was not
+// or has been activated since.
+func (a *app) SymbolQuarantine(symbol string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.quarantine[symbol]
+}
+
+// stopAll stops every client and waits until they have closed their
+// connections and stored their last ticks, or ctx ends. No client starts
+// afterwards.
+func (a *app) stopAll(ctx context.Context) error {
+	a.mu.Lock()
+	clients := make(map[*runningClient]string, len(a.clients)+len(a.retiring))
+	for symbol, running := range a.clients {
+		clients[running] = symbol
+	}
+	for running, symbol := range a.retiring {
+		clients[running] = symbol
+	}
+	a.clients = make(map[string]*runningClient)
+	a.stopping = true
+	a.mu.Unlock()
+
+	for running := range clients {
+		running.cancel()
+	}
+	var pending []string
+	for running, symbol := range clients {
+		select {
+		case <-running.done:
+			slog.Info("client stopped", "symbol", symbol)
+		case <-ctx.Done():
+			pending = append(pending, symbol)
+		}
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return fmt.Errorf("clients did not stop: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// drain waits for inserts in progress and stops further ones, so the store
+// can be closed. Ticks that clients still deliver are dropped and reported.
+// If ctx ends first, drained is closed once the inserts finish.
+func (a *app) drain(ctx context.Context) error {
+	go func() {
+		a.writes.Lock()
+		a.closed = true
+		close(a.drained)
+		a.writes.Unlock()
+	}()
+
+	select {
+	case <-a.drained:
+	case <-ctx.Done():
+		return fmt.Errorf("inserts still in progress: %w", ctx.Err())
+	}
+
+	// Late ticks are only possible from clients that did not stop
+	if lost := a.lost.Load(); lost > 0 {
+		return fmt.Errorf("%d ticks arrived after the streams stopped and were not stored", lost)
 	}
-	a.clients = make(map[string]context.CancelFunc)
+	return nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/database"
+	httpHandler "binance-tick-store/internal/http"
+	"binance-tick-store/internal/settings"
+	"binance-tick-store/internal/websocket"
+)
+
+// idleDialer connects to a stream that sends nothing until ctx ends.
+type idleDialer struct{}
+
+func (idleDialer) Dial(ctx context.Context, url string) (websocket.Conn, error) {
+	return idleConn{ctx}, nil
+}
+
+type idleConn struct{ ctx context.Context }
+
+func (c idleConn) ReadMessage() (int, []byte, error) {
+	<-c.ctx.Done()
+	return 0, nil, errors.New("connection closed")
+}
+
+func (idleConn) Close() error { return nil }
+
+func TestApp_RestartsSymbolActivatedWithinWatcherInterval(t *testing.T) {
+	store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetSymbolEnabled("BTCUSDT", true); err != nil {
+		t.Fatalf("SetSymbolEnabled failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app := newApp(store, nil, idleDialer{}, websocket.Options{URL: "ws://stream"})
+	defer app.stopAll(context.Background())
+	watcher := settings.New(store, time.Hour)
+	app.watcher = watcher
+	go app.handleChanges(ctx, watcher.Start(ctx))
+	waitActive(t, app, true)
+
+	// Pause and activate through the API before the watcher checks again
+	handler := httpHandler.NewHandler(store, app, httpHandler.Options{})
+	for _, state := range []string{"paused", "active"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/symbols?symbol=BTCUSDT&state="+state, nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", state, rec.Code, rec.Body)
+		}
+	}
+	waitActive(t, app, false)
+
+	watcher.SetInterval(10 * time.Millisecond) // the next check
+	waitActive(t, app, true)
+}
+
+// waitActive waits until BTCUSDT's client is running or not.
+func waitActive(t *testing.T, app *app, active bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for app.GetActiveSymbols()["BTCUSDT"] != active {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected BTCUSDT active=%v", active)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
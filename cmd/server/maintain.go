@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+)
+
+const mb = 1 << 20
+
+// maintenanceOptions maps the configuration onto the scheduler options.
+func maintenanceOptions(cfg config.Config) database.MaintenanceOptions {
+	return database.MaintenanceOptions{
+		CheckInterval:      cfg.MaintInterval,
+		CheckpointInterval: cfg.CheckpointInterval,
+		WALLimit:           int64(cfg.CheckpointMB) * mb,
+		VacuumInterval:     cfg.VacuumInterval,
+		FreeLimit:          int64(cfg.VacuumFreeMB) * mb,
+		VacuumStep:         int64(cfg.VacuumStepMB) * mb,
+		AnalyzeInterval:    cfg.AnalyzeInterval,
+	}
+}
+
+// runMaintain checkpoints the WAL, releases free pages and refreshes the
+// planner statistics now, or sorts out-of-order appends back into flat
+// files. -full-vacuum rebuilds the file instead, which is needed once to
+// enable incremental vacuum on older databases and blocks writers for its
+// duration.
+//
+//	server maintain [-full-vacuum]
+func runMaintain(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("maintain", flag.ContinueOnError)
+	full := fs.Bool("full-vacuum", false, "rebuild the database and enable incremental vacuum")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if c, ok := store.(database.Compactor); ok && !*full {
+		n, err := c.Compact()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("compact: %d files rewritten\n", n)
+		if _, ok := store.(database.Maintainer); !ok {
+			return nil
+		}
+	}
+
+	m, ok := store.(database.Maintainer)
+	if !ok {
+		return fmt.Errorf("the %s backend needs no maintenance", cfg.Storage)
+	}
+
+	before, err := m.MaintenanceInfo()
+	if err != nil {
+		return err
+	}
+
+	if *full {
+		fv, ok := store.(interface{ FullVacuum() error })
+		if !ok {
+			return fmt.Errorf("the %s backend does not support full vacuum", cfg.Storage)
+		}
+		if err := fv.FullVacuum(); err != nil {
+			return err
+		}
+		fmt.Printf("vacuum: rebuilt, %d free bytes released\n", before.FreeBytes)
+	} else if before.AutoVacuum == "incremental" {
+		released, err := m.IncrementalVacuum(0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vacuum: %d bytes released\n", released)
+	} else {
+		fmt.Printf("vacuum: skipped, auto_vacuum is %s (run with -full-vacuum once)\n", before.AutoVacuum)
+	}
+
+	res, err := m.Checkpoint()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checkpoint: %d frames, wal was %d bytes", res.Frames, before.WALSize)
+	if res.Busy {
+		fmt.Print(" (busy, not truncated)")
+	}
+	fmt.Println()
+
+	if err := m.Analyze(); err != nil {
+		return err
+	}
+	fmt.Println("analyze: done")
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"binance-tick-store/internal/backup"
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/logging"
+	"binance-tick-store/internal/settings"
+	"binance-tick-store/internal/websocket"
+)
+
+// liveSettings can change while the server runs; Reload applies each of
+// them. Other changes are logged and take effect at the next restart.
+var liveSettings = map[string]bool{
+	"log_level":                  true,
+	"log_levels":                 true,
+	"watcher_interval":           true,
+	"reconnect_backoff_min":      true,
+	"reconnect_backoff_max":      true,
+	"reconnect_strategy":         true,
+	"reconnect_breaker_failures": true,
+	"reconnect_breaker_pause":    true,
+	"stream_connect_limit":       true,
+	"stream_connect_window":      true,
+	"backup_keep":                true,
+}
+
+// reloader re-reads the configuration on SIGHUP or the admin endpoint and
+// applies what it can without restarting. Connections are kept; new
+// reconnect settings apply from each client's next reconnect.
+type reloader struct {
+	args    []string // command-line flags, which still override the file
+	levels  *logging.Levels
+	watcher settings.Watcher
+	backoff *websocket.Backoff
+	limiter *websocket.ConnLimiter
+	backups *backup.Manager // nil if the backend has no backups
+
+	mu  sync.Mutex
+	cfg config.Config
+}
+
+// Reload loads the configuration again and applies the live settings. An
+// invalid configuration is rejected as a whole and the running one is
+// kept.
+func (r *reloader) Reload() ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, _, err := config.Load(r.args)
+	if err != nil {
+		slog.Error("config reload rejected", "error", err)
+		return nil, fmt.Errorf("config rejected: %w", err)
+	}
+
+	changes := cfg.Diff(r.cfg)
+	for i, c := range changes {
+		changes[i].Applied = liveSettings[c.Key]
+		if changes[i].Applied {
+			slog.Info("setting changed", "key", c.Key, "from", c.From, "to", c.To)
+		} else {
+			slog.Warn("setting changed, restart to apply", "key", c.Key, "from", c.From, "to", c.To)
+		}
+	}
+
+	r.levels.Set(cfg.LogLevel, cfg.LogLevels)
+	if cfg.WatcherInterval != r.cfg.WatcherInterval {
+		r.watcher.SetInterval(cfg.WatcherInterval)
+	}
+	applyReconnect(cfg, r.backoff, r.limiter)
+	if r.backups != nil {
+		r.backups.SetKeep(cfg.BackupKeep)
+	}
+
+	// Settings that need a restart keep their running values, so the next
+	// reload reports them again until the server restarts
+	running := r.cfg
+	running.LogLevel, running.LogLevels = cfg.LogLevel, cfg.LogLevels
+	running.WatcherInterval = cfg.WatcherInterval
+	running.ReconnectMin, running.ReconnectMax = cfg.ReconnectMin, cfg.ReconnectMax
+	running.ReconnectStrategy = cfg.ReconnectStrategy
+	running.BreakerFailures, running.BreakerPause = cfg.BreakerFailures, cfg.BreakerPause
+	running.ConnectLimit, running.ConnectWindow = cfg.ConnectLimit, cfg.ConnectWindow
+	running.BackupKeep = cfg.BackupKeep
+	r.cfg = running
+
+	slog.Info("config reloaded", "file", cfg.File, "changes", len(changes))
+	return changes, nil
+}
+
+// applyReconnect sets the clients' reconnect policy and connection limit
+// from cfg.
+func applyReconnect(cfg config.Config, backoff *websocket.Backoff, limiter *websocket.ConnLimiter) {
+	backoff.Set(cfg.ReconnectMin, cfg.ReconnectMax)
+	if s, err := websocket.StrategyByName(cfg.ReconnectStrategy); err == nil { // validated by config
+		backoff.SetStrategy(s)
+	}
+	backoff.SetBreaker(cfg.BreakerFailures, cfg.BreakerPause)
+	limiter.Set(cfg.ConnectLimit, cfg.ConnectWindow)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/exchange"
+)
+
+// runRename records that the exchange relisted a symbol under a new name:
+// the old symbol is marked delisted, its history is exported under the new
+// name with history=true, and the new symbol is enabled.
+//
+//	server rename -from SHIBUSDT -to 1000SHIBUSDT
+func runRename(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	from := fs.String("from", "", "delisted symbol")
+	to := fs.String("to", "", "symbol it continues as")
+	enable := fs.Bool("enable", true, "start capturing the new symbol")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return fmt.Errorf("-from and -to are required")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *enable && cfg.CheckListings {
+		listings := exchange.NewListings(exchange.NewClient(cfg.ExchangeURL), 0)
+		if err := listings.Check(context.Background(), *to); err != nil {
+			return err
+		}
+	}
+
+	delister, ok := store.(database.Delister)
+	if !ok {
+		return fmt.Errorf("the %s backend cannot mark symbols delisted", cfg.Storage)
+	}
+	if err := delister.MarkDelisted(*from, *to); err != nil {
+		return err
+	}
+	if *enable {
+		if err := store.SetSymbolEnabled(*to, true); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s delisted, history continues as %s\n", *from, *to)
+	return nil
+}
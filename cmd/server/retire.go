@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/export"
+	"binance-tick-store/internal/settings"
+)
+
+// runRetire stops capturing symbols and drops their ticks, archiving them to
+// a Parquet file under the export directory first unless -archive=false. A
+// running server stops the clients at its next settings check; use the API
+// to stop them immediately.
+//
+//	server retire -symbol LUNAUSDT
+//	server retire -symbol LUNAUSDT,USTUSDT -archive=false
+func runRetire(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("retire", flag.ContinueOnError)
+	symbolFlag := fs.String("symbol", "", "comma-separated symbols to retire")
+	archive := fs.Bool("archive", true, "archive the ticks before dropping them")
+	dir := fs.String("out", cfg.ExportDir, "export directory; archives go to <out>/retired")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *symbolFlag == "" {
+		return fmt.Errorf("-symbol is required")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var exporter *export.Exporter
+	if *archive {
+		exporter = export.New(store, *dir)
+	}
+
+	for _, symbol := range strings.Split(*symbolFlag, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		res, err := settings.Retire(store, exporter, symbol, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", symbol, err)
+		}
+		switch {
+		case res.ArchivePath != "":
+			fmt.Printf("%-12s retired, %d ticks archived to %s\n", res.Symbol, res.Archived, res.ArchivePath)
+		case *archive:
+			fmt.Printf("%-12s retired, no ticks to archive\n", res.Symbol)
+		default:
+			fmt.Printf("%-12s retired, ticks dropped\n", res.Symbol)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// shutdownPhase is one step of the shutdown sequence.
+type shutdownPhase struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// shutdownResult is the outcome of a phase.
+type shutdownResult struct {
+	phase string
+	took  time.Duration
+	err   error
+}
+
+// shutdown runs phases in order, each limited to its timeout, and logs a
+// report. A phase that fails or times out is reported and the next one runs
+// anyway: the store must still be flushed and closed if a stream hangs.
+func shutdown(phases []shutdownPhase) {
+	start := time.Now()
+	results := make([]shutdownResult, 0, len(phases))
+	for _, p := range phases {
+		res := runPhase(p)
+		if res.err != nil {
+			slog.Warn("shutdown phase failed", "phase", res.phase, "took", res.took, "error", res.err)
+		} else {
+			slog.Info("shutdown phase done", "phase", res.phase, "took", res.took)
+		}
+		results = append(results, res)
+	}
+
+	var failed []string
+	for _, res := range results {
+		if res.err != nil {
+			failed = append(failed, res.phase)
+		}
+	}
+	if len(failed) > 0 {
+		slog.Warn("shutdown complete with failures", "took", time.Since(start), "failed", failed)
+	} else {
+		slog.Info("shutdown complete", "took", time.Since(start))
+	}
+}
+
+// runPhase runs p until it returns or its timeout passes. A phase that
+// ignores its context is left running.
+func runPhase(p shutdownPhase) shutdownResult {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- p.run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", p.timeout)
+	}
+	return shutdownResult{phase: p.name, took: time.Since(start), err: err}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/websocket"
+)
+
+// blockingStore holds InsertPrice until release is closed and counts
+// Close calls.
+type blockingStore struct {
+	database.Store
+	inserting chan struct{}
+	release   chan struct{}
+	closes    atomic.Int32
+}
+
+func (s *blockingStore) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
+	close(s.inserting)
+	<-s.release
+	return nil
+}
+
+func (s *blockingStore) Close() error {
+	s.closes.Add(1)
+	return nil
+}
+
+func TestShutdown_StoreLeftOpenWhileInserting(t *testing.T) {
+	store := &blockingStore{inserting: make(chan struct{}), release: make(chan struct{})}
+	app := newApp(store, nil, nil, websocket.Options{})
+
+	done := make(chan struct{})
+	go func() {
+		app.insert(websocket.Tick{Symbol: "BTCUSDT", AggID: 1})
+		close(done)
+	}()
+	<-store.inserting
+
+	cfg := config.Default()
+	cfg.ShutdownTimeout, cfg.ShutdownStoreTimeout = 20*time.Millisecond, 20*time.Millisecond
+	shutdown(shutdownPhases(cfg, &http.Server{}, app, store))
+
+	close(store.release)
+	<-done
+	if n := store.closes.Load(); n != 0 {
+		t.Fatalf("expected the store to stay open under an insert, closed %d times", n)
+	}
+
+	app.insert(websocket.Tick{Symbol: "BTCUSDT", AggID: 2}) // after the drain
+	if n := app.lost.Load(); n != 1 {
+		t.Errorf("expected a tick after the drain to be counted as lost, got %d", n)
+	}
+}
+
+func TestShutdown_ClosesDrainedStore(t *testing.T) {
+	store := &blockingStore{}
+	cfg := config.Default()
+	shutdown(shutdownPhases(cfg, &http.Server{}, newApp(store, nil, nil, websocket.Options{}), store))
+	if n := store.closes.Load(); n != 1 {
+		t.Errorf("expected the store to be closed once, got %d", n)
+	}
+}
+
+func TestStopAll_WaitsForReplacedClients(t *testing.T) {
+	app := newApp(nil, nil, nil, websocket.Options{})
+	old := &runningClient{cancel: func() {}, done: make(chan struct{}), connections: 1}
+	app.clients["BTCUSDT"] = old
+	app.retire("BTCUSDT", old) // as a connection change does
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := app.stopAll(ctx); err == nil || !strings.Contains(err.Error(), "BTCUSDT") {
+		t.Fatalf("expected stopAll to wait for the replaced client, got %v", err)
+	}
+
+	close(old.done)
+	app.retired(old)
+	if err := app.stopAll(context.Background()); err != nil {
+		t.Errorf("expected no clients left once the replaced one closed, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/database/flatfile"
+	"binance-tick-store/internal/database/postgres"
+	"binance-tick-store/internal/database/ticklog"
+)
+
+// openStore opens the storage backend selected by cfg.Storage.
+func openStore(cfg config.Config) (database.Store, error) {
+	switch cfg.Storage {
+	case "sqlite":
+		return database.Open(cfg.DBPath)
+	case "postgres":
+		if cfg.PostgresDSN == "" {
+			return nil, fmt.Errorf("POSTGRES_DSN is required for the postgres backend")
+		}
+		return postgres.Open(cfg.PostgresDSN, cfg.Timescale)
+	case "flatfile":
+		return flatfile.Open(cfg.FlatFileDir)
+	case "ticklog":
+		return openTickLog(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (available: sqlite, postgres, flatfile, ticklog)", cfg.Storage)
+	}
+}
+
+// openTickLog opens the tick log in cfg.TickLogDir, keeping symbol settings
+// and the export log in a flat file store beside it.
+func openTickLog(cfg config.Config) (*ticklog.Log, error) {
+	meta, err := flatfile.Open(filepath.Join(cfg.TickLogDir, "meta"))
+	if err != nil {
+		return nil, err
+	}
+	log, err := ticklog.Open(cfg.TickLogDir, meta)
+	if err != nil {
+		meta.Close()
+		return nil, err
+	}
+	return log, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"binance-tick-store/internal/config"
+	"binance-tick-store/internal/verify"
+)
+
+// runVerify checks storage integrity and the plausibility of stored ticks,
+// saving the report where /status picks it up. It fails if problems were
+// found.
+//
+//	server verify
+//	server verify -symbol BTCUSDT -json
+func runVerify(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	symbolFlag := fs.String("symbol", "", "comma-separated symbols (default: all configured)")
+	out := fs.String("out", cfg.VerifyReport, "report file")
+	outlier := fs.Float64("outlier", verify.DefaultOutlierRatio, "relative jump from both neighbours that flags a price")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	symbols, err := exportSymbols(store, *symbolFlag)
+	if err != nil {
+		return err
+	}
+
+	report, err := verify.New(store, verify.Options{OutlierRatio: *outlier}).Run(symbols)
+	if err != nil {
+		return err
+	}
+	if err := verify.Save(*out, report); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, line := range report.Integrity {
+			fmt.Printf("integrity: %s\n", line)
+		}
+		for _, sr := range report.Symbols {
+			fmt.Printf("%-12s %d ticks, %d out of order, %d non-positive, %d outliers, %d off tick, %d bad timestamps",
+				sr.Symbol, sr.Ticks, sr.OutOfOrder, sr.NonPositive, sr.Outliers, sr.OffTick, sr.BadTimestamps)
+			if sr.StatsDrift {
+				fmt.Printf(", stats count %d", sr.StatsCount)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("report written to %s\n", *out)
+	}
+
+	if !report.OK {
+		return errors.New("problems found")
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	filePrefix = "ticks-"
+	timeLayout = "20060102T150405.000Z" // fixed width, so names sort by time
+)
+
+// Options configures where snapshots go and how many are kept.
+type Options struct {
+	Dir      string
+	Keep     int // 0 keeps every snapshot
+	Compress bool
+}
+
+// Result describes a finished snapshot.
+type Result struct {
+	Path     string
+	Size     int64
+	Duration time.Duration
+	Removed  []string // snapshots deleted by rotation
+}
+
+// Manager takes verified snapshots of a live database and rotates old ones.
+type Manager struct {
+	store database.Backuper
+	opts  Options
+	mu    sync.Mutex // one snapshot at a time
+	now   func() time.Time
+}
+
+// NewManager creates a backup manager.
+func NewManager(store database.Backuper, opts Options) *Manager {
+	return &Manager{
+		store: store,
+		opts:  opts,
+		now:   time.Now,
+	}
+}
+
+// SetKeep changes how many snapshots are kept from the next rotation on.
+func (m *Manager) SetKeep(keep int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opts.Keep = keep
+}
+
+// Run writes a snapshot, verifies it with a read-only quick check,
+// optionally gzips it and removes snapshots beyond Keep.
+func (m *Manager) Run() (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := m.now()
+	if err := os.MkdirAll(m.opts.Dir, 0755); err != nil {
+		return Result{}, fmt.Errorf("create backup dir: %w", err)
+	}
+
+	name := filePrefix + start.UTC().Format(timeLayout) + ".db"
+	final := filepath.Join(m.opts.Dir, name)
+	if m.opts.Compress {
+		final += ".gz"
+	}
+	// Another process, such as `server backup`, may have taken one just now
+	if _, err := os.Stat(final); err == nil {
+		return Result{}, fmt.Errorf("snapshot %s already exists", final)
+	}
+	snapshot := filepath.Join(m.opts.Dir, name+".tmp")
+	defer os.Remove(snapshot)
+
+	if err := m.store.Backup(snapshot); err != nil {
+		return Result{}, err
+	}
+	if err := verify(snapshot); err != nil {
+		return Result{}, fmt.Errorf("verify snapshot: %w", err)
+	}
+
+	if m.opts.Compress {
+		if err := gzipFile(snapshot, final); err != nil {
+			return Result{}, err
+		}
+	} else if err := os.Rename(snapshot, final); err != nil {
+		return Result{}, fmt.Errorf("rename snapshot: %w", err)
+	}
+
+	info, err := os.Stat(final)
+	if err != nil {
+		return Result{}, err
+	}
+
+	removed, err := m.rotate()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Path:     final,
+		Size:     info.Size(),
+		Duration: m.now().Sub(start),
+		Removed:  removed,
+	}, nil
+}
+
+// verify reopens a snapshot through database.Open, as a restore would, so
+// its schema migrates, then runs database.QuickCheck, which fails on a
+// truncated or corrupt file.
+func verify(path string) error {
+	store, err := database.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+	// Open left the snapshot in WAL mode, whose side files a read-only
+	// connection creates but cannot remove; Close checkpointed the WAL
+	defer os.Remove(path + "-wal")
+	defer os.Remove(path + "-shm")
+	return database.QuickCheck(path)
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		return fmt.Errorf("compress snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress snapshot: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// rotate deletes the oldest snapshots so that at most Keep remain.
+func (m *Manager) rotate() ([]string, error) {
+	if m.opts.Keep <= 0 {
+		return nil, nil
+	}
+
+	snapshots, err := List(m.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) <= m.opts.Keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, path := range snapshots[:len(snapshots)-m.opts.Keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("remove old snapshot: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// List returns snapshot files in dir, oldest first.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, filePrefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ".db") || strings.HasSuffix(name, ".db.gz") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+func openStore(t *testing.T, dir string) database.Store {
+	t.Helper()
+	store, err := database.Open(filepath.Join(dir, "ticks.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := store.InsertPrice("BTCUSDT", i, i*1000, 42000); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+	return store
+}
+
+func TestManager_SnapshotIsReadable(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+
+	m := NewManager(store.(database.Backuper), Options{Dir: filepath.Join(dir, "backup")})
+	res, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	snapshot, err := database.Open(res.Path)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	if err := snapshot.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if count, _ := snapshot.GetCount("BTCUSDT"); count != 3 {
+		t.Errorf("expected 3 ticks in snapshot, got %d", count)
+	}
+}
+
+func TestManager_CompressAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	backupDir := filepath.Join(dir, "backup")
+
+	m := NewManager(store.(database.Backuper), Options{Dir: backupDir, Keep: 2, Compress: true})
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	var last Result
+	for i := 0; i < 3; i++ {
+		res, err := m.Run()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		last = res
+		now = now.Add(time.Hour)
+	}
+
+	snapshots, err := List(backupDir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots after rotation, got %v", snapshots)
+	}
+	if len(last.Removed) != 1 || filepath.Base(last.Removed[0]) != "ticks-20240115T000000.000Z.db.gz" {
+		t.Errorf("expected oldest snapshot removed, got %v", last.Removed)
+	}
+
+	// The compressed snapshot decompresses to a valid database
+	f, err := os.Open(last.Path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	restored := filepath.Join(dir, "restored.db")
+	out, _ := os.Create(restored)
+	io.Copy(out, zr)
+	out.Close()
+
+	snapshot, err := database.Open(restored)
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	defer snapshot.Close()
+	if count, _ := snapshot.GetCount("BTCUSDT"); count != 3 {
+		t.Errorf("expected 3 ticks in restored snapshot, got %d", count)
+	}
+
+	// A snapshot in the same millisecond as the last one must not replace it
+	now = now.Add(-time.Hour)
+	if _, err := m.Run(); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected a clash with the last snapshot to fail, got %v", err)
+	}
+	now = now.Add(time.Millisecond)
+	if _, err := m.Run(); err != nil {
+		t.Errorf("expected a snapshot a millisecond later to succeed, got %v", err)
+	}
+}
+
+func TestVerify_ReopensSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+
+	// ? and # would end the file name in an unescaped SQLite URI
+	snapshot := filepath.Join(dir, "snap?shot#1", "snapshot.db")
+	os.MkdirAll(filepath.Dir(snapshot), 0755)
+	if err := store.(database.Backuper).Backup(snapshot); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := verify(snapshot); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(snapshot + suffix); !os.IsNotExist(err) {
+			t.Errorf("expected verify to leave no %s file", suffix)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "snap") && e.Name() != "snap?shot#1" {
+			t.Errorf("expected nothing opened outside the snapshot dir, found %s", e.Name())
+		}
+	}
+}
+
+func TestVerify_RejectsCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := store.(database.Backuper).Backup(snapshot); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	raw, _ := os.ReadFile(snapshot)
+	os.WriteFile(snapshot, raw[:len(raw)/2], 0644)
+	if err := verify(snapshot); err == nil {
+		t.Error("expected a truncated snapshot to fail verification")
+	}
+}
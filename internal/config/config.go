@@ -1,54 +1,387 @@
+// Package config loads the server configuration. Each setting has a
+// default and can be set, in increasing order of precedence, in a YAML or
+// TOML config file, by an environment variable or by a command-line flag.
+// The environment variable names the setting: HTTP_PORT is http_port in the
+// file and -http-port on the command line.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	DBPath   string
-	HTTPPort int
-	LogLevel slog.Level
+	File string // config file the settings were read from, if any
+
+	Storage         string // sqlite, postgres, flatfile or ticklog
+	DBPath          string
+	PostgresDSN     string
+	Timescale       bool // create price tables as TimescaleDB hypertables
+	FlatFileDir     string
+	TickLogDir      string
+	HTTPPort        int
+	LogLevel        slog.Level
+	LogLevels       map[string]slog.Level // per subsystem, overriding LogLevel
+	LogFormat       string                // text or json
+	LogFile         string                // empty logs to stdout
+	LogMaxSizeMB    int                   // rotate the log file past this size, 0 never
+	LogMaxFiles     int                   // rotated log files kept
+	LogRateLimit    int                   // repeated warnings and errors logged per minute, 0 unlimited
+	ExportDir       string
+	ArchiveInterval time.Duration // 0 disables scheduled archiving
+	StatsInterval   time.Duration // how often symbol_stats is reconciled
+	BackupDir       string
+	BackupKeep      int // snapshots to keep, 0 keeps all
+	BackupCompress  bool
+	VerifyReport    string        // where the last verification report is kept
+	ExchangeURL     string        // Binance futures REST API, for symbol listings
+	ListingInterval time.Duration // how often listings are checked for delistings, 0 disables
+	CheckListings   bool          // reject symbols the exchange does not list
+
+	WatcherInterval   time.Duration // how often symbol_settings is polled
+	StreamURL         string        // WebSocket stream base URL
+	StandbyURLs       []string      // further base URLs for symbols with redundant connections
+	StreamProxy       string        // http:// or socks5:// proxy for stream connections
+	StreamCAFile      string        // PEM certificates trusted in addition to the system's
+	HandshakeTimeout  time.Duration // stream connection handshake timeout
+	StreamCompression bool          // negotiate permessage-deflate
+	StreamHeaders     []string      // Name=value headers sent with each handshake
+	ReconnectMin      time.Duration // first reconnect delay, doubled per failure
+	ReconnectMax      time.Duration
+	ReconnectStrategy string        // equal-jitter, full-jitter, decorrelated or exponential
+	BreakerFailures   int           // failures in a row that pause a client, 0 disables
+	BreakerPause      time.Duration // how long the breaker pauses a client
+	ConnectLimit      int           // connections opened per ConnectWindow by all clients, 0 unlimited
+	ConnectWindow     time.Duration
+	RotateAfter       time.Duration // replace connections this often, 0 never
+	RotateOverlap     time.Duration // how long old and new connections overlap
+	QuarantinePanics  int           // panics within QuarantineWindow that pause a symbol, 0 never
+	QuarantineWindow  time.Duration
+	HTTPReadTimeout   time.Duration
+	HTTPWriteTimeout  time.Duration
+	HTTPIdleTimeout   time.Duration
+	AdminToken        string // required by state-changing endpoints, empty allows loopback clients only
+
+	// Shutdown runs in phases, each limited to one of these
+	ShutdownTimeout      time.Duration // HTTP, stream and drain phases
+	ShutdownStoreTimeout time.Duration // flush, checkpoint and close phases
+
+	// SQLite maintenance; a zero interval or size disables that trigger
+	MaintInterval      time.Duration // how often the thresholds are checked, 0 disables maintenance
+	CheckpointInterval time.Duration
+	CheckpointMB       int // checkpoint once the WAL grows past this size
+	VacuumInterval     time.Duration
+	VacuumFreeMB       int // vacuum once free pages exceed this size
+	VacuumStepMB       int // released per vacuum, 0 for all free pages
+	AnalyzeInterval    time.Duration
+
+	sources map[string]Source // by key; missing keys are defaults
 }
 
-func Load() Config {
+// Source is where a setting's value came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Setting is one effective setting, as shown by `config print`.
+type Setting struct {
+	Key    string
+	Env    string
+	Flag   string
+	Value  string // secrets are redacted
+	Source Source
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
 	return Config{
-		DBPath:   getEnv("DB_PATH", "./.data/ticks.db"),
-		HTTPPort: getEnvInt("HTTP_PORT", 8080),
-		LogLevel: getLogLevel("LOG_LEVEL", slog.LevelInfo),
+		Storage:         "sqlite",
+		DBPath:          "./.data/ticks.db",
+		FlatFileDir:     "./.data/flatfile",
+		TickLogDir:      "./.data/ticklog",
+		HTTPPort:        8080,
+		LogLevel:        slog.LevelInfo,
+		LogFormat:       "text",
+		LogMaxSizeMB:    100,
+		LogMaxFiles:     5,
+		LogRateLimit:    10,
+		ExportDir:       "./.data/export",
+		StatsInterval:   6 * time.Hour,
+		BackupDir:       "./.data/backup",
+		BackupKeep:      7,
+		BackupCompress:  true,
+		VerifyReport:    "./.data/verify.json",
+		ExchangeURL:     "https://fapi.binance.com",
+		ListingInterval: 15 * time.Minute,
+		CheckListings:   true,
+
+		WatcherInterval:   60 * time.Second,
+		StreamURL:         "wss://fstream.binance.com/ws",
+		HandshakeTimeout:  45 * time.Second,
+		ReconnectMin:      time.Second,
+		ReconnectMax:      30 * time.Second,
+		ReconnectStrategy: "equal-jitter",
+		BreakerFailures:   10,
+		BreakerPause:      5 * time.Minute,
+		ConnectLimit:      300,
+		ConnectWindow:     5 * time.Minute,
+		RotateAfter:       23*time.Hour + 50*time.Minute,
+		RotateOverlap:     10 * time.Second,
+		QuarantinePanics:  5,
+		QuarantineWindow:  10 * time.Minute,
+		HTTPReadTimeout:   5 * time.Second,
+		HTTPWriteTimeout:  10 * time.Second,
+		HTTPIdleTimeout:   60 * time.Second,
+
+		ShutdownTimeout:      5 * time.Second,
+		ShutdownStoreTimeout: 30 * time.Second,
+
+		MaintInterval:      time.Minute,
+		CheckpointInterval: time.Hour,
+		CheckpointMB:       64,
+		VacuumInterval:     24 * time.Hour,
+		VacuumFreeMB:       256,
+		VacuumStepMB:       64,
+		AnalyzeInterval:    24 * time.Hour,
 	}
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// Load builds the configuration from the defaults, the config file named
+// by -config or CONFIG_FILE, the environment and the leading flags of args,
+// and validates it. It returns the arguments after the flags. Every
+// malformed or invalid value is reported; none falls back to its default.
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+	cfg.sources = make(map[string]Source)
+	fields := cfg.fields()
+
+	// Flags are collected first and applied last so they override the rest
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML (.yaml, .yml) or TOML (.toml) config file")
+	var flagged []string
+	flagValues := make(map[string]string)
+	for _, f := range fields {
+		fs.Var(&flagValue{def: f.value.String(), set: func(v string) {
+			flagged = append(flagged, f.key())
+			flagValues[f.key()] = v
+		}}, f.flag(), f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	var errs []error
+	if *file != "" {
+		values, err := readFile(*file)
+		if err != nil {
+			return cfg, nil, err
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			f, ok := lookup(fields, key)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown setting %q", *file, key))
+				continue
+			}
+			if err := f.value.Set(values[key]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", *file, key, err))
+				continue
+			}
+			cfg.sources[key] = SourceFile
+		}
+		cfg.File = *file
 	}
-	return fallback
+
+	for _, f := range fields {
+		v := os.Getenv(f.env)
+		if v == "" {
+			continue
+		}
+		if err := f.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			continue
+		}
+		cfg.sources[f.key()] = SourceEnv
+	}
+
+	for _, key := range flagged {
+		f, _ := lookup(fields, key)
+		if err := f.value.Set(flagValues[key]); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.flag(), err))
+			continue
+		}
+		cfg.sources[key] = SourceFlag
+	}
+
+	if err := errors.Join(append(errs, cfg.Validate())...); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, fs.Args(), nil
 }
 
-func getEnvInt(key string, fallback int) int {
-	if v := os.Getenv(key); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			return i
+// Validate reports every setting that is out of range or inconsistent.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
 		}
 	}
-	return fallback
+
+	check(c.HTTPPort > 0 && c.HTTPPort <= 65535, "http_port: %d is not a port number", c.HTTPPort)
+	check(c.Storage != "postgres" || c.PostgresDSN != "", "postgres_dsn: required by storage_backend postgres")
+	check(c.BackupKeep >= 0, "backup_keep: must not be negative")
+	check(c.CheckpointMB >= 0, "wal_checkpoint_mb: must not be negative")
+	check(c.VacuumFreeMB >= 0, "vacuum_free_mb: must not be negative")
+	check(c.VacuumStepMB >= 0, "vacuum_step_mb: must not be negative")
+	check(c.LogMaxSizeMB >= 0, "log_max_size_mb: must not be negative")
+	check(c.LogMaxFiles >= 0, "log_max_files: must not be negative")
+	check(c.LogRateLimit >= 0, "log_rate_limit: must not be negative")
+
+	for key, d := range map[string]time.Duration{
+		"stats_reconcile_interval": c.StatsInterval,
+		"watcher_interval":         c.WatcherInterval,
+		"reconnect_backoff_min":    c.ReconnectMin,
+		"shutdown_timeout":         c.ShutdownTimeout,
+		"shutdown_store_timeout":   c.ShutdownStoreTimeout,
+		"stream_handshake_timeout": c.HandshakeTimeout,
+	} {
+		check(d > 0, "%s: must be positive", key)
+	}
+	for key, d := range map[string]time.Duration{
+		"archive_interval":        c.ArchiveInterval,
+		"listing_check_interval":  c.ListingInterval,
+		"http_read_timeout":       c.HTTPReadTimeout,
+		"http_write_timeout":      c.HTTPWriteTimeout,
+		"http_idle_timeout":       c.HTTPIdleTimeout,
+		"maint_interval":          c.MaintInterval,
+		"wal_checkpoint_interval": c.CheckpointInterval,
+		"vacuum_interval":         c.VacuumInterval,
+		"analyze_interval":        c.AnalyzeInterval,
+	} {
+		check(d >= 0, "%s: must not be negative", key)
+	}
+	check(c.BreakerFailures >= 0, "reconnect_breaker_failures: must not be negative")
+	check(c.BreakerFailures == 0 || c.BreakerPause > 0, "reconnect_breaker_pause: must be positive when the breaker is enabled")
+	check(c.ConnectLimit >= 0, "stream_connect_limit: must not be negative")
+	check(c.ConnectLimit == 0 || c.ConnectWindow > 0, "stream_connect_window: must be positive when connections are limited")
+	check(c.RotateAfter >= 0 && c.RotateAfter < 24*time.Hour, "stream_rotate_after: must be under 24h, when Binance closes connections")
+	check(c.RotateAfter == 0 || c.RotateOverlap > 0 && c.RotateOverlap < c.RotateAfter, "stream_rotate_overlap: must be positive and shorter than stream_rotate_after")
+	check(c.QuarantinePanics >= 0, "stream_quarantine_panics: must not be negative")
+	check(c.QuarantinePanics == 0 || c.QuarantineWindow > 0, "stream_quarantine_window: must be positive when quarantine is enabled")
+	check(c.ReconnectMax >= c.ReconnectMin, "reconnect_backoff_max: must be at least reconnect_backoff_min (%s)", c.ReconnectMin)
+
+	check(validURL(c.ExchangeURL, "http", "https"), "exchange_api_url: %q is not an http(s) URL", c.ExchangeURL)
+	check(validURL(c.StreamURL, "ws", "wss"), "stream_url: %q is not a ws(s) URL", c.StreamURL)
+	check(c.StreamProxy == "" || validURL(c.StreamProxy, "http", "socks5"), "stream_proxy: %q is not an http or socks5 URL", redact(c.StreamProxy))
+	for _, h := range c.StreamHeaders {
+		name, _, ok := strings.Cut(h, "=")
+		check(ok && strings.TrimSpace(name) != "", "stream_headers: %q is not Name=value", name)
+	}
+	for _, u := range c.StandbyURLs {
+		check(validURL(u, "ws", "wss"), "stream_standby_urls: %q is not a ws(s) URL", u)
+	}
+
+	// Map iteration order varies; keep the report stable
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
 }
 
-func getLogLevel(key string, fallback slog.Level) slog.Level {
-	v := strings.ToUpper(os.Getenv(key))
-	switch v {
-	case "DEBUG":
-		return slog.LevelDebug
-	case "INFO":
-		return slog.LevelInfo
-	case "WARN", "WARNING":
-		return slog.LevelWarn
-	case "ERROR":
-		return slog.LevelError
-	default:
-		return fallback
+func validURL(raw string, schemes ...string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamHeader returns StreamHeaders as a header.
+func (c Config) StreamHeader() http.Header {
+	header := make(http.Header)
+	for _, h := range c.StreamHeaders {
+		if name, value, ok := strings.Cut(h, "="); ok {
+			header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return header
+}
+
+// Settings lists every setting with its effective value and source.
+func (c Config) Settings() []Setting {
+	fields := c.fields()
+	settings := make([]Setting, 0, len(fields))
+	for _, f := range fields {
+		value := f.value.String()
+		if f.secret {
+			value = redact(value)
+		}
+		source := c.sources[f.key()]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{
+			Key: f.key(), Env: f.env, Flag: "-" + f.flag(), Value: value, Source: source,
+		})
+	}
+	return settings
+}
+
+// redact hides the password of a URL-style DSN. Other forms are hidden
+// entirely, as they may carry one anywhere.
+func redact(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return "xxxxx"
+}
+
+// Change is a setting whose value differs between two configurations.
+type Change struct {
+	Key     string `json:"key"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Applied bool   `json:"applied"` // false if it takes a restart
+}
+
+// Diff lists the settings that differ from old in c, in Settings order.
+// Changed secrets are shown redacted.
+func (c Config) Diff(old Config) []Change {
+	before, after := old.fields(), c.fields()
+	var changes []Change
+	for i, f := range after {
+		from, to := before[i].value.String(), f.value.String()
+		if from == to {
+			continue
+		}
+		if f.secret {
+			from, to = redact(from), redact(to)
+		}
+		changes = append(changes, Change{Key: f.key(), From: from, To: to})
 	}
+	return changes
 }
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"binance-tick-store/internal/logging"
+)
+
+// field binds one setting to its Config field.
+type field struct {
+	env    string // names the setting everywhere; see key and flag
+	usage  string
+	value  value
+	secret bool // redacted by Settings
+}
+
+// key is the setting's name in a config file.
+func (f field) key() string { return strings.ToLower(f.env) }
+
+// flag is the setting's command-line flag, without the dash.
+func (f field) flag() string { return strings.ReplaceAll(f.key(), "_", "-") }
+
+func lookup(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.key() == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// fields lists every setting in the order `config print` shows them.
+func (c *Config) fields() []field {
+	return []field{
+		{env: "STORAGE_BACKEND", usage: "storage backend: sqlite, postgres, flatfile or ticklog", value: choiceValue{&c.Storage, []string{"sqlite", "postgres", "flatfile", "ticklog"}}},
+		{env: "DB_PATH", usage: "SQLite database file", value: stringValue{&c.DBPath}},
+		{env: "POSTGRES_DSN", usage: "PostgreSQL connection string", value: stringValue{&c.PostgresDSN}, secret: true},
+		{env: "POSTGRES_TIMESCALE", usage: "create price tables as TimescaleDB hypertables", value: boolValue{&c.Timescale}},
+		{env: "FLATFILE_DIR", usage: "flat file backend directory", value: stringValue{&c.FlatFileDir}},
+		{env: "TICKLOG_DIR", usage: "tick log directory", value: stringValue{&c.TickLogDir}},
+		{env: "HTTP_PORT", usage: "HTTP server port", value: intValue{&c.HTTPPort}},
+		{env: "HTTP_READ_TIMEOUT", usage: "HTTP request read timeout", value: durationValue{&c.HTTPReadTimeout}},
+		{env: "HTTP_WRITE_TIMEOUT", usage: "HTTP response write timeout; exports extend it per chunk", value: durationValue{&c.HTTPWriteTimeout}},
+		{env: "HTTP_IDLE_TIMEOUT", usage: "HTTP keep-alive idle timeout", value: durationValue{&c.HTTPIdleTimeout}},
+		{env: "ADMIN_TOKEN", usage: "bearer token for the backup, reload and symbol-changing endpoints, empty allows them from localhost only", value: stringValue{&c.AdminToken}, secret: true},
+		{env: "SHUTDOWN_TIMEOUT", usage: "how long each shutdown phase stopping HTTP requests, streams and writes may take", value: durationValue{&c.ShutdownTimeout}},
+		{env: "SHUTDOWN_STORE_TIMEOUT", usage: "how long each shutdown phase flushing, checkpointing and closing the store may take", value: durationValue{&c.ShutdownStoreTimeout}},
+		{env: "LOG_LEVEL", usage: "debug, info, warn or error", value: levelValue{&c.LogLevel}},
+		{env: "LOG_LEVELS", usage: "levels per subsystem overriding LOG_LEVEL, such as websocket=debug,http=warn", value: levelsValue{&c.LogLevels}},
+		{env: "LOG_FORMAT", usage: "log format: text or json", value: choiceValue{&c.LogFormat, []string{"text", "json"}}},
+		{env: "LOG_FILE", usage: "log file, empty logs to stdout", value: stringValue{&c.LogFile}},
+		{env: "LOG_MAX_SIZE_MB", usage: "rotate the log file once it exceeds this size, 0 never", value: intValue{&c.LogMaxSizeMB}},
+		{env: "LOG_MAX_FILES", usage: "rotated log files to keep", value: intValue{&c.LogMaxFiles}},
+		{env: "LOG_RATE_LIMIT", usage: "times per minute the same warning or error is logged, 0 unlimited", value: intValue{&c.LogRateLimit}},
+		{env: "WATCHER_INTERVAL", usage: "how often symbol settings are checked for changes", value: durationValue{&c.WatcherInterval}},
+		{env: "STREAM_URL", usage: "WebSocket stream base URL", value: stringValue{&c.StreamURL}},
+		{env: "STREAM_PROXY", usage: "http:// (CONNECT) or socks5:// proxy for stream connections, empty takes HTTPS_PROXY", value: stringValue{&c.StreamProxy}, secret: true},
+		{env: "STREAM_CA_FILE", usage: "PEM certificates trusted for stream connections in addition to the system's", value: stringValue{&c.StreamCAFile}},
+		{env: "STREAM_HANDSHAKE_TIMEOUT", usage: "stream connection handshake timeout, including the proxy and TLS", value: durationValue{&c.HandshakeTimeout}},
+		{env: "STREAM_COMPRESSION", usage: "negotiate permessage-deflate compression", value: boolValue{&c.StreamCompression}},
+		{env: "STREAM_HEADERS", usage: "comma-separated Name=value headers sent with each stream handshake", value: listValue{&c.StreamHeaders}, secret: true},
+		{env: "STREAM_STANDBY_URLS", usage: "comma-separated base URLs for the further connections of symbols with redundant connections", value: listValue{&c.StandbyURLs}},
+		{env: "RECONNECT_BACKOFF_MIN", usage: "first reconnect delay, doubled per failed attempt by the strategy", value: durationValue{&c.ReconnectMin}},
+		{env: "RECONNECT_BACKOFF_MAX", usage: "longest reconnect delay", value: durationValue{&c.ReconnectMax}},
+		{env: "RECONNECT_STRATEGY", usage: "reconnect delays: equal-jitter, full-jitter, decorrelated or exponential", value: choiceValue{&c.ReconnectStrategy, []string{"equal-jitter", "full-jitter", "decorrelated", "exponential"}}},
+		{env: "RECONNECT_BREAKER_FAILURES", usage: "failed reconnects in a row that pause a client, 0 disables the breaker", value: intValue{&c.BreakerFailures}},
+		{env: "RECONNECT_BREAKER_PAUSE", usage: "how long the breaker pauses a client between attempts", value: durationValue{&c.BreakerPause}},
+		{env: "STREAM_CONNECT_LIMIT", usage: "connections all clients may open per STREAM_CONNECT_WINDOW, 0 unlimited", value: intValue{&c.ConnectLimit}},
+		{env: "STREAM_CONNECT_WINDOW", usage: "window of STREAM_CONNECT_LIMIT", value: durationValue{&c.ConnectWindow}},
+		{env: "STREAM_ROTATE_AFTER", usage: "replace each connection this often, ahead of Binance closing it at 24h; 0 never", value: durationValue{&c.RotateAfter}},
+		{env: "STREAM_ROTATE_OVERLAP", usage: "how long a replaced connection keeps running alongside the new one", value: durationValue{&c.RotateOverlap}},
+		{env: "STREAM_QUARANTINE_PANICS", usage: "panics within STREAM_QUARANTINE_WINDOW that pause a symbol, 0 never", value: intValue{&c.QuarantinePanics}},
+		{env: "STREAM_QUARANTINE_WINDOW", usage: "window of STREAM_QUARANTINE_PANICS", value: durationValue{&c.QuarantineWindow}},
+		{env: "EXPORT_DIR", usage: "Parquet export directory", value: stringValue{&c.ExportDir}},
+		{env: "ARCHIVE_INTERVAL", usage: "how often closed days are archived, 0 disables", value: durationValue{&c.ArchiveInterval}},
+		{env: "STATS_RECONCILE_INTERVAL", usage: "how often maintained tick counts are recomputed", value: durationValue{&c.StatsInterval}},
+		{env: "BACKUP_DIR", usage: "backup directory", value: stringValue{&c.BackupDir}},
+		{env: "BACKUP_KEEP", usage: "backups to keep, 0 keeps all", value: intValue{&c.BackupKeep}},
+		{env: "BACKUP_COMPRESS", usage: "gzip backups", value: boolValue{&c.BackupCompress}},
+		{env: "VERIFY_REPORT", usage: "verification report file", value: stringValue{&c.VerifyReport}},
+		{env: "EXCHANGE_API_URL", usage: "Binance futures REST API base URL", value: stringValue{&c.ExchangeURL}},
+		{env: "LISTING_CHECK_INTERVAL", usage: "how often listings are refreshed, 0 disables the periodic check", value: durationValue{&c.ListingInterval}},
+		{env: "CHECK_LISTINGS", usage: "check symbols against the exchange's listings", value: boolValue{&c.CheckListings}},
+		{env: "MAINT_INTERVAL", usage: "how often maintenance thresholds are checked, 0 disables maintenance", value: durationValue{&c.MaintInterval}},
+		{env: "WAL_CHECKPOINT_INTERVAL", usage: "checkpoint the WAL this often, 0 disables", value: durationValue{&c.CheckpointInterval}},
+		{env: "WAL_CHECKPOINT_MB", usage: "checkpoint once the WAL exceeds this size, 0 disables", value: intValue{&c.CheckpointMB}},
+		{env: "VACUUM_INTERVAL", usage: "release free pages this often, 0 disables", value: durationValue{&c.VacuumInterval}},
+		{env: "VACUUM_FREE_MB", usage: "release free pages once they exceed this size, 0 disables", value: intValue{&c.VacuumFreeMB}},
+		{env: "VACUUM_STEP_MB", usage: "free space released per vacuum, 0 for all", value: intValue{&c.VacuumStepMB}},
+		{env: "ANALYZE_INTERVAL", usage: "refresh query planner statistics this often, 0 disables", value: durationValue{&c.AnalyzeInterval}},
+	}
+}
+
+// value parses and prints one Config field.
+type value interface {
+	String() string
+	Set(string) error
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string { return *v.p }
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+// listValue is a comma-separated list.
+type listValue struct{ p *[]string }
+
+func (v listValue) String() string { return strings.Join(*v.p, ",") }
+
+func (v listValue) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v.p = list
+	return nil
+}
+
+type choiceValue struct {
+	p       *string
+	choices []string
+}
+
+func (v choiceValue) String() string { return *v.p }
+
+func (v choiceValue) Set(s string) error {
+	s = strings.ToLower(s)
+	for _, c := range v.choices {
+		if s == c {
+			*v.p = s
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", s, strings.Join(v.choices, ", "))
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) String() string { return strconv.Itoa(*v.p) }
+
+func (v intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", s)
+	}
+	*v.p = i
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", s)
+	}
+	*v.p = b
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) String() string { return v.p.String() }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s or 1h", s)
+	}
+	*v.p = d
+	return nil
+}
+
+type levelValue struct{ p *slog.Level }
+
+func (v levelValue) String() string { return strings.ToLower(v.p.String()) }
+
+func (v levelValue) Set(s string) error {
+	level, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	*v.p = level
+	return nil
+}
+
+type levelsValue struct{ p *map[string]slog.Level }
+
+func (v levelsValue) String() string { return logging.FormatLevels(*v.p) }
+
+func (v levelsValue) Set(s string) error {
+	levels, err := logging.ParseLevels(s, ParseLevel)
+	if err != nil {
+		return err
+	}
+	*v.p = levels
+	return nil
+}
+
+// ParseLevel parses a log level name: debug, info, warn (or warning) or
+// error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(s) {
+	case "DEBUG":
+		return slog.LevelDebug, nil
+	case "INFO":
+		return slog.LevelInfo, nil
+	case "WARN", "WARNING":
+		return slog.LevelWarn, nil
+	case "ERROR":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("%q is not a log level (debug, info, warn, error)", s)
+	}
+}
+
+// flagValue records a flag's raw value for Load to apply after the file and
+// the environment. def is shown as the flag's default.
+type flagValue struct {
+	def string
+	set func(string)
+}
+
+func (v *flagValue) String() string { return v.def }
+
+func (v *flagValue) Set(s string) error {
+	v.set(s)
+	return nil
+}
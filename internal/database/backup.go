@@ -0,0 +1,22 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Backup writes a consistent point-in-time copy of the database to dest using
+// VACUUM INTO. It runs on its own connection inside a read transaction, so
+// tick inserts continue while the snapshot is written. dest must not exist.
+func (s *store) Backup(dest string) error {
+	db, err := sql.Open("sqlite", fileDSN(s.path, busyTimeout))
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("VACUUM INTO ?", dest); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", dest, err)
+	}
+	return nil
+}
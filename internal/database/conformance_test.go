@@ -0,0 +1,19 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/database/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return store
+	})
+}
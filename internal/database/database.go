@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -24,10 +26,16 @@ func ValidateSymbol(symbol string) error {
 	return nil
 }
 
-// SymbolSettings represents a symbol configuration.
+// SymbolSettings represents a symbol configuration. Enabled is true only for
+// active symbols.
 type SymbolSettings struct {
-	Symbol  string
-	Enabled bool
+	Symbol    string
+	Enabled   bool
+	State     string // StateActive, StatePaused, StateDelisted or StateRetired
+	Successor string // symbol a delisted one was renamed to, if any
+	// Connections is how many redundant connections capture the symbol,
+	// 1 unless set with a Redundancer.
+	Connections int
 }
 
 // DateRange represents min/max timestamps for a symbol.
@@ -36,20 +44,107 @@ type DateRange struct {
 	To   *time.Time
 }
 
-// Store defines database operations.
+// Price is a stored tick. AggID is the Binance aggregate trade id, or 0 for
+// ticks captured before aggregate ids were recorded.
+type Price struct {
+	ID        int64
+	AggID     int64
+	Timestamp int64
+	Price     float64
+}
+
+// PriceQuery selects one page of ticks in [From, To) ordered by timestamp, id.
+// After is the last row of the previous page, nil for the first page.
+type PriceQuery struct {
+	From  int64
+	To    int64
+	After *Price
+	Limit int
+}
+
+// Start is the lowest timestamp on the page: From, or the cursor's
+// timestamp if that is later.
+func (q PriceQuery) Start() int64 {
+	if q.After == nil {
+		return q.From
+	}
+	return max(q.From, q.After.Timestamp)
+}
+
+// Includes reports whether p is in the range and past the cursor.
+func (q PriceQuery) Includes(p Price) bool {
+	if p.Timestamp < q.From || p.Timestamp >= q.To {
+		return false
+	}
+	if q.After == nil {
+		return true
+	}
+	return p.Timestamp > q.After.Timestamp || (p.Timestamp == q.After.Timestamp && p.ID > q.After.ID)
+}
+
+// Store defines database operations. Every storage backend implements it and
+// must pass the shared suite in package storetest.
 type Store interface {
 	Close() error
 	GetSymbolSettings() ([]SymbolSettings, error)
+	SetSymbolEnabled(symbol string, enabled bool) error
 	EnsurePriceTable(symbol string) error
-	InsertPrice(symbol string, timestamp int64, price float64) error
+	InsertPrice(symbol string, aggID, timestamp int64, price float64) error
+	InsertPrices(symbol string, prices []Price) (int64, error)
 	GetDateRange(symbol string) (DateRange, error)
 	GetCount(symbol string) (int64, error)
+	GetStats(symbol string) (SymbolStats, error)
+	// ReconcileStats recomputes the stats from the stored ticks and returns
+	// how far the maintained count was off (actual minus maintained).
+	ReconcileStats(symbol string) (drift int64, err error)
+	GetPrices(symbol string, q PriceQuery) ([]Price, error)
+	MarkExported(rec ExportRecord) error
+	GetExportRecords(symbol string) ([]ExportRecord, error)
+}
+
+// IntegrityChecker is implemented by stores that can check their on-disk
+// structures. IntegrityCheck returns []string{"ok"} when nothing is wrong.
+type IntegrityChecker interface {
+	IntegrityCheck() ([]string, error)
+}
+
+// Backuper is implemented by stores that can write a consistent snapshot of
+// themselves while in use.
+type Backuper interface {
+	Backup(dest string) error
+}
+
+// Flusher is implemented by stores that buffer inserted ticks in memory.
+// Flush writes them out.
+type Flusher interface {
+	Flush() error
+}
+
+const (
+	// maxReaders bounds the read-only connection pool.
+	maxReaders = 4
+	// busyTimeout is how long a connection waits on a lock held by another
+	// process (e.g. an import running next to the server).
+	busyTimeout = "_pragma=busy_timeout(5000)"
+)
+
+// fileDSN returns a file: URI for the database at path with the query
+// params, escaping characters such as ? and # that would otherwise end the
+// file name.
+func fileDSN(path, params string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?" + params
 }
 
+// store uses one writer connection and a pool of read-only connections.
+// WAL mode lets readers run concurrently with the writer, so status queries
+// and exports never wait for tick inserts (or vice versa).
 type store struct {
-	db    *sql.DB
-	mu    sync.Mutex
-	stmts map[string]*sql.Stmt // prepared statements cache
+	path   string
+	writer *sql.DB
+	reader *sql.DB
+	mu     sync.Mutex           // serializes writes and guards stmts
+	stmts  map[string]*sql.Stmt // prepared insert statements (writer)
+	maint  maintenance
 }
 
 // Open creates a new database connection with WAL mode.
@@ -58,37 +153,95 @@ func Open(path string) (Store, error) {
 		return nil, fmt.Errorf("create data dir: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", path)
+	writer, err := sql.Open("sqlite", fileDSN(path, busyTimeout+"&_txlock=immediate"))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	writer.SetMaxOpenConns(1)
+
+	// Only takes effect on a new database; existing ones need FullVacuum
+	if _, err := writer.Exec("PRAGMA auto_vacuum=INCREMENTAL"); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("set auto_vacuum: %w", err)
+	}
 
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
+	if _, err := writer.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		writer.Close()
 		return nil, fmt.Errorf("set WAL mode: %w", err)
 	}
 
-	if err := createSettingsTable(db); err != nil {
-		db.Close()
+	if err := createSettingsTable(writer); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	if err := createExportLogTable(writer); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	if err := createStatsTable(writer); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	if err := createMetadataTable(writer); err != nil {
+		writer.Close()
 		return nil, err
 	}
 
+	reader, err := sql.Open("sqlite", fileDSN(path, busyTimeout+"&_pragma=query_only(1)"))
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	reader.SetMaxOpenConns(maxReaders)
+	reader.SetMaxIdleConns(maxReaders)
+
 	return &store{
-		db:    db,
-		stmts: make(map[string]*sql.Stmt),
+		path:   path,
+		writer: writer,
+		reader: reader,
+		stmts:  make(map[string]*sql.Stmt),
 	}, nil
 }
 
 func createSettingsTable(db *sql.DB) error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS symbol_settings (
-			symbol  TEXT PRIMARY KEY,
-			enabled INTEGER DEFAULT 1
+			symbol    TEXT PRIMARY KEY,
+			enabled   INTEGER DEFAULT 1,
+			retired   INTEGER NOT NULL DEFAULT 0,
+			delisted  INTEGER NOT NULL DEFAULT 0,
+			successor TEXT NOT NULL DEFAULT '',
+			connections INTEGER NOT NULL DEFAULT 1
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("create symbol_settings table: %w", err)
 	}
+
+	// Tables created before symbols had lifecycle states (or by `make enable`)
+	columns := []struct{ name, def string }{
+		{"retired", "INTEGER NOT NULL DEFAULT 0"},
+		{"delisted", "INTEGER NOT NULL DEFAULT 0"},
+		{"successor", "TEXT NOT NULL DEFAULT ''"},
+		{"connections", "INTEGER NOT NULL DEFAULT 1"},
+	}
+	for _, col := range columns {
+		var exists int
+		err = db.QueryRow(
+			"SELECT COUNT(*) FROM pragma_table_info('symbol_settings') WHERE name = ?", col.name,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("inspect symbol_settings: %w", err)
+		}
+		if exists == 0 {
+			if _, err := db.Exec("ALTER TABLE symbol_settings ADD COLUMN " + col.name + " " + col.def); err != nil {
+				return fmt.Errorf("add %s to symbol_settings: %w", col.name, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -99,14 +252,15 @@ func (s *store) Close() error {
 	for _, stmt := range s.stmts {
 		stmt.Close()
 	}
-	return s.db.Close()
+	rerr := s.reader.Close()
+	if err := s.writer.Close(); err != nil {
+		return err
+	}
+	return rerr
 }
 
 func (s *store) GetSymbolSettings() ([]SymbolSettings, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	rows, err := s.db.Query("SELECT symbol, enabled FROM symbol_settings")
+	rows, err := s.reader.Query("SELECT symbol, enabled, retired, delisted, successor, connections FROM symbol_settings")
 	if err != nil {
 		return nil, fmt.Errorf("query symbol_settings: %w", err)
 	}
@@ -115,16 +269,37 @@ func (s *store) GetSymbolSettings() ([]SymbolSettings, error) {
 	var settings []SymbolSettings
 	for rows.Next() {
 		var ss SymbolSettings
-		var enabled int
-		if err := rows.Scan(&ss.Symbol, &enabled); err != nil {
+		var enabled, retired, delisted int
+		if err := rows.Scan(&ss.Symbol, &enabled, &retired, &delisted, &ss.Successor, &ss.Connections); err != nil {
 			return nil, fmt.Errorf("scan row: %w", err)
 		}
-		ss.Enabled = enabled == 1
+		ss.State = SymbolState(enabled == 1, retired == 1, delisted == 1)
+		ss.Enabled = ss.State == StateActive
 		settings = append(settings, ss)
 	}
 	return settings, rows.Err()
 }
 
+func (s *store) SetSymbolEnabled(symbol string, enabled bool) error {
+	if err := ValidateSymbol(symbol); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.writer.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled) VALUES (?, ?)
+		ON CONFLICT(symbol) DO UPDATE SET enabled = excluded.enabled,
+			retired = CASE WHEN excluded.enabled = 1 THEN 0 ELSE retired END,
+			delisted = CASE WHEN excluded.enabled = 1 THEN 0 ELSE delisted END
+	`, strings.ToUpper(symbol), boolToInt(enabled))
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
+
 func (s *store) EnsurePriceTable(symbol string) error {
 	if err := ValidateSymbol(symbol); err != nil {
 		return err
@@ -138,22 +313,39 @@ func (s *store) EnsurePriceTable(symbol string) error {
 		CREATE TABLE IF NOT EXISTS %s (
 			id        INTEGER PRIMARY KEY AUTOINCREMENT,
 			timestamp INTEGER NOT NULL,
-			price     REAL NOT NULL
+			price     REAL NOT NULL,
+			agg_id    INTEGER
 		)
 	`, table)
 
-	if _, err := s.db.Exec(query); err != nil {
+	if _, err := s.writer.Exec(query); err != nil {
 		return fmt.Errorf("create price table %s: %w", table, err)
 	}
 
+	if err := s.addAggIDColumn(table); err != nil {
+		return err
+	}
+
 	idx := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_timestamp ON %s(timestamp)", table, table)
-	if _, err := s.db.Exec(idx); err != nil {
+	if _, err := s.writer.Exec(idx); err != nil {
 		return fmt.Errorf("create index on %s: %w", table, err)
 	}
 
-	// Prepare insert statement for this symbol
-	insertSQL := fmt.Sprintf("INSERT INTO %s (timestamp, price) VALUES (?, ?)", table)
-	stmt, err := s.db.Prepare(insertSQL)
+	if err := s.ensureStatsTrigger(symbol, table); err != nil {
+		return err
+	}
+
+	if err := s.ensureUniqueAggID(symbol, table); err != nil {
+		return err
+	}
+
+	// Prepare insert statement for this symbol. Ticks already stored are
+	// skipped; an agg_id of 0 (unknown) is stored as NULL and never conflicts.
+	insertSQL := fmt.Sprintf(`
+		INSERT INTO %s (agg_id, timestamp, price) VALUES (NULLIF(?, 0), ?, ?)
+		ON CONFLICT(agg_id) DO NOTHING
+	`, table)
+	stmt, err := s.writer.Prepare(insertSQL)
 	if err != nil {
 		return fmt.Errorf("prepare insert statement: %w", err)
 	}
@@ -162,7 +354,85 @@ func (s *store) EnsurePriceTable(symbol string) error {
 	return nil
 }
 
-func (s *store) InsertPrice(symbol string, timestamp int64, price float64) error {
+// addAggIDColumn migrates price tables created before aggregate trade ids
+// were stored. Must be called with s.mu held.
+func (s *store) addAggIDColumn(table string) error {
+	var exists int
+	err := s.writer.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'agg_id'", table,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	if _, err := s.writer.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN agg_id INTEGER", table)); err != nil {
+		return fmt.Errorf("add agg_id to %s: %w", table, err)
+	}
+	return nil
+}
+
+// ensureUniqueAggID replaces the plain agg_id index of tables created
+// before duplicate protection with a unique one, deleting all but the first
+// copy of each tick. Must be called with s.mu held.
+func (s *store) ensureUniqueAggID(symbol, table string) error {
+	index := fmt.Sprintf("idx_%s_agg_id", table)
+
+	var unique int
+	err := s.writer.QueryRow(
+		`SELECT COUNT(*) FROM pragma_index_list(?) WHERE name = ? AND "unique" = 1`, table, index,
+	).Scan(&unique)
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if unique > 0 {
+		return nil
+	}
+
+	tx, err := s.writer.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET agg_id = NULL WHERE agg_id = 0", table)); err != nil {
+		return fmt.Errorf("clear unknown agg_id in %s: %w", table, err)
+	}
+	res, err := tx.Exec(fmt.Sprintf(`
+		DELETE FROM %s WHERE agg_id IS NOT NULL AND id NOT IN (
+			SELECT MIN(id) FROM %s WHERE agg_id IS NOT NULL GROUP BY agg_id
+		)
+	`, table, table))
+	if err != nil {
+		return fmt.Errorf("remove duplicates from %s: %w", table, err)
+	}
+	removed, _ := res.RowsAffected()
+
+	if _, err := tx.Exec("DROP INDEX IF EXISTS " + index); err != nil {
+		return fmt.Errorf("drop index on %s: %w", table, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s(agg_id)", index, table)); err != nil {
+		return fmt.Errorf("create unique index on %s: %w", table, err)
+	}
+	if err := addDuplicates(tx, symbol, removed); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if removed == 0 {
+		return nil
+	}
+	slog.Info("removed duplicate ticks", "symbol", strings.ToUpper(symbol), "count", removed)
+	return s.reconcileStats(symbol)
+}
+
+// InsertPrice stores one tick. A tick whose aggregate id is already stored
+// is dropped and counted in the symbol's duplicates.
+func (s *store) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -171,76 +441,139 @@ func (s *store) InsertPrice(symbol string, timestamp int64, price float64) error
 		return fmt.Errorf("no prepared statement for symbol %s", symbol)
 	}
 
-	_, err := stmt.Exec(timestamp, price)
+	res, err := stmt.Exec(aggID, timestamp, price)
 	if err != nil {
 		return fmt.Errorf("insert price: %w", err)
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return addDuplicates(s.writer, symbol, 1)
+	}
 	return nil
 }
 
-func (s *store) GetDateRange(symbol string) (DateRange, error) {
+// InsertPrices inserts a batch of ticks in one transaction, skipping ticks
+// whose aggregate id is already stored. It returns the number inserted.
+func (s *store) InsertPrices(symbol string, prices []Price) (int64, error) {
 	if err := ValidateSymbol(symbol); err != nil {
-		return DateRange{}, err
+		return 0, err
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	table := priceTableName(symbol)
+	tx, err := s.writer.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
 
-	// Check if table exists
-	var exists int
-	err := s.db.QueryRow(`
-		SELECT COUNT(*) FROM sqlite_master
-		WHERE type='table' AND name=?
-	`, table).Scan(&exists)
-	if err != nil || exists == 0 {
-		return DateRange{}, nil
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO %s (agg_id, timestamp, price) VALUES (NULLIF(?, 0), ?, ?)
+		ON CONFLICT(agg_id) DO NOTHING
+	`, table))
+	if err != nil {
+		return 0, fmt.Errorf("prepare insert: %w", err)
 	}
+	defer stmt.Close()
 
-	var minTS, maxTS sql.NullInt64
-	query := fmt.Sprintf("SELECT MIN(timestamp), MAX(timestamp) FROM %s", table)
-	if err := s.db.QueryRow(query).Scan(&minTS, &maxTS); err != nil {
-		return DateRange{}, fmt.Errorf("query date range: %w", err)
+	var inserted int64
+	for _, p := range prices {
+		res, err := stmt.Exec(p.AggID, p.Timestamp, p.Price)
+		if err != nil {
+			return 0, fmt.Errorf("insert price: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		inserted += n
 	}
 
-	var dr DateRange
-	if minTS.Valid {
-		t := time.UnixMilli(minTS.Int64).UTC()
-		dr.From = &t
+	if err := addDuplicates(tx, symbol, int64(len(prices))-inserted); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
 	}
-	if maxTS.Valid {
-		t := time.UnixMilli(maxTS.Int64).UTC()
-		dr.To = &t
+	return inserted, nil
+}
+
+// GetDateRange reads the first and last tick time from symbol_stats.
+func (s *store) GetDateRange(symbol string) (DateRange, error) {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
+		return DateRange{}, err
 	}
-	return dr, nil
+	return DateRange{From: stats.FirstTS, To: stats.LastTS}, nil
 }
 
+// GetCount reads the tick count from symbol_stats.
 func (s *store) GetCount(symbol string) (int64, error) {
-	if err := ValidateSymbol(symbol); err != nil {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
 		return 0, err
 	}
+	return stats.Count, nil
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *store) GetPrices(symbol string, q PriceQuery) ([]Price, error) {
+	if err := ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
 
 	table := priceTableName(symbol)
+	if !s.tableExists(table) {
+		return nil, nil
+	}
+
+	// Start the index range scan at the cursor rather than at From
+	args := []any{q.Start(), q.To}
+	var after string
+	if q.After != nil {
+		after = "AND (timestamp, id) > (?, ?)"
+		args = append(args, q.After.Timestamp, q.After.ID)
+	}
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(agg_id, 0), timestamp, price FROM %s
+		WHERE timestamp >= ? AND timestamp < ? %s
+		ORDER BY timestamp, id
+		LIMIT ?
+	`, table, after)
+
+	rows, err := s.reader.Query(query, append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer rows.Close()
 
+	prices := make([]Price, 0, q.Limit)
+	for rows.Next() {
+		var p Price
+		if err := rows.Scan(&p.ID, &p.AggID, &p.Timestamp, &p.Price); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// tableExists reports whether table exists.
+func (s *store) tableExists(table string) bool {
 	var exists int
-	err := s.db.QueryRow(`
+	err := s.reader.QueryRow(`
 		SELECT COUNT(*) FROM sqlite_master
 		WHERE type='table' AND name=?
 	`, table).Scan(&exists)
-	if err != nil || exists == 0 {
-		return 0, nil
-	}
+	return err == nil && exists > 0
+}
 
-	var count int64
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", table)
-	if err := s.db.QueryRow(query).Scan(&count); err != nil {
-		return 0, fmt.Errorf("query count: %w", err)
+func boolToInt(b bool) int {
+	if b {
+		return 1
 	}
-	return count, nil
+	return 0
 }
 
 func priceTableName(symbol string) string {
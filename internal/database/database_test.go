@@ -1,9 +1,11 @@
 package database
 
 import (
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestValidateSymbol(t *testing.T) {
@@ -80,7 +82,7 @@ func TestPriceTable(t *testing.T) {
 	}
 
 	// Insert price
-	if err := store.InsertPrice("BTCUSDT", 1700000000000, 42000.50); err != nil {
+	if err := store.InsertPrice("BTCUSDT", 1, 1700000000000, 42000.50); err != nil {
 		t.Fatalf("InsertPrice failed: %v", err)
 	}
 
@@ -126,3 +128,333 @@ func TestGetDateRange_NoTable(t *testing.T) {
 		t.Error("expected nil date range for non-existent table")
 	}
 }
+
+func TestPriceIterator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+
+	// Insert out of order, with a duplicate timestamp
+	for i, ts := range []int64{1000, 3000, 2000, 2000, 4000, 5000} {
+		if err := store.InsertPrice("BTCUSDT", int64(i), ts, float64(ts)); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+
+	// Page size smaller than result forces several GetPrices calls
+	it := NewPriceIterator(store, "BTCUSDT", 2000, 5000, 2)
+	var got []int64
+	for it.Next() {
+		got = append(got, it.Price().Timestamp)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator failed: %v", err)
+	}
+
+	want := []int64{2000, 2000, 3000, 4000}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestGetPrices_NoTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	prices, err := store.GetPrices("NONEXISTENT", PriceQuery{To: 1 << 62})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(prices) != 0 {
+		t.Errorf("expected no prices, got %d", len(prices))
+	}
+}
+
+func TestExportRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	rec := ExportRecord{Symbol: "BTCUSDT", Day: "2024-01-15", Path: "/tmp/x.parquet", Rows: 10, ExportedAt: time.Now()}
+	if err := store.MarkExported(rec); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+	// Re-export replaces the record
+	rec.Rows = 12
+	if err := store.MarkExported(rec); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+
+	records, err := store.GetExportRecords("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetExportRecords failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Day != "2024-01-15" || records[0].Rows != 12 {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
+func TestEnsurePriceTable_AddsAggIDToLegacyTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	db := store.writer
+	if _, err := db.Exec(`CREATE TABLE prices_BTCUSDT (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp INTEGER NOT NULL, price REAL NOT NULL)`); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO prices_BTCUSDT (timestamp, price) VALUES (1000, 1.5)"); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if count, _ := store.GetCount("BTCUSDT"); count != 1 {
+		t.Errorf("expected legacy row counted, got %d", count)
+	}
+	n, err := store.InsertPrices("BTCUSDT", []Price{{AggID: 7, Timestamp: 2000, Price: 2.5}})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertPrices = %d, %v", n, err)
+	}
+
+	prices, err := store.GetPrices("BTCUSDT", PriceQuery{To: 1 << 62})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(prices) != 2 || prices[0].AggID != 0 || prices[1].AggID != 7 {
+		t.Errorf("unexpected prices: %+v", prices)
+	}
+}
+
+func TestEnsurePriceTable_RemovesDuplicatesFromExistingTable(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	// A table from before agg_id was unique, with a replayed tick
+	db := store.writer
+	for _, stmt := range []string{
+		`CREATE TABLE prices_BTCUSDT (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp INTEGER NOT NULL, price REAL NOT NULL, agg_id INTEGER)`,
+		"CREATE INDEX idx_prices_BTCUSDT_agg_id ON prices_BTCUSDT(agg_id)",
+		`INSERT INTO prices_BTCUSDT (agg_id, timestamp, price) VALUES
+			(1, 1000, 1), (2, 2000, 2), (1, 1000, 1), (0, 3000, 3), (0, 3000, 3)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+	}
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	stats, _ := store.GetStats("BTCUSDT")
+	if stats.Count != 4 || stats.Duplicates != 1 {
+		t.Errorf("expected 4 ticks and 1 duplicate, got %+v", stats)
+	}
+
+	prices, _ := store.GetPrices("BTCUSDT", PriceQuery{To: 1 << 62})
+	if len(prices) != 4 || prices[0].ID != 1 || prices[2].AggID != 0 {
+		t.Errorf("unexpected prices: %+v", prices)
+	}
+
+	// Idempotent once migrated
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if err := store.InsertPrice("BTCUSDT", 2, 2000, 2); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+	if stats, _ := store.GetStats("BTCUSDT"); stats.Count != 4 || stats.Duplicates != 2 {
+		t.Errorf("expected replay dropped, got %+v", stats)
+	}
+}
+
+func TestReadsDoNotWaitForWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if err := store.InsertPrice("BTCUSDT", 1, 1000, 1.5); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+
+	// Simulate a long write: hold the writer lock with an open transaction
+	store.mu.Lock()
+	tx, err := store.writer.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO prices_BTCUSDT (agg_id, timestamp, price) VALUES (2, 2000, 2.5)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	defer func() {
+		tx.Rollback()
+		store.mu.Unlock()
+	}()
+
+	done := make(chan int64, 1)
+	go func() {
+		count, _ := store.GetCount("BTCUSDT")
+		done <- count
+	}()
+
+	select {
+	case count := <-done:
+		if count != 1 {
+			t.Errorf("expected committed count 1, got %d", count)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("read blocked by writer")
+	}
+}
+
+func TestStats_MaintainedAndReconciled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if err := store.InsertPrice("BTCUSDT", 2, 2000, 1.5); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+	if _, err := store.InsertPrices("BTCUSDT", []Price{{AggID: 1, Timestamp: 1000}, {AggID: 3, Timestamp: 3000}}); err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+
+	stats, err := store.GetStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Count != 3 || stats.FirstTS.UnixMilli() != 1000 || stats.LastTS.UnixMilli() != 3000 {
+		t.Errorf("unexpected stats: count=%d first=%v last=%v", stats.Count, stats.FirstTS, stats.LastTS)
+	}
+	if stats.LastInsertAt == nil || time.Since(*stats.LastInsertAt) > time.Minute {
+		t.Errorf("unexpected last insert time: %v", stats.LastInsertAt)
+	}
+
+	// Deletes are not tracked incrementally; reconcile fixes the drift
+	if _, err := store.writer.Exec("DELETE FROM prices_BTCUSDT WHERE timestamp = 1000"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	drift, err := store.ReconcileStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("ReconcileStats failed: %v", err)
+	}
+	if drift != -1 {
+		t.Errorf("expected a drift of -1, got %d", drift)
+	}
+
+	dr, _ := store.GetDateRange("BTCUSDT")
+	count, _ := store.GetCount("BTCUSDT")
+	if count != 2 || dr.From.UnixMilli() != 2000 {
+		t.Errorf("expected reconciled count 2 from 2000, got %d from %v", count, dr.From)
+	}
+}
+
+func TestReconcileStats_InsertsDuringScan(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	store.EnsurePriceTable("BTCUSDT")
+	store.InsertPrice("BTCUSDT", 1, 2000, 1)
+	store.InsertPrice("BTCUSDT", 2, 3000, 1)
+	store.writer.Exec("DELETE FROM prices_BTCUSDT WHERE agg_id = 1") // drift
+
+	// Ticks inserted between the scan and the correction are kept
+	snap, err := scanStats(store.reader, "prices_BTCUSDT", 0)
+	if err != nil {
+		t.Fatalf("scanStats failed: %v", err)
+	}
+	store.InsertPrice("BTCUSDT", 3, 1000, 1)
+	store.InsertPrice("BTCUSDT", 4, 4000, 1)
+
+	store.mu.Lock()
+	drift, err := store.applyStats("BTCUSDT", snap)
+	store.mu.Unlock()
+	if err != nil {
+		t.Fatalf("applyStats failed: %v", err)
+	}
+	if drift != -1 {
+		t.Errorf("expected only the deleted tick as drift, got %d", drift)
+	}
+
+	stats, _ := store.GetStats("BTCUSDT")
+	if stats.Count != 3 || stats.FirstTS.UnixMilli() != 1000 || stats.LastTS.UnixMilli() != 4000 {
+		t.Errorf("expected 3 ticks from 1000 to 4000, got %d from %v to %v", stats.Count, stats.FirstTS, stats.LastTS)
+	}
+}
+
+func TestSymbolMetadata_PriceFormatting(t *testing.T) {
+	btc := SymbolMetadata{Symbol: "BTCUSDT", TickSize: "0.10", PricePrecision: 2}
+	if d := btc.TickDecimals(); d != 1 {
+		t.Errorf("expected 1 tick decimal, got %d", d)
+	}
+	if got := btc.FormatPrice(42000.1); got != "42000.10" {
+		t.Errorf("expected 42000.10, got %s", got)
+	}
+	for price, want := range map[float64]bool{42000.1: true, 42000: true, 0.3: true, 42000.15: false, 42000.01: false} {
+		if got := btc.OnTick(price); got != want {
+			t.Errorf("OnTick(%v): expected %v, got %v", price, want, got)
+		}
+	}
+
+	// Ticks coarser than one unit of the last decimal
+	shib := SymbolMetadata{Symbol: "1000SHIBUSDT", TickSize: "0.000005", PricePrecision: 4}
+	if got := shib.FormatPrice(0.012345); got != "0.012345" {
+		t.Errorf("expected the tick size to widen the precision, got %s", got)
+	}
+	if !shib.OnTick(0.012345) || shib.OnTick(0.012346) {
+		t.Error("expected multiples of 5 in the last decimal to be on tick")
+	}
+
+	var unknown SymbolMetadata
+	if unknown.TickDecimals() != -1 || !unknown.OnTick(math.Pi) || unknown.FormatPrice(1.5) != "1.5" {
+		t.Error("expected unknown metadata to accept and format any price")
+	}
+}
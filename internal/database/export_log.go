@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExportRecord records that a symbol's UTC day was archived.
+type ExportRecord struct {
+	Symbol     string
+	Day        string // YYYY-MM-DD
+	Path       string // empty when the day had no ticks
+	Rows       int64
+	ExportedAt time.Time
+}
+
+func createExportLogTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS export_log (
+			symbol      TEXT NOT NULL,
+			day         TEXT NOT NULL,
+			path        TEXT NOT NULL,
+			rows        INTEGER NOT NULL,
+			exported_at INTEGER NOT NULL,
+			PRIMARY KEY (symbol, day)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create export_log table: %w", err)
+	}
+	return nil
+}
+
+func (s *store) MarkExported(rec ExportRecord) error {
+	if err := ValidateSymbol(rec.Symbol); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.writer.Exec(`
+		INSERT OR REPLACE INTO export_log (symbol, day, path, rows, exported_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, strings.ToUpper(rec.Symbol), rec.Day, rec.Path, rec.Rows, rec.ExportedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("insert export_log: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetExportRecords(symbol string) ([]ExportRecord, error) {
+	if err := ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader.Query(`
+		SELECT symbol, day, path, rows, exported_at FROM export_log
+		WHERE symbol = ? ORDER BY day
+	`, strings.ToUpper(symbol))
+	if err != nil {
+		return nil, fmt.Errorf("query export_log: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ExportRecord
+	for rows.Next() {
+		var rec ExportRecord
+		var exportedAt int64
+		if err := rows.Scan(&rec.Symbol, &rec.Day, &rec.Path, &rec.Rows, &exportedAt); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		rec.ExportedAt = time.UnixMilli(exportedAt).UTC()
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
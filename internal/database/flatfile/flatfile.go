@@ -0,0 +1,973 @@
+// Package flatfile implements database.Store on plain append-only files, for
+// deployments that want ticks on disk without a database engine.
+//
+// Layout under the store directory:
+//
+//	symbol_settings.csv   symbol,enabled,retired,delisted,successor,connections (rewritten on change)
+//	export_log.csv        symbol,day,path,rows,exported_at_ms (appended, last row wins)
+//	symbol_metadata.csv   symbol,contract_type,tick_size,step_size,price_precision,quantity_precision,updated_at_ms
+//	duplicates.csv        symbol,duplicates (appended, last row wins)
+//	prices/<SYMBOL>.bin   fixed 32-byte little-endian records: id, agg_id, timestamp, price
+//
+// Stats are computed by scanning each price file when it is first used and
+// maintained in memory afterwards. Range reads binary search the leading
+// records in timestamp order and sort any ticks appended out of order after
+// them in memory; Compact sorts those into the file.
+// The range of aggregate ids in every 4096 records is kept in memory, so a
+// tick whose id is not above the highest stored one is checked for a
+// duplicate by reading only the chunks that could hold it. A symbol's
+// running duplicate count is appended to duplicates.csv after every batch
+// that dropped any. Only one process may write a store directory at a time.
+package flatfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const recordSize = 32
+
+type store struct {
+	dir      string
+	mu       sync.Mutex // guards settings, metadata, exports and tables
+	settings map[string]database.SymbolSettings
+	metadata map[string]database.SymbolMetadata
+	exports  map[string]map[string]database.ExportRecord
+	tables   map[string]*priceFile
+
+	dupMu      sync.Mutex // guards duplicates and duplicates.csv
+	duplicates map[string]int64
+}
+
+// Open creates dir if needed and loads the settings and export log.
+func Open(dir string) (database.Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "prices"), 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	s := &store{
+		dir:      dir,
+		settings: make(map[string]database.SymbolSettings),
+		metadata: make(map[string]database.SymbolMetadata),
+		exports:  make(map[string]map[string]database.ExportRecord),
+		tables:   make(map[string]*priceFile),
+
+		duplicates: make(map[string]int64),
+	}
+	if err := s.loadSettings(); err != nil {
+		return nil, err
+	}
+	if err := s.loadExports(); err != nil {
+		return nil, err
+	}
+	if err := s.loadMetadata(); err != nil {
+		return nil, err
+	}
+	if err := s.loadDuplicates(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, pf := range s.tables {
+		errs = append(errs, pf.close())
+	}
+	s.tables = make(map[string]*priceFile)
+	return errors.Join(errs...)
+}
+
+func (s *store) GetSymbolSettings() ([]database.SymbolSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := make([]database.SymbolSettings, 0, len(s.settings))
+	for _, ss := range s.settings {
+		settings = append(settings, ss)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Symbol < settings[j].Symbol })
+	return settings, nil
+}
+
+func (s *store) SetSymbolEnabled(symbol string, enabled bool) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol = strings.ToUpper(symbol)
+	ss := s.settings[symbol]
+	ss.Symbol = symbol
+	switch {
+	case enabled:
+		ss.State = database.StateActive
+	case ss.State == database.StateActive || ss.State == "":
+		ss.State = database.StatePaused
+	}
+	s.setSettings(ss)
+	return s.saveSettings()
+}
+
+func (s *store) EnsurePriceTable(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	_, err := s.table(symbol, true)
+	return err
+}
+
+func (s *store) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
+	pf, err := s.table(symbol, false)
+	if err != nil {
+		return err
+	}
+	if pf == nil {
+		return fmt.Errorf("no price file for symbol %s", symbol)
+	}
+
+	_, err = pf.append([]database.Price{{AggID: aggID, Timestamp: timestamp, Price: price}})
+	return err
+}
+
+func (s *store) InsertPrices(symbol string, prices []database.Price) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	pf, err := s.table(symbol, true)
+	if err != nil {
+		return 0, err
+	}
+	return pf.append(prices)
+}
+
+func (s *store) GetDateRange(symbol string) (database.DateRange, error) {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
+		return database.DateRange{}, err
+	}
+	return database.DateRange{From: stats.FirstTS, To: stats.LastTS}, nil
+}
+
+func (s *store) GetCount(symbol string) (int64, error) {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Count, nil
+}
+
+func (s *store) GetStats(symbol string) (database.SymbolStats, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return database.SymbolStats{}, err
+	}
+
+	stats := database.SymbolStats{Symbol: strings.ToUpper(symbol)}
+	pf, err := s.table(symbol, false)
+	if err != nil || pf == nil {
+		return stats, err
+	}
+	return pf.stats(stats), nil
+}
+
+func (s *store) ReconcileStats(symbol string) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	pf, err := s.table(symbol, false)
+	if err != nil || pf == nil {
+		return 0, err
+	}
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	stored := pf.count
+	if err := pf.scan(); err != nil {
+		return 0, err
+	}
+	return pf.count - stored, nil
+}
+
+func (s *store) GetPrices(symbol string, q database.PriceQuery) ([]database.Price, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = database.DefaultPageSize
+	}
+
+	pf, err := s.table(symbol, false)
+	if err != nil || pf == nil {
+		return nil, err
+	}
+	return pf.query(q)
+}
+
+func (s *store) MarkExported(rec database.ExportRecord) error {
+	if err := database.ValidateSymbol(rec.Symbol); err != nil {
+		return err
+	}
+	rec.Symbol = strings.ToUpper(rec.Symbol)
+	rec.ExportedAt = time.UnixMilli(rec.ExportedAt.UnixMilli()).UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.dir, "export_log.csv"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open export log: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{
+		rec.Symbol, rec.Day, rec.Path,
+		strconv.FormatInt(rec.Rows, 10),
+		strconv.FormatInt(rec.ExportedAt.UnixMilli(), 10),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write export log: %w", err)
+	}
+
+	s.addExport(rec)
+	return nil
+}
+
+func (s *store) GetExportRecords(symbol string) ([]database.ExportRecord, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []database.ExportRecord
+	for _, rec := range s.exports[strings.ToUpper(symbol)] {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Day < records[j].Day })
+	return records, nil
+}
+
+// RetireSymbol marks symbol retired in the settings file and deletes its
+// price file.
+func (s *store) RetireSymbol(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	symbol = strings.ToUpper(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ss := s.settings[symbol]
+	ss.Symbol, ss.State = symbol, database.StateRetired
+	s.setSettings(ss)
+	if err := s.saveSettings(); err != nil {
+		return err
+	}
+	if pf, ok := s.tables[symbol]; ok {
+		pf.close()
+		delete(s.tables, symbol)
+	}
+	err := os.Remove(filepath.Join(s.dir, "prices", symbol+".bin"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove price file: %w", err)
+	}
+	return s.saveDuplicates(symbol, 0)
+}
+
+func (s *store) MarkDelisted(symbol, successor string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if successor != "" {
+		if err := database.ValidateSymbol(successor); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol = strings.ToUpper(symbol)
+	ss := s.settings[symbol]
+	ss.Symbol, ss.Successor = symbol, strings.ToUpper(successor)
+	if ss.State != database.StateRetired {
+		ss.State = database.StateDelisted
+	}
+	s.setSettings(ss)
+	return s.saveSettings()
+}
+
+func (s *store) SetSymbolConnections(symbol string, n int) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if err := database.ValidateConnections(n); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol = strings.ToUpper(symbol)
+	ss, ok := s.settings[symbol]
+	if !ok {
+		ss = database.SymbolSettings{Symbol: symbol, State: database.StatePaused}
+	}
+	ss.Connections = n
+	s.setSettings(ss)
+	return s.saveSettings()
+}
+
+func (s *store) PriceSymbols() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "prices", "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(paths))
+	for _, path := range paths {
+		symbols = append(symbols, strings.TrimSuffix(filepath.Base(path), ".bin"))
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+// table returns the open price file for symbol. Existing files are opened on
+// first use; missing ones are created only when create is set, otherwise
+// table returns nil.
+func (s *store) table(symbol string, create bool) (*priceFile, error) {
+	symbol = strings.ToUpper(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pf, ok := s.tables[symbol]; ok {
+		return pf, nil
+	}
+
+	path := filepath.Join(s.dir, "prices", symbol+".bin")
+	flags := os.O_RDWR | os.O_APPEND
+	if create {
+		flags |= os.O_CREATE
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, os.ErrNotExist) && !create {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open price file: %w", err)
+	}
+
+	pf := &priceFile{
+		f:              f,
+		duplicates:     s.savedDuplicates(symbol),
+		saveDuplicates: func(n int64) error { return s.saveDuplicates(symbol, n) },
+	}
+	if err := pf.scan(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.tables[symbol] = pf
+	return pf, nil
+}
+
+func (s *store) loadSettings() error {
+	rows, err := readCSV(filepath.Join(s.dir, "symbol_settings.csv"))
+	if err != nil {
+		return fmt.Errorf("read symbol settings: %w", err)
+	}
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		// Columns added after the first release are optional
+		row = append(row, make([]string, 6-min(len(row), 6))...)
+		connections, _ := strconv.Atoi(row[5])
+		s.setSettings(database.SymbolSettings{
+			Symbol:      row[0],
+			State:       database.SymbolState(row[1] == "1", row[2] == "1", row[3] == "1"),
+			Successor:   row[4],
+			Connections: connections,
+		})
+	}
+	return nil
+}
+
+// setSettings stores ss, deriving Enabled from its state and defaulting
+// Connections to 1. Must be called with s.mu held.
+func (s *store) setSettings(ss database.SymbolSettings) {
+	ss.Enabled = ss.State == database.StateActive
+	if ss.Connections < 1 {
+		ss.Connections = 1
+	}
+	s.settings[ss.Symbol] = ss
+}
+
+// saveSettings rewrites the settings file. Must be called with s.mu held.
+func (s *store) saveSettings() error {
+	flag := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	rows := make([][]string, 0, len(s.settings))
+	for _, ss := range s.settings {
+		rows = append(rows, []string{
+			ss.Symbol,
+			flag(ss.State == database.StateActive),
+			flag(ss.State == database.StateRetired),
+			flag(ss.State == database.StateDelisted),
+			ss.Successor,
+			strconv.Itoa(ss.Connections),
+		})
+	}
+	if err := writeCSV(filepath.Join(s.dir, "symbol_settings.csv"), rows); err != nil {
+		return fmt.Errorf("write symbol settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) SetSymbolMetadata(meta database.SymbolMetadata) error {
+	if err := database.ValidateSymbol(meta.Symbol); err != nil {
+		return err
+	}
+	meta.Symbol = strings.ToUpper(meta.Symbol)
+	meta.UpdatedAt = time.UnixMilli(meta.UpdatedAt.UnixMilli()).UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata[meta.Symbol] = meta
+	rows := make([][]string, 0, len(s.metadata))
+	for _, m := range s.metadata {
+		rows = append(rows, []string{
+			m.Symbol, m.ContractType, m.TickSize, m.StepSize,
+			strconv.Itoa(m.PricePrecision),
+			strconv.Itoa(m.QuantityPrecision),
+			strconv.FormatInt(m.UpdatedAt.UnixMilli(), 10),
+		})
+	}
+	if err := writeCSV(filepath.Join(s.dir, "symbol_metadata.csv"), rows); err != nil {
+		return fmt.Errorf("write symbol metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetSymbolMetadata() ([]database.SymbolMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metas := make([]database.SymbolMetadata, 0, len(s.metadata))
+	for _, m := range s.metadata {
+		metas = append(metas, m)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Symbol < metas[j].Symbol })
+	return metas, nil
+}
+
+func (s *store) loadMetadata() error {
+	rows, err := readCSV(filepath.Join(s.dir, "symbol_metadata.csv"))
+	if err != nil {
+		return fmt.Errorf("read symbol metadata: %w", err)
+	}
+	for _, row := range rows {
+		if len(row) < 7 {
+			continue
+		}
+		pricePrec, _ := strconv.Atoi(row[4])
+		qtyPrec, _ := strconv.Atoi(row[5])
+		ms, _ := strconv.ParseInt(row[6], 10, 64)
+		s.metadata[row[0]] = database.SymbolMetadata{
+			Symbol:            row[0],
+			ContractType:      row[1],
+			TickSize:          row[2],
+			StepSize:          row[3],
+			PricePrecision:    pricePrec,
+			QuantityPrecision: qtyPrec,
+			UpdatedAt:         time.UnixMilli(ms).UTC(),
+		}
+	}
+	return nil
+}
+
+func (s *store) loadExports() error {
+	rows, err := readCSV(filepath.Join(s.dir, "export_log.csv"))
+	if err != nil {
+		return fmt.Errorf("read export log: %w", err)
+	}
+	for _, row := range rows {
+		if len(row) < 5 {
+			continue
+		}
+		count, _ := strconv.ParseInt(row[3], 10, 64)
+		ms, _ := strconv.ParseInt(row[4], 10, 64)
+		s.addExport(database.ExportRecord{
+			Symbol:     row[0],
+			Day:        row[1],
+			Path:       row[2],
+			Rows:       count,
+			ExportedAt: time.UnixMilli(ms).UTC(),
+		})
+	}
+	return nil
+}
+
+func (s *store) loadDuplicates() error {
+	rows, err := readCSV(filepath.Join(s.dir, "duplicates.csv"))
+	if err != nil {
+		return fmt.Errorf("read duplicate counts: %w", err)
+	}
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		n, _ := strconv.ParseInt(row[1], 10, 64)
+		s.duplicates[row[0]] = n
+	}
+	// Keep only the last row of each symbol
+	if len(rows) > len(s.duplicates) {
+		rows = rows[:0]
+		for symbol, n := range s.duplicates {
+			rows = append(rows, []string{symbol, strconv.FormatInt(n, 10)})
+		}
+		if err := writeCSV(filepath.Join(s.dir, "duplicates.csv"), rows); err != nil {
+			return fmt.Errorf("write duplicate counts: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *store) savedDuplicates(symbol string) int64 {
+	s.dupMu.Lock()
+	defer s.dupMu.Unlock()
+	return s.duplicates[symbol]
+}
+
+// saveDuplicates records n as the duplicate count of symbol.
+func (s *store) saveDuplicates(symbol string, n int64) error {
+	s.dupMu.Lock()
+	defer s.dupMu.Unlock()
+
+	if s.duplicates[symbol] == n {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, "duplicates.csv"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open duplicate counts: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{symbol, strconv.FormatInt(n, 10)})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write duplicate counts: %w", err)
+	}
+	s.duplicates[symbol] = n
+	return nil
+}
+
+func (s *store) addExport(rec database.ExportRecord) {
+	days, ok := s.exports[rec.Symbol]
+	if !ok {
+		days = make(map[string]database.ExportRecord)
+		s.exports[rec.Symbol] = days
+	}
+	days[rec.Day] = rec
+}
+
+// writeCSV replaces path atomically with rows.
+func writeCSV(path string, rows [][]string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	err = errors.Join(w.Error(), f.Sync(), f.Close())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readCSV returns all rows of path, or none if it does not exist. A torn
+// final line from an interrupted append, one without its newline, is
+// ignored; a malformed row anywhere else is an error.
+func readCSV(path string) ([][]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data[:bytes.LastIndexByte(data, '\n')+1]))
+	r.FieldsPerRecord = -1
+	return r.ReadAll()
+}
+
+// priceFile is one symbol's append-only tick file and its in-memory stats.
+type priceFile struct {
+	f          *os.File
+	mu         sync.RWMutex
+	count      int64
+	firstTS    int64
+	lastTS     int64
+	lastInsert time.Time
+	ordered    int64              // leading records in (timestamp, id) order
+	maxAggID   int64              // highest aggregate id stored
+	aggRanges  []aggRange         // per aggChunk records, for duplicate lookups
+	aggChunk   int64              // index+1 of the chunk aggIDs holds, 0 if none
+	aggIDs     map[int64]struct{} // aggregate ids of the last chunk checked for a duplicate
+	duplicates int64              // dropped in total
+
+	saveDuplicates func(int64) error
+}
+
+// aggChunkSize is how many records share an aggRange.
+const aggChunkSize = 4096
+
+// aggRange is the range of nonzero aggregate ids in a chunk of records;
+// min > max if it has none.
+type aggRange struct{ min, max int64 }
+
+// addAggID widens the range of the chunk holding record i, which follows
+// the records already added.
+func addAggID(ranges []aggRange, i, aggID int64) []aggRange {
+	if i%aggChunkSize == 0 {
+		ranges = append(ranges, aggRange{math.MaxInt64, math.MinInt64})
+	}
+	if aggID != 0 {
+		r := &ranges[len(ranges)-1]
+		r.min, r.max = min(r.min, aggID), max(r.max, aggID)
+	}
+	return ranges
+}
+
+// scan recomputes the stats from the file. A partial trailing record left by
+// a crash is truncated away. Must be called with pf.mu held for writing.
+func (pf *priceFile) scan() error {
+	info, err := pf.f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat price file: %w", err)
+	}
+	size := info.Size()
+	if rem := size % recordSize; rem != 0 {
+		size -= rem
+		if err := pf.f.Truncate(size); err != nil {
+			return fmt.Errorf("truncate torn record: %w", err)
+		}
+	}
+
+	pf.count = size / recordSize
+	pf.ordered = -1
+	pf.firstTS, pf.lastTS = math.MaxInt64, math.MinInt64
+	pf.lastInsert = info.ModTime()
+	pf.maxAggID = 0
+	pf.aggRanges, pf.aggChunk, pf.aggIDs = nil, 0, nil
+
+	r := bufio.NewReaderSize(io.NewSectionReader(pf.f, 0, size), 1<<16)
+	var buf [recordSize]byte
+	var prev int64 = math.MinInt64
+	for i := int64(0); i < pf.count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return fmt.Errorf("read price file: %w", err)
+		}
+		p := decode(buf[:])
+		ts := p.Timestamp
+		pf.aggRanges = addAggID(pf.aggRanges, i, p.AggID)
+		pf.maxAggID = max(pf.maxAggID, p.AggID)
+		pf.firstTS = min(pf.firstTS, ts)
+		pf.lastTS = max(pf.lastTS, ts)
+		if ts < prev && pf.ordered < 0 {
+			pf.ordered = i
+		}
+		prev = ts
+	}
+	if pf.ordered < 0 {
+		pf.ordered = pf.count
+	}
+	return nil
+}
+
+// append writes prices as new records, dropping ticks whose aggregate id is
+// already stored. Binance ids only grow, so only a tick at or below the
+// highest stored id is looked up, in the chunks whose range holds it.
+func (pf *priceFile) append(prices []database.Price) (int64, error) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	// Saved under pf.mu, so the last row written is the latest count
+	defer func(before int64) {
+		if pf.duplicates == before {
+			return
+		}
+		if err := pf.saveDuplicates(pf.duplicates); err != nil {
+			slog.Warn("failed to save duplicate count", "file", pf.f.Name(), "error", err)
+		}
+	}(pf.duplicates)
+
+	buf := make([]byte, 0, len(prices)*recordSize)
+	added := make([]database.Price, 0, len(prices))
+	var batch map[int64]struct{} // aggregate ids of added, once needed
+	maxAggID := pf.maxAggID
+	for _, p := range prices {
+		if p.AggID != 0 {
+			if p.AggID <= maxAggID && batch == nil {
+				batch = make(map[int64]struct{}, len(prices))
+				for _, a := range added {
+					batch[a.AggID] = struct{}{}
+				}
+			}
+			if batch != nil {
+				_, dup := batch[p.AggID]
+				if !dup && p.AggID <= pf.maxAggID {
+					var err error
+					if dup, err = pf.hasAggID(p.AggID); err != nil {
+						return 0, err
+					}
+				}
+				if dup {
+					pf.duplicates++
+					continue
+				}
+				batch[p.AggID] = struct{}{}
+			}
+			maxAggID = max(maxAggID, p.AggID)
+		}
+		p.ID = pf.count + int64(len(added)) + 1
+		buf = encode(buf, p)
+		added = append(added, p)
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if _, err := pf.f.Write(buf); err != nil {
+		// Drop whatever part of the batch made it to disk
+		pf.f.Truncate(pf.count * recordSize)
+		return 0, fmt.Errorf("append prices: %w", err)
+	}
+
+	pf.maxAggID = maxAggID
+	if pf.aggChunk == int64(len(pf.aggRanges)) {
+		pf.aggChunk, pf.aggIDs = 0, nil // the cached chunk grows
+	}
+	for _, p := range added {
+		if pf.ordered == pf.count && (pf.count == 0 || p.Timestamp >= pf.lastTS) {
+			pf.ordered++
+		}
+		if pf.count == 0 {
+			pf.firstTS, pf.lastTS = p.Timestamp, p.Timestamp
+		}
+		pf.firstTS = min(pf.firstTS, p.Timestamp)
+		pf.lastTS = max(pf.lastTS, p.Timestamp)
+		pf.aggRanges = addAggID(pf.aggRanges, pf.count, p.AggID)
+		pf.count++
+	}
+	pf.lastInsert = time.Now()
+	return int64(len(added)), nil
+}
+
+// hasAggID reports whether a record with aggregate id id is stored, reading
+// only chunks whose range holds it. The ids of the last such chunk are kept,
+// since duplicates tend to arrive in runs. Must be called with pf.mu held
+// for writing.
+func (pf *priceFile) hasAggID(id int64) (bool, error) {
+	for c := len(pf.aggRanges) - 1; c >= 0; c-- {
+		if r := pf.aggRanges[c]; id < r.min || id > r.max {
+			continue
+		}
+		if pf.aggChunk != int64(c+1) {
+			start := int64(c) * aggChunkSize
+			n := min(aggChunkSize, pf.count-start)
+			buf := make([]byte, n*recordSize)
+			if _, err := pf.f.ReadAt(buf, start*recordSize); err != nil {
+				return false, fmt.Errorf("read price file: %w", err)
+			}
+			pf.aggIDs = make(map[int64]struct{}, n)
+			for i := int64(0); i < n; i++ {
+				pf.aggIDs[decode(buf[i*recordSize:]).AggID] = struct{}{}
+			}
+			pf.aggChunk = int64(c + 1)
+		}
+		if _, ok := pf.aggIDs[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (pf *priceFile) stats(stats database.SymbolStats) database.SymbolStats {
+	pf.mu.RLock()
+	defer pf.mu.RUnlock()
+
+	stats.Count = pf.count
+	stats.Duplicates = pf.duplicates
+	if pf.count > 0 {
+		first := time.UnixMilli(pf.firstTS).UTC()
+		last := time.UnixMilli(pf.lastTS).UTC()
+		stats.FirstTS, stats.LastTS = &first, &last
+		insert := pf.lastInsert.UTC()
+		stats.LastInsertAt = &insert
+	}
+	return stats
+}
+
+// query reads the ordered prefix by binary search and scans the unordered
+// tail, if any, sorting its matches in memory. It never rewrites the file;
+// Compact sorts the tail into place.
+func (pf *priceFile) query(q database.PriceQuery) ([]database.Price, error) {
+	pf.mu.RLock()
+	defer pf.mu.RUnlock()
+
+	prices, err := pf.queryOrdered(q)
+	if err != nil || pf.ordered == pf.count {
+		return prices, err
+	}
+	tail, err := pf.queryTail(q)
+	if err != nil {
+		return nil, err
+	}
+	return mergePrices(prices, tail, q.Limit), nil
+}
+
+// queryOrdered pages through the leading records in (timestamp, id) order.
+func (pf *priceFile) queryOrdered(q database.PriceQuery) ([]database.Price, error) {
+	from, match := q.Start(), q.Includes
+
+	var searchErr error
+	start := sort.Search(int(pf.ordered), func(i int) bool {
+		p, err := pf.read(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return p.Timestamp > from || (p.Timestamp == from && match(p))
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	prices := make([]database.Price, 0, q.Limit)
+	r := bufio.NewReader(io.NewSectionReader(pf.f, int64(start)*recordSize, (pf.ordered-int64(start))*recordSize))
+	var buf [recordSize]byte
+	for len(prices) < q.Limit {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("read price file: %w", err)
+		}
+		p := decode(buf[:])
+		if p.Timestamp >= q.To {
+			break
+		}
+		if match(p) {
+			prices = append(prices, p)
+		}
+	}
+	return prices, nil
+}
+
+// queryTail returns the first q.Limit matches among the records after the
+// ordered prefix, in (timestamp, id) order.
+func (pf *priceFile) queryTail(q database.PriceQuery) ([]database.Price, error) {
+	var prices []database.Price
+	keep := func() {
+		sort.Slice(prices, func(i, j int) bool { return less(prices[i], prices[j]) })
+		if len(prices) > q.Limit {
+			prices = prices[:q.Limit]
+		}
+	}
+
+	n := pf.count - pf.ordered
+	r := bufio.NewReaderSize(io.NewSectionReader(pf.f, pf.ordered*recordSize, n*recordSize), 1<<16)
+	var buf [recordSize]byte
+	for i := int64(0); i < n; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, fmt.Errorf("read price file: %w", err)
+		}
+		if p := decode(buf[:]); q.Includes(p) {
+			prices = append(prices, p)
+			if len(prices) >= 2*q.Limit {
+				keep()
+			}
+		}
+	}
+	keep()
+	return prices, nil
+}
+
+// mergePrices merges a and b, each in (timestamp, id) order, up to limit.
+func mergePrices(a, b []database.Price, limit int) []database.Price {
+	prices := make([]database.Price, 0, min(len(a)+len(b), limit))
+	for len(prices) < limit && (len(a) > 0 || len(b) > 0) {
+		if len(b) == 0 || (len(a) > 0 && less(a[0], b[0])) {
+			prices, a = append(prices, a[0]), a[1:]
+		} else {
+			prices, b = append(prices, b[0]), b[1:]
+		}
+	}
+	return prices
+}
+
+func (pf *priceFile) read(i int64) (database.Price, error) {
+	var buf [recordSize]byte
+	if _, err := pf.f.ReadAt(buf[:], i*recordSize); err != nil {
+		return database.Price{}, fmt.Errorf("read price file: %w", err)
+	}
+	return decode(buf[:]), nil
+}
+
+func (pf *priceFile) close() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	return errors.Join(pf.f.Sync(), pf.f.Close())
+}
+
+func encode(buf []byte, p database.Price) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(p.ID))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(p.AggID))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(p.Timestamp))
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.Price))
+}
+
+func decode(buf []byte) database.Price {
+	return database.Price{
+		ID:        int64(binary.LittleEndian.Uint64(buf[0:])),
+		AggID:     int64(binary.LittleEndian.Uint64(buf[8:])),
+		Timestamp: int64(binary.LittleEndian.Uint64(buf[16:])),
+		Price:     math.Float64frombits(binary.LittleEndian.Uint64(buf[24:])),
+	}
+}
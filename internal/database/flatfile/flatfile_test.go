@@ -0,0 +1,214 @@
+package flatfile
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/database/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		store, err := Open(t.TempDir())
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return store
+	})
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	s.SetSymbolEnabled("BTCUSDT", true)
+	s.EnsurePriceTable("BTCUSDT")
+	for i := int64(1); i <= 3; i++ {
+		if err := s.InsertPrice("BTCUSDT", i, i*1000, 1); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+	s.Close()
+
+	// Simulate a crash in the middle of an append
+	f, _ := os.OpenFile(filepath.Join(dir, "prices", "BTCUSDT.bin"), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	settings, _ := s.GetSymbolSettings()
+	if len(settings) != 1 || !settings[0].Enabled {
+		t.Errorf("settings not persisted: %+v", settings)
+	}
+	stats, err := s.GetStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Count != 3 || stats.LastTS.UnixMilli() != 3000 {
+		t.Errorf("unexpected stats after reopen: %+v", stats)
+	}
+
+	// Ids continue after the existing records and aggregate ids stay unique
+	n, err := s.InsertPrices("BTCUSDT", []database.Price{{AggID: 3, Timestamp: 3000}, {AggID: 4, Timestamp: 4000}})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertPrices = %d, %v", n, err)
+	}
+	prices, _ := s.GetPrices("BTCUSDT", database.PriceQuery{To: math.MaxInt64})
+	if len(prices) != 4 || prices[3].ID != 4 || prices[3].AggID != 4 {
+		t.Errorf("unexpected prices: %+v", prices)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export_log.csv")
+	os.WriteFile(path, []byte("BTCUSDT,2024-01-01,a.parquet,1,1\nBTCUSDT,2024-01-02,\"b.par"), 0644)
+	rows, err := readCSV(path)
+	if err != nil || len(rows) != 1 {
+		t.Errorf("expected the torn final line to be ignored, got %v, %v", rows, err)
+	}
+
+	os.WriteFile(path, []byte("BTCUSDT,2024-01-01,\"a.par\nBTCUSDT,2024-01-02,b.parquet,1,1\n"), 0644)
+	if rows, err := readCSV(path); err == nil {
+		t.Errorf("expected an error for a malformed row before the end, got %v", rows)
+	}
+}
+
+func TestOutOfOrderAppendsAreSorted(t *testing.T) {
+	defer func(n int64) { sortRun = n }(sortRun)
+	sortRun = 2 // several runs to merge
+
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	s.EnsurePriceTable("BTCUSDT")
+	for i, ts := range []int64{5000, 6000, 1000, 7000, 2000, 3000} {
+		if err := s.InsertPrice("BTCUSDT", int64(i+1), ts, 1); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+
+	timestamps := func() []int64 {
+		var got []int64
+		it := database.NewPriceIterator(s, "BTCUSDT", math.MinInt64, math.MaxInt64, 2)
+		for it.Next() {
+			got = append(got, it.Price().Timestamp)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("iterator failed: %v", err)
+		}
+		return got
+	}
+	if got := fmt.Sprint(timestamps()); got != "[1000 2000 3000 5000 6000 7000]" {
+		t.Fatalf("unexpected order %s", got)
+	}
+
+	// Reads sort the unordered tail in memory and leave the file alone
+	pf, _ := s.(*store).table("BTCUSDT", false)
+	if pf.ordered != 2 {
+		t.Errorf("expected reads not to rewrite the file, got %d of %d records ordered", pf.ordered, pf.count)
+	}
+
+	// Compaction rewrites it in order, so reads binary search it again
+	if n, err := s.(*store).Compact(); err != nil || n != 1 {
+		t.Fatalf("Compact = %d, %v", n, err)
+	}
+	if n, err := s.(*store).Compact(); err != nil || n != 0 {
+		t.Errorf("expected a second Compact to rewrite nothing, got %d, %v", n, err)
+	}
+	if got := fmt.Sprint(timestamps()); got != "[1000 2000 3000 5000 6000 7000]" {
+		t.Fatalf("unexpected order after compaction %s", got)
+	}
+	if pf.ordered != pf.count {
+		t.Errorf("expected all %d records ordered, got %d", pf.count, pf.ordered)
+	}
+	if err := s.InsertPrice("BTCUSDT", 7, 8000, 1); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+	if pf.ordered != 7 {
+		t.Errorf("expected an in-order append to stay ordered, got %d of %d", pf.ordered, pf.count)
+	}
+	s.Close()
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "prices"))
+	if len(entries) != 1 {
+		t.Errorf("expected only the price file to remain, got %v", entries)
+	}
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	if got := fmt.Sprint(timestamps()); got != "[1000 2000 3000 5000 6000 7000 8000]" {
+		t.Errorf("unexpected order after reopen %s", got)
+	}
+	if dups, _ := s.InsertPrices("BTCUSDT", []database.Price{{AggID: 3, Timestamp: 1000}}); dups != 0 {
+		t.Errorf("expected the sorted file to still drop duplicates")
+	}
+}
+
+func TestDuplicateChecksAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	s.EnsurePriceTable("BTCUSDT")
+	prices := make([]database.Price, 2*aggChunkSize+10)
+	for i := range prices {
+		prices[i] = database.Price{AggID: int64(2 * (i + 1)), Timestamp: int64(i), Price: 1}
+	}
+	if _, err := s.InsertPrices("BTCUSDT", prices); err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	s.Close()
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	n, err := s.InsertPrices("BTCUSDT", []database.Price{
+		{AggID: 2, Timestamp: 0},                      // first chunk
+		{AggID: 3, Timestamp: 1},                      // a gap in the first chunk
+		{AggID: 3, Timestamp: 1},                      // within the batch
+		{AggID: 2 * (aggChunkSize + 5), Timestamp: 2}, // second chunk
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertPrices = %d, %v", n, err)
+	}
+	if stats, _ := s.GetStats("BTCUSDT"); stats.Duplicates != 3 {
+		t.Errorf("expected 3 duplicates, got %d", stats.Duplicates)
+	}
+	pf, _ := s.(*store).table("BTCUSDT", false)
+	if len(pf.aggIDs) > aggChunkSize {
+		t.Errorf("expected at most one chunk of aggregate ids in memory, got %d", len(pf.aggIDs))
+	}
+
+	// Duplicate counts survive a reopen
+	s.InsertPrices("BTCUSDT", []database.Price{{AggID: 4, Timestamp: 1}})
+	s.Close()
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	if stats, _ := s.GetStats("BTCUSDT"); stats.Duplicates != 4 {
+		t.Errorf("expected 4 duplicates after reopen, got %d", stats.Duplicates)
+	}
+	if rows, _ := readCSV(filepath.Join(dir, "duplicates.csv")); len(rows) != 1 {
+		t.Errorf("expected the duplicate counts to be compacted to one row, got %v", rows)
+	}
+}
@@ -0,0 +1,238 @@
+package flatfile
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"binance-tick-store/internal/database"
+)
+
+// sortRun is how many records of the unordered tail are sorted in memory at
+// a time when a price file is put back in order. A variable for tests.
+var sortRun int64 = 1 << 20
+
+// Compact sorts the records appended out of timestamp order, as by
+// imports, back into every price file, so reads go back to a single binary
+// search. Each file is rewritten while inserts to it wait. It returns the
+// number of files rewritten.
+func (s *store) Compact() (int, error) {
+	symbols, err := s.PriceSymbols()
+	if err != nil {
+		return 0, fmt.Errorf("list price files: %w", err)
+	}
+
+	var n int
+	for _, symbol := range symbols {
+		pf, err := s.table(symbol, false)
+		if err != nil {
+			return n, err
+		}
+		if pf == nil {
+			continue // retired meanwhile
+		}
+		rewritten, err := pf.compact()
+		if err != nil {
+			return n, fmt.Errorf("compact %s: %w", symbol, err)
+		}
+		if rewritten {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// compact restores the order of the file if anything was appended out of
+// order, and reports whether it did.
+func (pf *priceFile) compact() (bool, error) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if pf.ordered == pf.count {
+		return false, nil
+	}
+	return true, pf.restoreOrder()
+}
+
+// restoreOrder rewrites the file in (timestamp, id) order. Only the records
+// after the ordered prefix are sorted, in runs of sortRun records spilled to
+// temporary files, and merged with the prefix, so memory stays bounded. The
+// new file replaces the old one by rename. Must be called with pf.mu held
+// for writing.
+func (pf *priceFile) restoreOrder() error {
+	path := pf.f.Name()
+	dir := filepath.Dir(path)
+
+	var runs []*os.File
+	defer func() {
+		for _, run := range runs {
+			run.Close()
+			os.Remove(run.Name())
+		}
+	}()
+	for start := pf.ordered; start < pf.count; start += sortRun {
+		n := min(sortRun, pf.count-start)
+		run, err := pf.spillRun(dir, start, n)
+		if run != nil {
+			runs = append(runs, run)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := os.CreateTemp(dir, filepath.Base(path)+".sorting*")
+	if err != nil {
+		return fmt.Errorf("create sorted price file: %w", err)
+	}
+	defer os.Remove(out.Name()) // fails harmlessly once renamed
+
+	sources := []io.Reader{io.NewSectionReader(pf.f, 0, pf.ordered*recordSize)}
+	for _, run := range runs {
+		sources = append(sources, run)
+	}
+	// Records move, so the aggregate id ranges are rebuilt
+	var ranges []aggRange
+	var i int64
+	w := bufio.NewWriterSize(out, 1<<16)
+	err = mergeRecords(w, sources, func(p database.Price) {
+		ranges = addAggID(ranges, i, p.AggID)
+		i++
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write sorted price file: %w", err)
+	}
+
+	// Opened before the rename, so appends never go to the replaced file
+	f, err := os.OpenFile(out.Name(), os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open sorted price file: %w", err)
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		f.Close()
+		return fmt.Errorf("replace price file: %w", err)
+	}
+	pf.f.Close()
+	pf.f = f
+	pf.ordered = pf.count
+	pf.aggRanges, pf.aggChunk, pf.aggIDs = ranges, 0, nil
+	return nil
+}
+
+// spillRun sorts the n records from position start into a temporary file in
+// dir, positioned at its start.
+func (pf *priceFile) spillRun(dir string, start, n int64) (*os.File, error) {
+	buf := make([]byte, n*recordSize)
+	if _, err := pf.f.ReadAt(buf, start*recordSize); err != nil {
+		return nil, fmt.Errorf("read price file: %w", err)
+	}
+	prices := make([]database.Price, n)
+	for i := range prices {
+		prices[i] = decode(buf[i*recordSize:])
+	}
+	sort.Slice(prices, func(i, j int) bool { return less(prices[i], prices[j]) })
+
+	buf = buf[:0]
+	for _, p := range prices {
+		buf = encode(buf, p)
+	}
+	run, err := os.CreateTemp(dir, "run*")
+	if err != nil {
+		return nil, fmt.Errorf("create sort run: %w", err)
+	}
+	if _, err := run.Write(buf); err != nil {
+		return run, fmt.Errorf("write sort run: %w", err)
+	}
+	if _, err := run.Seek(0, io.SeekStart); err != nil {
+		return run, fmt.Errorf("rewind sort run: %w", err)
+	}
+	return run, nil
+}
+
+func less(a, b database.Price) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return a.ID < b.ID
+}
+
+// mergeRecords writes the records of sources, each in (timestamp, id) order,
+// to w in that order, passing each to visit.
+func mergeRecords(w io.Writer, sources []io.Reader, visit func(database.Price)) error {
+	var h recordHeap
+	for _, src := range sources {
+		c := &cursor{r: bufio.NewReaderSize(src, 1<<16)}
+		ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	buf := make([]byte, 0, recordSize)
+	for h.Len() > 0 {
+		c := h[0]
+		if _, err := w.Write(encode(buf[:0], c.p)); err != nil {
+			return err
+		}
+		visit(c.p)
+		ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// cursor is the current record of a merge source.
+type cursor struct {
+	r   *bufio.Reader
+	buf [recordSize]byte
+	p   database.Price
+}
+
+func (c *cursor) next() (bool, error) {
+	if _, err := io.ReadFull(c.r, c.buf[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("read price file: %w", err)
+	}
+	c.p = decode(c.buf[:])
+	return true, nil
+}
+
+type recordHeap []*cursor
+
+func (h recordHeap) Len() int           { return len(h) }
+func (h recordHeap) Less(i, j int) bool { return less(h[i].p, h[j].p) }
+func (h recordHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *recordHeap) Push(x any)        { *h = append(*h, x.(*cursor)) }
+func (h *recordHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// IntegrityCheck runs PRAGMA integrity_check on a read connection. It reads
+// every page of the database, so it takes a while on large files, but does
+// not block tick inserts.
+func (s *store) IntegrityCheck() ([]string, error) {
+	rows, err := s.reader.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		result = append(result, line)
+	}
+	return result, rows.Err()
+}
+
+// QuickCheck opens the database file at path read-only, without the
+// migrations and WAL setup of Open, and runs PRAGMA quick_check on it. It
+// returns an error if the file cannot be read or the check reports a
+// problem. It does not modify the file.
+func QuickCheck(path string) error {
+	db, err := sql.Open("sqlite", fileDSN(path, "mode=ro&"+busyTimeout+"&_pragma=query_only(1)"))
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("quick check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("scan row: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("quick check: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("quick check: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
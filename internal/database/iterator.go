@@ -0,0 +1,72 @@
+package database
+
+// DefaultPageSize is the number of rows fetched per GetPrices call when the
+// query does not set a limit.
+const DefaultPageSize = 5000
+
+// PriceIterator walks a symbol's ticks in [from, to) in timestamp order.
+// Rows are fetched page by page, so memory stays bounded and no read
+// transaction stays open for the whole walk.
+type PriceIterator struct {
+	store  Store
+	symbol string
+	query  PriceQuery
+	page   []Price
+	pos    int
+	done   bool
+	err    error
+}
+
+// NewPriceIterator creates an iterator over ticks with from <= timestamp < to.
+func NewPriceIterator(store Store, symbol string, from, to int64, pageSize int) *PriceIterator {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &PriceIterator{
+		store:  store,
+		symbol: symbol,
+		query:  PriceQuery{From: from, To: to, Limit: pageSize},
+	}
+}
+
+// Next advances to the next tick. It returns false when the range is
+// exhausted or an error occurred; check Err afterwards.
+func (it *PriceIterator) Next() bool {
+	if it.pos+1 < len(it.page) {
+		it.pos++
+		return true
+	}
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if len(it.page) > 0 {
+		last := it.page[len(it.page)-1]
+		it.query.After = &last
+	}
+	page, err := it.store.GetPrices(it.symbol, it.query)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if len(page) < it.query.Limit {
+		it.done = true
+	}
+	if len(page) == 0 {
+		return false
+	}
+
+	it.page = page
+	it.pos = 0
+	return true
+}
+
+// Price returns the current tick.
+func (it *PriceIterator) Price() Price {
+	return it.page[it.pos]
+}
+
+// Err returns the first error encountered while fetching pages.
+func (it *PriceIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Symbol lifecycle states. Active symbols are captured, paused ones keep
+// their ticks but are not captured, delisted ones are paused because the
+// exchange no longer lists them, and retired ones have had their ticks
+// dropped. Enabling a symbol in any state makes it active again.
+const (
+	StateActive   = "active"
+	StatePaused   = "paused"
+	StateDelisted = "delisted"
+	StateRetired  = "retired"
+)
+
+// SymbolState derives the lifecycle state from the stored flags.
+func SymbolState(enabled, retired, delisted bool) string {
+	switch {
+	case retired:
+		return StateRetired
+	case delisted:
+		return StateDelisted
+	case enabled:
+		return StateActive
+	default:
+		return StatePaused
+	}
+}
+
+// Retirer is implemented by stores that can drop a symbol's ticks.
+type Retirer interface {
+	// RetireSymbol marks symbol retired, which stops its capture, and drops
+	// its ticks and stats. The export log is kept.
+	RetireSymbol(symbol string) error
+	// PriceSymbols lists every symbol with stored ticks, whether or not it
+	// has a settings row.
+	PriceSymbols() ([]string, error)
+}
+
+// Delister is implemented by stores that can record delisted symbols.
+type Delister interface {
+	// MarkDelisted stops capture of symbol and records that the exchange no
+	// longer lists it. successor is the symbol it continues as, or empty.
+	MarkDelisted(symbol, successor string) error
+}
+
+// Predecessors returns the symbols that were renamed to symbol, directly or
+// through a chain of renames, oldest last.
+func Predecessors(settings []SymbolSettings, symbol string) []string {
+	var chain []string
+	seen := map[string]bool{symbol: true}
+	for next := []string{symbol}; len(next) > 0; {
+		var found []string
+		for _, s := range settings {
+			if s.Successor != "" && slices.Contains(next, s.Successor) && !seen[s.Symbol] {
+				seen[s.Symbol] = true
+				found = append(found, s.Symbol)
+			}
+		}
+		sort.Strings(found)
+		chain = append(chain, found...)
+		next = found
+	}
+	return chain
+}
+
+// Orphans returns the symbols in tables that have no settings row.
+func Orphans(tables []string, settings []SymbolSettings) []string {
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.Symbol] = true
+	}
+	var orphans []string
+	for _, symbol := range tables {
+		if !known[symbol] {
+			orphans = append(orphans, symbol)
+		}
+	}
+	return orphans
+}
+
+func (s *store) RetireSymbol(symbol string) error {
+	if err := ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	symbol = strings.ToUpper(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stmt, ok := s.stmts[symbol]; ok {
+		stmt.Close()
+		delete(s.stmts, symbol)
+	}
+
+	tx, err := s.writer.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, retired) VALUES (?, 0, 1)
+		ON CONFLICT(symbol) DO UPDATE SET enabled = 0, retired = 1
+	`, symbol)
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	// Dropping the table drops its indexes and stats trigger with it
+	if _, err := tx.Exec("DROP TABLE IF EXISTS " + priceTableName(symbol)); err != nil {
+		return fmt.Errorf("drop price table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM symbol_stats WHERE symbol = ?", symbol); err != nil {
+		return fmt.Errorf("delete stats: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *store) MarkDelisted(symbol, successor string) error {
+	if err := ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if successor != "" {
+		if err := ValidateSymbol(successor); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.writer.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, delisted, successor) VALUES (?, 0, 1, ?)
+		ON CONFLICT(symbol) DO UPDATE SET enabled = 0, delisted = 1, successor = excluded.successor
+	`, strings.ToUpper(symbol), strings.ToUpper(successor))
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) PriceSymbols() ([]string, error) {
+	rows, err := s.reader.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name LIKE 'prices\_%' ESCAPE '\'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list price tables: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		symbols = append(symbols, strings.TrimPrefix(name, "prices_"))
+	}
+	return symbols, rows.Err()
+}
@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Maintainer is implemented by stores that need periodic housekeeping.
+type Maintainer interface {
+	Checkpoint() (CheckpointResult, error)
+	IncrementalVacuum(maxBytes int64) (int64, error)
+	Analyze() error
+	MaintenanceInfo() (MaintenanceInfo, error)
+}
+
+// Compactor is implemented by stores whose files need rewriting now and
+// then, work their reads leave to it. Compact returns how many files it
+// rewrote.
+type Compactor interface {
+	Compact() (int, error)
+}
+
+// StartCompaction compacts store every interval in the background until ctx
+// is cancelled.
+func StartCompaction(ctx context.Context, store Compactor, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := store.Compact(); err != nil {
+					slog.Error("compaction failed", "error", err)
+				} else if n > 0 {
+					slog.Info("compaction", "files", n)
+				}
+			}
+		}
+	}()
+}
+
+// CheckpointResult is the outcome of a WAL checkpoint. Busy means readers
+// kept the WAL from being fully reset; the next checkpoint retries.
+type CheckpointResult struct {
+	Busy         bool
+	Frames       int64
+	Checkpointed int64
+}
+
+// MaintenanceInfo describes the database files and when each maintenance
+// task last ran in this process.
+type MaintenanceInfo struct {
+	WALSize        int64
+	FreeBytes      int64 // size of pages on the freelist
+	AutoVacuum     string
+	LastCheckpoint time.Time
+	LastVacuum     time.Time
+	LastAnalyze    time.Time
+}
+
+// maintenance tracks when the store last ran each task.
+type maintenance struct {
+	mu             sync.Mutex
+	lastCheckpoint time.Time
+	lastVacuum     time.Time
+	lastAnalyze    time.Time
+}
+
+func (m *maintenance) set(field *time.Time) {
+	m.mu.Lock()
+	*field = time.Now()
+	m.mu.Unlock()
+}
+
+// Checkpoint copies the WAL into the database and truncates it. Inserts
+// wait for it; readers are not interrupted, but one that is active keeps the
+// WAL from being truncated.
+func (s *store) Checkpoint() (CheckpointResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var busy int
+	var res CheckpointResult
+	err := s.writer.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &res.Frames, &res.Checkpointed)
+	if err != nil {
+		return res, fmt.Errorf("wal checkpoint: %w", err)
+	}
+	res.Busy = busy != 0
+	s.maint.set(&s.maint.lastCheckpoint)
+	return res, nil
+}
+
+// IncrementalVacuum returns up to maxBytes of free pages to the file system
+// (all of them if maxBytes <= 0) and reports how many bytes were released.
+// It needs auto_vacuum=INCREMENTAL; databases created before that was the
+// default must be converted once with FullVacuum.
+func (s *store) IncrementalVacuum(maxBytes int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pageSize, freeBefore, err := pageCounts(s.writer)
+	if err != nil {
+		return 0, err
+	}
+	pages := int64(0)
+	if maxBytes > 0 {
+		pages = max(maxBytes/pageSize, 1)
+	}
+	// The pragma frees one page per step, so its rows must be drained
+	rows, err := s.writer.Query(fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return 0, fmt.Errorf("incremental vacuum: %w", err)
+	}
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("incremental vacuum: %w", err)
+	}
+	_, freeAfter, err := pageCounts(s.writer)
+	if err != nil {
+		return 0, err
+	}
+	s.maint.set(&s.maint.lastVacuum)
+	return (freeBefore - freeAfter) * pageSize, nil
+}
+
+// FullVacuum rebuilds the database and switches it to incremental
+// auto-vacuum. It rewrites the whole file while blocking every insert, so it
+// is meant for the command line with capture stopped.
+func (s *store) FullVacuum() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writer.Exec("PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		return fmt.Errorf("set auto_vacuum: %w", err)
+	}
+	if _, err := s.writer.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	s.maint.set(&s.maint.lastVacuum)
+	return nil
+}
+
+// Analyze refreshes the query planner statistics. analysis_limit bounds the
+// rows sampled per index so it stays fast on large tables.
+func (s *store) Analyze() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writer.Exec("PRAGMA analysis_limit = 1000"); err != nil {
+		return fmt.Errorf("set analysis_limit: %w", err)
+	}
+	if _, err := s.writer.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+	s.maint.set(&s.maint.lastAnalyze)
+	return nil
+}
+
+func (s *store) MaintenanceInfo() (MaintenanceInfo, error) {
+	var info MaintenanceInfo
+
+	fi, err := os.Stat(s.path + "-wal")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return info, fmt.Errorf("stat wal: %w", err)
+	}
+	if fi != nil {
+		info.WALSize = fi.Size()
+	}
+
+	pageSize, free, err := pageCounts(s.reader)
+	if err != nil {
+		return info, err
+	}
+	info.FreeBytes = free * pageSize
+
+	var mode int
+	if err := s.reader.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return info, fmt.Errorf("query auto_vacuum: %w", err)
+	}
+	info.AutoVacuum = [...]string{"none", "full", "incremental"}[mode%3]
+
+	s.maint.mu.Lock()
+	info.LastCheckpoint = s.maint.lastCheckpoint
+	info.LastVacuum = s.maint.lastVacuum
+	info.LastAnalyze = s.maint.lastAnalyze
+	s.maint.mu.Unlock()
+	return info, nil
+}
+
+func pageCounts(db *sql.DB) (pageSize, free int64, err error) {
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, 0, fmt.Errorf("query page_size: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA freelist_count").Scan(&free); err != nil {
+		return 0, 0, fmt.Errorf("query freelist_count: %w", err)
+	}
+	return pageSize, free, nil
+}
+
+// MaintenanceOptions schedules the maintenance tasks. Each task runs when
+// its interval has passed since it last ran or, for checkpoint and vacuum,
+// as soon as its size threshold is exceeded. A zero interval or threshold
+// disables that trigger.
+type MaintenanceOptions struct {
+	CheckInterval      time.Duration // how often thresholds are checked
+	CheckpointInterval time.Duration
+	WALLimit           int64 // checkpoint once the WAL grows past this many bytes
+	VacuumInterval     time.Duration
+	FreeLimit          int64 // vacuum once free pages exceed this many bytes
+	VacuumStep         int64 // bytes released per vacuum, 0 for all
+	AnalyzeInterval    time.Duration
+}
+
+// MaintenanceScheduler runs checkpoint, vacuum and analyze on a store.
+type MaintenanceScheduler struct {
+	store Maintainer
+	opts  MaintenanceOptions
+	now   func() time.Time
+
+	lastCheckpoint time.Time
+	lastVacuum     time.Time
+	lastAnalyze    time.Time
+	warnedVacuum   bool
+}
+
+// NewMaintenanceScheduler creates a scheduler. Intervals are measured from
+// its creation.
+func NewMaintenanceScheduler(store Maintainer, opts MaintenanceOptions) *MaintenanceScheduler {
+	now := time.Now()
+	return &MaintenanceScheduler{
+		store:          store,
+		opts:           opts,
+		now:            time.Now,
+		lastCheckpoint: now,
+		lastVacuum:     now,
+		lastAnalyze:    now,
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+func (m *MaintenanceScheduler) Start(ctx context.Context) {
+	if m.opts.CheckInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.opts.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce runs every task that is due.
+func (m *MaintenanceScheduler) RunOnce(ctx context.Context) {
+	info, err := m.store.MaintenanceInfo()
+	if err != nil {
+		slog.Error("failed to read maintenance info", "error", err)
+		return
+	}
+	now := m.now()
+
+	if due(now, m.lastCheckpoint, m.opts.CheckpointInterval) || over(info.WALSize, m.opts.WALLimit) {
+		m.lastCheckpoint = now
+		res, err := m.store.Checkpoint()
+		if err != nil {
+			slog.Error("wal checkpoint failed", "error", err)
+		} else {
+			slog.Info("wal checkpoint", "wal_bytes", info.WALSize, "frames", res.Frames, "busy", res.Busy)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	if due(now, m.lastVacuum, m.opts.VacuumInterval) || over(info.FreeBytes, m.opts.FreeLimit) {
+		m.lastVacuum = now
+		if info.AutoVacuum != "incremental" {
+			if !m.warnedVacuum {
+				slog.Warn("incremental vacuum unavailable; run `server maintain -full-vacuum` once with capture stopped",
+					"auto_vacuum", info.AutoVacuum, "free_bytes", info.FreeBytes)
+				m.warnedVacuum = true
+			}
+		} else if released, err := m.store.IncrementalVacuum(m.opts.VacuumStep); err != nil {
+			slog.Error("incremental vacuum failed", "error", err)
+		} else {
+			slog.Info("incremental vacuum", "released_bytes", released)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	if due(now, m.lastAnalyze, m.opts.AnalyzeInterval) {
+		m.lastAnalyze = now
+		if err := m.store.Analyze(); err != nil {
+			slog.Error("analyze failed", "error", err)
+		} else {
+			slog.Info("analyze complete")
+		}
+	}
+}
+
+func due(now, last time.Time, interval time.Duration) bool {
+	return interval > 0 && now.Sub(last) >= interval
+}
+
+func over(size, limit int64) bool {
+	return limit > 0 && size > limit
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	store := s.(*store)
+
+	store.EnsurePriceTable("BTCUSDT")
+	prices := make([]Price, 20000)
+	for i := range prices {
+		prices[i] = Price{AggID: int64(i + 1), Timestamp: int64(i), Price: 1}
+	}
+	if _, err := store.InsertPrices("BTCUSDT", prices); err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	if _, err := store.writer.Exec("DELETE FROM prices_BTCUSDT WHERE id > 1000"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	info, err := store.MaintenanceInfo()
+	if err != nil {
+		t.Fatalf("MaintenanceInfo failed: %v", err)
+	}
+	if info.WALSize == 0 || info.FreeBytes == 0 || info.AutoVacuum != "incremental" {
+		t.Fatalf("expected WAL data and free pages, got %+v", info)
+	}
+
+	// Thresholds trigger checkpoint and vacuum; analyze waits for its interval
+	m := NewMaintenanceScheduler(store, MaintenanceOptions{
+		WALLimit:        1,
+		FreeLimit:       1,
+		AnalyzeInterval: time.Hour,
+	})
+	m.RunOnce(context.Background())
+
+	after, err := store.MaintenanceInfo()
+	if err != nil {
+		t.Fatalf("MaintenanceInfo failed: %v", err)
+	}
+	if after.LastCheckpoint.IsZero() || after.LastVacuum.IsZero() || !after.LastAnalyze.IsZero() {
+		t.Errorf("unexpected task times: %+v", after)
+	}
+	if after.FreeBytes != 0 {
+		t.Errorf("expected free pages released, %d bytes left", after.FreeBytes)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	m.RunOnce(context.Background())
+	if after, _ := store.MaintenanceInfo(); after.LastAnalyze.IsZero() {
+		t.Error("expected analyze to run once its interval passed")
+	}
+
+	res, err := store.Checkpoint()
+	if err != nil || res.Busy {
+		t.Fatalf("Checkpoint = %+v, %v", res, err)
+	}
+	if info, _ := store.MaintenanceInfo(); info.WALSize != 0 {
+		t.Errorf("expected truncated WAL, got %d bytes", info.WALSize)
+	}
+	if count, _ := store.GetCount("BTCUSDT"); count != 20000 {
+		t.Errorf("maintenance must not touch stats, got %d", count)
+	}
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SymbolMetadata describes a contract as listed by the exchange. Sizes are
+// kept as the exchange quotes them ("0.10") so no precision is lost.
+type SymbolMetadata struct {
+	Symbol            string
+	ContractType      string // PERPETUAL, CURRENT_QUARTER, ...
+	TickSize          string // minimum price increment
+	StepSize          string // minimum quantity increment
+	PricePrecision    int
+	QuantityPrecision int
+	UpdatedAt         time.Time
+}
+
+// MetadataStore is implemented by stores that keep contract metadata.
+type MetadataStore interface {
+	SetSymbolMetadata(meta SymbolMetadata) error
+	GetSymbolMetadata() ([]SymbolMetadata, error)
+}
+
+// TickDecimals is the number of decimals in the tick size: prices scaled by
+// 10^TickDecimals are whole numbers. It is -1 if the tick size is unknown.
+func (m SymbolMetadata) TickDecimals() int {
+	d, _, ok := parseTickSize(m.TickSize)
+	if !ok {
+		return -1
+	}
+	return d
+}
+
+// PriceDecimals is the number of decimals prices are shown with: the price
+// precision, widened if the tick size needs more. It is -1 if the tick size
+// is unknown.
+func (m SymbolMetadata) PriceDecimals() int {
+	d := m.TickDecimals()
+	if d < 0 {
+		return -1
+	}
+	return max(d, m.PricePrecision)
+}
+
+// FormatPrice formats price with PriceDecimals decimals, or as few as
+// needed if the metadata is unknown.
+func (m SymbolMetadata) FormatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', m.PriceDecimals(), 64)
+}
+
+// OnTick reports whether price is a whole multiple of the tick size. Every
+// price is on tick if the tick size is unknown.
+func (m SymbolMetadata) OnTick(price float64) bool {
+	d, tick, ok := parseTickSize(m.TickSize)
+	if !ok {
+		return true
+	}
+	scaled := price * math.Pow10(d)
+	units := math.Round(scaled)
+	// Allow for the float error of the scaling itself
+	if math.Abs(scaled-units) > 1e-9*math.Max(1, math.Abs(scaled)) || math.Abs(units) > 1<<62 {
+		return false
+	}
+	return int64(units)%tick == 0
+}
+
+// parseTickSize splits a tick size such as "0.010" into its decimals (2)
+// and its value in units of 10^-decimals (1).
+func parseTickSize(size string) (decimals int, units int64, ok bool) {
+	whole, frac, _ := strings.Cut(size, ".")
+	frac = strings.TrimRight(frac, "0")
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || units <= 0 {
+		return 0, 0, false
+	}
+	return len(frac), units, true
+}
+
+// MetadataBySymbol returns the stored contract metadata keyed by symbol, or
+// nil if store does not keep any.
+func MetadataBySymbol(store Store) (map[string]SymbolMetadata, error) {
+	ms, ok := store.(MetadataStore)
+	if !ok {
+		return nil, nil
+	}
+	metas, err := ms.GetSymbolMetadata()
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]SymbolMetadata, len(metas))
+	for _, m := range metas {
+		bySymbol[m.Symbol] = m
+	}
+	return bySymbol, nil
+}
+
+func createMetadataTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS symbol_metadata (
+			symbol             TEXT PRIMARY KEY,
+			contract_type      TEXT NOT NULL,
+			tick_size          TEXT NOT NULL,
+			step_size          TEXT NOT NULL,
+			price_precision    INTEGER NOT NULL,
+			quantity_precision INTEGER NOT NULL,
+			updated_at         INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create symbol_metadata table: %w", err)
+	}
+	return nil
+}
+
+func (s *store) SetSymbolMetadata(meta SymbolMetadata) error {
+	if err := ValidateSymbol(meta.Symbol); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.writer.Exec(`
+		INSERT INTO symbol_metadata
+			(symbol, contract_type, tick_size, step_size, price_precision, quantity_precision, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(symbol) DO UPDATE SET
+			contract_type = excluded.contract_type,
+			tick_size = excluded.tick_size,
+			step_size = excluded.step_size,
+			price_precision = excluded.price_precision,
+			quantity_precision = excluded.quantity_precision,
+			updated_at = excluded.updated_at
+	`, strings.ToUpper(meta.Symbol), meta.ContractType, meta.TickSize, meta.StepSize,
+		meta.PricePrecision, meta.QuantityPrecision, meta.UpdatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("update symbol_metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetSymbolMetadata() ([]SymbolMetadata, error) {
+	rows, err := s.reader.Query(`
+		SELECT symbol, contract_type, tick_size, step_size, price_precision, quantity_precision, updated_at
+		FROM symbol_metadata ORDER BY symbol
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query symbol_metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var metas []SymbolMetadata
+	for rows.Next() {
+		var m SymbolMetadata
+		var updated int64
+		if err := rows.Scan(&m.Symbol, &m.ContractType, &m.TickSize, &m.StepSize,
+			&m.PricePrecision, &m.QuantityPrecision, &updated); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		m.UpdatedAt = time.UnixMilli(updated).UTC()
+		metas = append(metas, m)
+	}
+	return metas, rows.Err()
+}
@@ -0,0 +1,739 @@
+// Package postgres implements database.Store on PostgreSQL, optionally using
+// TimescaleDB hypertables for the per-symbol price tables.
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"binance-tick-store/internal/database"
+)
+
+// chunkInterval is the TimescaleDB chunk size in timestamp units (ms).
+const chunkInterval = 24 * 60 * 60 * 1000
+
+type store struct {
+	db        *sql.DB
+	timescale bool
+	mu        sync.Mutex           // guards stmts
+	stmts     map[string]*sql.Stmt // prepared insert statements
+}
+
+// Open connects to PostgreSQL and creates the shared tables. With timescale
+// set, price tables are created as hypertables partitioned by timestamp.
+func Open(dsn string, timescale bool) (database.Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	if timescale {
+		if _, err := db.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("enable timescaledb: %w", err)
+		}
+	}
+
+	if err := createTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &store{
+		db:        db,
+		timescale: timescale,
+		stmts:     make(map[string]*sql.Stmt),
+	}, nil
+}
+
+func createTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS symbol_settings (
+			symbol  TEXT PRIMARY KEY,
+			enabled INTEGER DEFAULT 1,
+			retired INTEGER NOT NULL DEFAULT 0
+		)`,
+		`ALTER TABLE symbol_settings ADD COLUMN IF NOT EXISTS retired INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE symbol_settings ADD COLUMN IF NOT EXISTS delisted INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE symbol_settings ADD COLUMN IF NOT EXISTS successor TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE symbol_settings ADD COLUMN IF NOT EXISTS connections INTEGER NOT NULL DEFAULT 1`,
+		`CREATE TABLE IF NOT EXISTS export_log (
+			symbol      TEXT NOT NULL,
+			day         TEXT NOT NULL,
+			path        TEXT NOT NULL,
+			rows        BIGINT NOT NULL,
+			exported_at BIGINT NOT NULL,
+			PRIMARY KEY (symbol, day)
+		)`,
+		`CREATE TABLE IF NOT EXISTS symbol_stats (
+			symbol         TEXT PRIMARY KEY,
+			row_count      BIGINT NOT NULL DEFAULT 0,
+			first_ts       BIGINT,
+			last_ts        BIGINT,
+			last_insert_at BIGINT,
+			duplicates     BIGINT NOT NULL DEFAULT 0
+		)`,
+		`ALTER TABLE symbol_stats ADD COLUMN IF NOT EXISTS duplicates BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS symbol_metadata (
+			symbol             TEXT PRIMARY KEY,
+			contract_type      TEXT NOT NULL,
+			tick_size          TEXT NOT NULL,
+			step_size          TEXT NOT NULL,
+			price_precision    INTEGER NOT NULL,
+			quantity_precision INTEGER NOT NULL,
+			updated_at         BIGINT NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("create tables: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+	return s.db.Close()
+}
+
+func (s *store) GetSymbolSettings() ([]database.SymbolSettings, error) {
+	rows, err := s.db.Query("SELECT symbol, enabled, retired, delisted, successor, connections FROM symbol_settings")
+	if err != nil {
+		return nil, fmt.Errorf("query symbol_settings: %w", err)
+	}
+	defer rows.Close()
+
+	var settings []database.SymbolSettings
+	for rows.Next() {
+		var ss database.SymbolSettings
+		var enabled, retired, delisted int
+		if err := rows.Scan(&ss.Symbol, &enabled, &retired, &delisted, &ss.Successor, &ss.Connections); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		ss.State = database.SymbolState(enabled == 1, retired == 1, delisted == 1)
+		ss.Enabled = ss.State == database.StateActive
+		settings = append(settings, ss)
+	}
+	return settings, rows.Err()
+}
+
+func (s *store) SetSymbolEnabled(symbol string, enabled bool) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled) VALUES ($1, $2)
+		ON CONFLICT (symbol) DO UPDATE SET enabled = EXCLUDED.enabled,
+			retired = CASE WHEN EXCLUDED.enabled = 1 THEN 0 ELSE symbol_settings.retired END,
+			delisted = CASE WHEN EXCLUDED.enabled = 1 THEN 0 ELSE symbol_settings.delisted END
+	`, strings.ToUpper(symbol), value)
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) EnsurePriceTable(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol = strings.ToUpper(symbol)
+	table := priceTableName(symbol)
+
+	// Hypertables cannot have a primary key that excludes the time column
+	idColumn := "id BIGSERIAL PRIMARY KEY"
+	if s.timescale {
+		idColumn = "id BIGSERIAL NOT NULL"
+	}
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			%s,
+			timestamp BIGINT NOT NULL,
+			price     DOUBLE PRECISION NOT NULL,
+			agg_id    BIGINT
+		)
+	`, table, idColumn)
+	if _, err := s.db.Exec(query); err != nil {
+		return fmt.Errorf("create price table %s: %w", table, err)
+	}
+
+	if s.timescale {
+		_, err := s.db.Exec(
+			"SELECT create_hypertable($1, 'timestamp', chunk_time_interval => $2::bigint, if_not_exists => TRUE)",
+			table, chunkInterval)
+		if err != nil {
+			return fmt.Errorf("create hypertable %s: %w", table, err)
+		}
+	}
+
+	idx := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_timestamp ON %s(timestamp, id)", table, table)
+	if _, err := s.db.Exec(idx); err != nil {
+		return fmt.Errorf("create index on %s: %w", table, err)
+	}
+
+	// Tables created before symbol_stats existed need an initial count
+	var hasStats bool
+	if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM symbol_stats WHERE symbol = $1)", symbol).Scan(&hasStats); err != nil {
+		return fmt.Errorf("check symbol_stats: %w", err)
+	}
+	if !hasStats {
+		if _, err := s.ReconcileStats(symbol); err != nil {
+			return err
+		}
+	}
+
+	if err := s.ensureUniqueAggID(symbol, table); err != nil {
+		return err
+	}
+
+	// Insert the tick and update symbol_stats in one statement. A tick
+	// already stored inserts nothing and counts as a duplicate instead.
+	stmt, err := s.db.Prepare(fmt.Sprintf(`
+		WITH ins AS (
+			INSERT INTO %s (agg_id, timestamp, price) VALUES (NULLIF($1::bigint, 0), $2, $3)
+			ON CONFLICT DO NOTHING
+			RETURNING timestamp
+		), n AS (
+			SELECT COUNT(*) AS c, MIN(timestamp) AS ts FROM ins
+		)
+		INSERT INTO symbol_stats (symbol, row_count, first_ts, last_ts, last_insert_at, duplicates)
+		SELECT '%s', c, ts, ts, CASE WHEN c > 0 THEN $4::bigint END, 1 - c FROM n
+		ON CONFLICT (symbol) DO UPDATE SET
+			row_count      = symbol_stats.row_count + EXCLUDED.row_count,
+			first_ts       = LEAST(symbol_stats.first_ts, EXCLUDED.first_ts),
+			last_ts        = GREATEST(symbol_stats.last_ts, EXCLUDED.last_ts),
+			last_insert_at = COALESCE(EXCLUDED.last_insert_at, symbol_stats.last_insert_at),
+			duplicates     = symbol_stats.duplicates + EXCLUDED.duplicates
+	`, table, symbol))
+	if err != nil {
+		return fmt.Errorf("prepare insert statement: %w", err)
+	}
+	if old, ok := s.stmts[symbol]; ok {
+		old.Close()
+	}
+	s.stmts[symbol] = stmt
+
+	return nil
+}
+
+// ensureUniqueAggID replaces the plain agg_id index of tables created
+// before duplicate protection with a unique one, deleting all but the first
+// copy of each tick. Unique indexes on hypertables must include the time
+// column; a trade's timestamp never changes, so copies of a tick still
+// collide on (agg_id, timestamp). Must be called with s.mu held.
+func (s *store) ensureUniqueAggID(symbol, table string) error {
+	index := fmt.Sprintf("idx_%s_agg_id", table)
+
+	var unique sql.NullBool
+	err := s.db.QueryRow("SELECT indisunique FROM pg_index WHERE indexrelid = to_regclass($1)", index).Scan(&unique)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if unique.Bool {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET agg_id = NULL WHERE agg_id = 0", table)); err != nil {
+		return fmt.Errorf("clear unknown agg_id in %s: %w", table, err)
+	}
+	res, err := tx.Exec(fmt.Sprintf(`
+		DELETE FROM %s a USING %s b
+		WHERE a.agg_id = b.agg_id AND a.id > b.id
+	`, table, table))
+	if err != nil {
+		return fmt.Errorf("remove duplicates from %s: %w", table, err)
+	}
+	removed, _ := res.RowsAffected()
+
+	columns := "agg_id"
+	if s.timescale {
+		columns = "agg_id, timestamp"
+	}
+	if _, err := tx.Exec("DROP INDEX IF EXISTS " + index); err != nil {
+		return fmt.Errorf("drop index on %s: %w", table, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s(%s)", index, table, columns)); err != nil {
+		return fmt.Errorf("create unique index on %s: %w", table, err)
+	}
+	if err := addDuplicates(tx, symbol, removed); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if removed == 0 {
+		return nil
+	}
+	slog.Info("removed duplicate ticks", "symbol", symbol, "count", removed)
+	_, err = s.ReconcileStats(symbol)
+	return err
+}
+
+// addDuplicates adds n to the symbol's dropped duplicate count.
+func addDuplicates(tx *sql.Tx, symbol string, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO symbol_stats (symbol, duplicates) VALUES ($1, $2)
+		ON CONFLICT (symbol) DO UPDATE SET duplicates = symbol_stats.duplicates + EXCLUDED.duplicates
+	`, symbol, n)
+	if err != nil {
+		return fmt.Errorf("update symbol_stats: %w", err)
+	}
+	return nil
+}
+
+func (s *store) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
+	s.mu.Lock()
+	stmt, ok := s.stmts[strings.ToUpper(symbol)]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no prepared statement for symbol %s", symbol)
+	}
+
+	if _, err := stmt.Exec(aggID, timestamp, price, time.Now().UnixMilli()); err != nil {
+		return fmt.Errorf("insert price: %w", err)
+	}
+	return nil
+}
+
+func (s *store) InsertPrices(symbol string, prices []database.Price) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	symbol = strings.ToUpper(symbol)
+	table := priceTableName(symbol)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO %s (agg_id, timestamp, price) VALUES (NULLIF($1::bigint, 0), $2, $3)
+		ON CONFLICT DO NOTHING
+	`, table))
+	if err != nil {
+		return 0, fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	var first, last sql.NullInt64
+	for _, p := range prices {
+		res, err := stmt.Exec(p.AggID, p.Timestamp, p.Price)
+		if err != nil {
+			return 0, fmt.Errorf("insert price: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted += n
+			if !first.Valid || p.Timestamp < first.Int64 {
+				first = sql.NullInt64{Int64: p.Timestamp, Valid: true}
+			}
+			if !last.Valid || p.Timestamp > last.Int64 {
+				last = sql.NullInt64{Int64: p.Timestamp, Valid: true}
+			}
+		}
+	}
+
+	if inserted > 0 {
+		_, err := tx.Exec(`
+			INSERT INTO symbol_stats (symbol, row_count, first_ts, last_ts, last_insert_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (symbol) DO UPDATE SET
+				row_count      = symbol_stats.row_count + EXCLUDED.row_count,
+				first_ts       = LEAST(symbol_stats.first_ts, EXCLUDED.first_ts),
+				last_ts        = GREATEST(symbol_stats.last_ts, EXCLUDED.last_ts),
+				last_insert_at = EXCLUDED.last_insert_at
+		`, symbol, inserted, first, last, time.Now().UnixMilli())
+		if err != nil {
+			return 0, fmt.Errorf("update symbol_stats: %w", err)
+		}
+	}
+	if err := addDuplicates(tx, symbol, int64(len(prices))-inserted); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return inserted, nil
+}
+
+func (s *store) GetDateRange(symbol string) (database.DateRange, error) {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
+		return database.DateRange{}, err
+	}
+	return database.DateRange{From: stats.FirstTS, To: stats.LastTS}, nil
+}
+
+func (s *store) GetCount(symbol string) (int64, error) {
+	stats, err := s.GetStats(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Count, nil
+}
+
+func (s *store) GetStats(symbol string) (database.SymbolStats, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return database.SymbolStats{}, err
+	}
+
+	stats := database.SymbolStats{Symbol: strings.ToUpper(symbol)}
+	var firstTS, lastTS, lastInsert sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT row_count, first_ts, last_ts, last_insert_at, duplicates FROM symbol_stats
+		WHERE symbol = $1
+	`, stats.Symbol).Scan(&stats.Count, &firstTS, &lastTS, &lastInsert, &stats.Duplicates)
+	if err == sql.ErrNoRows {
+		return stats, nil
+	}
+	if err != nil {
+		return database.SymbolStats{}, fmt.Errorf("query symbol_stats: %w", err)
+	}
+
+	stats.FirstTS = unixMilliPtr(firstTS)
+	stats.LastTS = unixMilliPtr(lastTS)
+	stats.LastInsertAt = unixMilliPtr(lastInsert)
+	return stats, nil
+}
+
+func (s *store) ReconcileStats(symbol string) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	symbol = strings.ToUpper(symbol)
+	table := priceTableName(symbol)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Block concurrent inserts so the recount matches the stats row
+	if _, err := tx.Exec(fmt.Sprintf("LOCK TABLE %s IN SHARE MODE", table)); err != nil {
+		return 0, fmt.Errorf("lock %s: %w", table, err)
+	}
+
+	var count int64
+	var firstTS, lastTS sql.NullInt64
+	query := fmt.Sprintf("SELECT COUNT(*), MIN(timestamp), MAX(timestamp) FROM %s", table)
+	if err := tx.QueryRow(query).Scan(&count, &firstTS, &lastTS); err != nil {
+		return 0, fmt.Errorf("scan %s: %w", table, err)
+	}
+
+	var stored int64
+	err = tx.QueryRow("SELECT row_count FROM symbol_stats WHERE symbol = $1", symbol).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("query symbol_stats: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO symbol_stats (symbol, row_count, first_ts, last_ts)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (symbol) DO UPDATE SET
+			row_count = EXCLUDED.row_count,
+			first_ts  = EXCLUDED.first_ts,
+			last_ts   = EXCLUDED.last_ts
+	`, symbol, count, firstTS, lastTS)
+	if err != nil {
+		return 0, fmt.Errorf("update symbol_stats: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return count - stored, nil
+}
+
+func (s *store) GetPrices(symbol string, q database.PriceQuery) ([]database.Price, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = database.DefaultPageSize
+	}
+
+	table := priceTableName(symbol)
+	exists, err := s.tableExists(table)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	args := []any{q.Start(), q.To}
+	var after string
+	if q.After != nil {
+		after = "AND (timestamp, id) > ($3, $4)"
+		args = append(args, q.After.Timestamp, q.After.ID)
+	}
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(agg_id, 0), timestamp, price FROM %s
+		WHERE timestamp >= $1 AND timestamp < $2 %s
+		ORDER BY timestamp, id
+		LIMIT $%d
+	`, table, after, len(args)+1)
+
+	rows, err := s.db.Query(query, append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := make([]database.Price, 0, q.Limit)
+	for rows.Next() {
+		var p database.Price
+		if err := rows.Scan(&p.ID, &p.AggID, &p.Timestamp, &p.Price); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+func (s *store) MarkExported(rec database.ExportRecord) error {
+	if err := database.ValidateSymbol(rec.Symbol); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO export_log (symbol, day, path, rows, exported_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (symbol, day) DO UPDATE SET
+			path        = EXCLUDED.path,
+			rows        = EXCLUDED.rows,
+			exported_at = EXCLUDED.exported_at
+	`, strings.ToUpper(rec.Symbol), rec.Day, rec.Path, rec.Rows, rec.ExportedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("insert export_log: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetExportRecords(symbol string) ([]database.ExportRecord, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT symbol, day, path, rows, exported_at FROM export_log
+		WHERE symbol = $1 ORDER BY day
+	`, strings.ToUpper(symbol))
+	if err != nil {
+		return nil, fmt.Errorf("query export_log: %w", err)
+	}
+	defer rows.Close()
+
+	var records []database.ExportRecord
+	for rows.Next() {
+		var rec database.ExportRecord
+		var exportedAt int64
+		if err := rows.Scan(&rec.Symbol, &rec.Day, &rec.Path, &rec.Rows, &exportedAt); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		rec.ExportedAt = time.UnixMilli(exportedAt).UTC()
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (s *store) RetireSymbol(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	symbol = strings.ToUpper(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stmt, ok := s.stmts[symbol]; ok {
+		stmt.Close()
+		delete(s.stmts, symbol)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, retired) VALUES ($1, 0, 1)
+		ON CONFLICT (symbol) DO UPDATE SET enabled = 0, retired = 1
+	`, symbol)
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	if _, err := tx.Exec("DROP TABLE IF EXISTS " + priceTableName(symbol)); err != nil {
+		return fmt.Errorf("drop price table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM symbol_stats WHERE symbol = $1", symbol); err != nil {
+		return fmt.Errorf("delete stats: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *store) MarkDelisted(symbol, successor string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if successor != "" {
+		if err := database.ValidateSymbol(successor); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, delisted, successor) VALUES ($1, 0, 1, $2)
+		ON CONFLICT (symbol) DO UPDATE SET enabled = 0, delisted = 1, successor = EXCLUDED.successor
+	`, strings.ToUpper(symbol), strings.ToUpper(successor))
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) SetSymbolConnections(symbol string, n int) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if err := database.ValidateConnections(n); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, connections) VALUES ($1, 0, $2)
+		ON CONFLICT (symbol) DO UPDATE SET connections = EXCLUDED.connections
+	`, strings.ToUpper(symbol), n)
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) SetSymbolMetadata(meta database.SymbolMetadata) error {
+	if err := database.ValidateSymbol(meta.Symbol); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO symbol_metadata
+			(symbol, contract_type, tick_size, step_size, price_precision, quantity_precision, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (symbol) DO UPDATE SET
+			contract_type = EXCLUDED.contract_type,
+			tick_size = EXCLUDED.tick_size,
+			step_size = EXCLUDED.step_size,
+			price_precision = EXCLUDED.price_precision,
+			quantity_precision = EXCLUDED.quantity_precision,
+			updated_at = EXCLUDED.updated_at
+	`, strings.ToUpper(meta.Symbol), meta.ContractType, meta.TickSize, meta.StepSize,
+		meta.PricePrecision, meta.QuantityPrecision, meta.UpdatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("update symbol_metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetSymbolMetadata() ([]database.SymbolMetadata, error) {
+	rows, err := s.db.Query(`
+		SELECT symbol, contract_type, tick_size, step_size, price_precision, quantity_precision, updated_at
+		FROM symbol_metadata ORDER BY symbol
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query symbol_metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var metas []database.SymbolMetadata
+	for rows.Next() {
+		var m database.SymbolMetadata
+		var updated int64
+		if err := rows.Scan(&m.Symbol, &m.ContractType, &m.TickSize, &m.StepSize,
+			&m.PricePrecision, &m.QuantityPrecision, &updated); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		m.UpdatedAt = time.UnixMilli(updated).UTC()
+		metas = append(metas, m)
+	}
+	return metas, rows.Err()
+}
+
+func (s *store) PriceSymbols() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename LIKE 'prices\_%'
+		ORDER BY tablename
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list price tables: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		symbols = append(symbols, strings.ToUpper(strings.TrimPrefix(name, "prices_")))
+	}
+	return symbols, rows.Err()
+}
+
+func (s *store) tableExists(table string) (bool, error) {
+	var exists bool
+	if err := s.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check table %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+// priceTableName returns the unquoted table name; PostgreSQL folds it to
+// lower case (prices_btcusdt).
+func priceTableName(symbol string) string {
+	return "prices_" + strings.ToLower(symbol)
+}
+
+func unixMilliPtr(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.UnixMilli(v.Int64).UTC()
+	return &t
+}
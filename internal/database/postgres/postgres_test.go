@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/database/storetest"
+)
+
+// TestConformance runs against a scratch database named by TEST_POSTGRES_DSN.
+// Every table in the public schema is dropped between subtests.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	timescale := os.Getenv("TEST_POSTGRES_TIMESCALE") == "1"
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		resetSchema(t, dsn)
+		store, err := Open(dsn, timescale)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return store
+	})
+}
+
+func resetSchema(t *testing.T, dsn string) {
+	t.Helper()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT tablename FROM pg_tables WHERE schemaname = 'public'")
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	for _, name := range tables {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + name + " CASCADE"); err != nil {
+			t.Fatalf("drop %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxConnections bounds the redundant connections of one symbol; each one
+// counts against the exchange's per-IP connection limits.
+const MaxConnections = 4
+
+// Redundancer is implemented by stores that keep how many redundant
+// connections capture each symbol.
+type Redundancer interface {
+	// SetSymbolConnections sets the connections of symbol, 1 to
+	// MaxConnections. It does not change the symbol's state.
+	SetSymbolConnections(symbol string, n int) error
+}
+
+// ValidateConnections checks a symbol's number of connections.
+func ValidateConnections(n int) error {
+	if n < 1 || n > MaxConnections {
+		return fmt.Errorf("invalid connections %d: must be 1 to %d", n, MaxConnections)
+	}
+	return nil
+}
+
+func (s *store) SetSymbolConnections(symbol string, n int) error {
+	if err := ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	if err := ValidateConnections(n); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.writer.Exec(`
+		INSERT INTO symbol_settings (symbol, enabled, connections) VALUES (?, 0, ?)
+		ON CONFLICT(symbol) DO UPDATE SET connections = excluded.connections
+	`, strings.ToUpper(symbol), n)
+	if err != nil {
+		return fmt.Errorf("update symbol_settings: %w", err)
+	}
+	return nil
+}
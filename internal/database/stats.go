@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SymbolStats is the maintained summary of a symbol's price table.
+type SymbolStats struct {
+	Symbol       string
+	Count        int64
+	FirstTS      *time.Time
+	LastTS       *time.Time
+	LastInsertAt *time.Time
+	Duplicates   int64 // ticks dropped because their aggregate id was already stored
+}
+
+func createStatsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS symbol_stats (
+			symbol         TEXT PRIMARY KEY,
+			row_count      INTEGER NOT NULL DEFAULT 0,
+			first_ts       INTEGER,
+			last_ts        INTEGER,
+			last_insert_at INTEGER,
+			duplicates     INTEGER NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create symbol_stats table: %w", err)
+	}
+
+	// Added with duplicate protection
+	var exists int
+	err = db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info('symbol_stats') WHERE name = 'duplicates'",
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("inspect symbol_stats: %w", err)
+	}
+	if exists == 0 {
+		if _, err := db.Exec("ALTER TABLE symbol_stats ADD COLUMN duplicates INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return fmt.Errorf("add duplicates to symbol_stats: %w", err)
+		}
+	}
+	return nil
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// addDuplicates adds n to the symbol's dropped duplicate count.
+func addDuplicates(db execer, symbol string, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := db.Exec(`
+		INSERT INTO symbol_stats (symbol, duplicates) VALUES (?, ?)
+		ON CONFLICT(symbol) DO UPDATE SET duplicates = duplicates + excluded.duplicates
+	`, strings.ToUpper(symbol), n)
+	if err != nil {
+		return fmt.Errorf("update symbol_stats: %w", err)
+	}
+	return nil
+}
+
+// ensureStatsTrigger keeps symbol_stats current on every insert into table,
+// including batch imports and inserts from other processes. Must be called
+// with s.mu held.
+func (s *store) ensureStatsTrigger(symbol, table string) error {
+	trigger := fmt.Sprintf(`
+		CREATE TRIGGER IF NOT EXISTS trg_%s_stats AFTER INSERT ON %s
+		BEGIN
+			INSERT INTO symbol_stats (symbol, row_count, first_ts, last_ts, last_insert_at)
+			VALUES ('%s', 1, NEW.timestamp, NEW.timestamp, CAST(unixepoch('subsec') * 1000 AS INTEGER))
+			ON CONFLICT(symbol) DO UPDATE SET
+				row_count      = row_count + 1,
+				first_ts       = MIN(COALESCE(first_ts, excluded.first_ts), excluded.first_ts),
+				last_ts        = MAX(COALESCE(last_ts, excluded.last_ts), excluded.last_ts),
+				last_insert_at = excluded.last_insert_at;
+		END
+	`, table, table, strings.ToUpper(symbol))
+	if _, err := s.writer.Exec(trigger); err != nil {
+		return fmt.Errorf("create stats trigger on %s: %w", table, err)
+	}
+
+	// Tables that predate symbol_stats are summarized once here
+	var exists int
+	err := s.writer.QueryRow(
+		"SELECT COUNT(*) FROM symbol_stats WHERE symbol = ?", strings.ToUpper(symbol),
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("query symbol_stats: %w", err)
+	}
+	if exists == 0 {
+		return s.reconcileStats(symbol)
+	}
+	return nil
+}
+
+func (s *store) GetStats(symbol string) (SymbolStats, error) {
+	if err := ValidateSymbol(symbol); err != nil {
+		return SymbolStats{}, err
+	}
+
+	stats := SymbolStats{Symbol: strings.ToUpper(symbol)}
+	var firstTS, lastTS, lastInsert sql.NullInt64
+	err := s.reader.QueryRow(`
+		SELECT row_count, first_ts, last_ts, last_insert_at, duplicates FROM symbol_stats
+		WHERE symbol = ?
+	`, stats.Symbol).Scan(&stats.Count, &firstTS, &lastTS, &lastInsert, &stats.Duplicates)
+	if err == sql.ErrNoRows {
+		return stats, nil
+	}
+	if err != nil {
+		return SymbolStats{}, fmt.Errorf("query symbol_stats: %w", err)
+	}
+
+	stats.FirstTS = unixMilliPtr(firstTS)
+	stats.LastTS = unixMilliPtr(lastTS)
+	stats.LastInsertAt = unixMilliPtr(lastInsert)
+	return stats, nil
+}
+
+// ReconcileStats recomputes a symbol's stats from its price table. The full
+// scan runs on a read-only connection, so inserts continue meanwhile; only
+// the rows inserted since the scan are counted under the write lock.
+func (s *store) ReconcileStats(symbol string) (int64, error) {
+	if err := ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	symbol = strings.ToUpper(symbol)
+
+	snap, err := scanStats(s.reader, priceTableName(symbol), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.applyStats(symbol, snap)
+}
+
+// reconcileStats recomputes the stats with the full scan on the writer. It
+// must be called with s.mu held.
+func (s *store) reconcileStats(symbol string) error {
+	symbol = strings.ToUpper(symbol)
+
+	snap, err := scanStats(s.writer, priceTableName(symbol), 0)
+	if err != nil {
+		return err
+	}
+	_, err = s.applyStats(symbol, snap)
+	return err
+}
+
+// tableStats summarizes the rows of a price table with id > afterID.
+type tableStats struct {
+	count           int64
+	firstTS, lastTS sql.NullInt64
+	maxID           int64 // afterID if there are no such rows
+}
+
+// add combines the summaries of two disjoint sets of rows.
+func (t tableStats) add(o tableStats) tableStats {
+	t.count += o.count
+	t.firstTS = pick(t.firstTS, o.firstTS, func(a, b int64) bool { return a < b })
+	t.lastTS = pick(t.lastTS, o.lastTS, func(a, b int64) bool { return a > b })
+	t.maxID = max(t.maxID, o.maxID)
+	return t
+}
+
+// pick returns b if a is null or b is valid and better than a, else a.
+func pick(a, b sql.NullInt64, better func(a, b int64) bool) sql.NullInt64 {
+	if !a.Valid || b.Valid && better(b.Int64, a.Int64) {
+		return b
+	}
+	return a
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// scanStats summarizes the rows of table with id > afterID in one statement,
+// so the aggregates and maxID come from the same snapshot. Ids only grow
+// (AUTOINCREMENT), so rows inserted later all have ids above maxID.
+func scanStats(db queryRower, table string, afterID int64) (tableStats, error) {
+	t := tableStats{maxID: afterID}
+	query := fmt.Sprintf("SELECT COUNT(*), MIN(timestamp), MAX(timestamp), COALESCE(MAX(id), ?) FROM %s WHERE id > ?", table)
+	if err := db.QueryRow(query, afterID, afterID).Scan(&t.count, &t.firstTS, &t.lastTS, &t.maxID); err != nil {
+		return tableStats{}, fmt.Errorf("scan %s: %w", table, err)
+	}
+	return t, nil
+}
+
+// applyStats stores snap plus the rows inserted since it was taken, found
+// through the primary key, and returns how far the stored count was off. It
+// must be called with s.mu held.
+func (s *store) applyStats(symbol string, snap tableStats) (int64, error) {
+	tx, err := s.writer.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	since, err := scanStats(tx, priceTableName(symbol), snap.maxID)
+	if err != nil {
+		return 0, err
+	}
+	total := snap.add(since)
+
+	var stored int64
+	err = tx.QueryRow("SELECT row_count FROM symbol_stats WHERE symbol = ?", symbol).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("query symbol_stats: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO symbol_stats (symbol, row_count, first_ts, last_ts)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(symbol) DO UPDATE SET
+			row_count = excluded.row_count,
+			first_ts  = excluded.first_ts,
+			last_ts   = excluded.last_ts
+	`, symbol, total.count, total.firstTS, total.lastTS)
+	if err != nil {
+		return 0, fmt.Errorf("update symbol_stats: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return total.count - stored, nil
+}
+
+// StatsReconciler periodically recomputes symbol_stats for all configured
+// symbols, correcting any drift from the incremental updates.
+type StatsReconciler struct {
+	store    Store
+	interval time.Duration
+}
+
+// NewStatsReconciler creates a reconciler running every interval.
+func NewStatsReconciler(store Store, interval time.Duration) *StatsReconciler {
+	return &StatsReconciler{
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Start runs the reconciler in the background until ctx is cancelled.
+func (r *StatsReconciler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce reconciles every configured symbol that has a price table.
+func (r *StatsReconciler) RunOnce(ctx context.Context) {
+	settings, err := r.store.GetSymbolSettings()
+	if err != nil {
+		slog.Error("failed to get symbol settings", "error", err)
+		return
+	}
+
+	for _, ss := range settings {
+		if ctx.Err() != nil {
+			return
+		}
+		stats, err := r.store.GetStats(ss.Symbol)
+		if err != nil {
+			slog.Error("failed to get stats", "symbol", ss.Symbol, "error", err)
+			continue
+		}
+		if stats.FirstTS == nil && stats.Count == 0 {
+			continue // no data yet, maybe no table
+		}
+		drift, err := r.store.ReconcileStats(ss.Symbol)
+		if err != nil {
+			slog.Error("failed to reconcile stats", "symbol", ss.Symbol, "error", err)
+			continue
+		}
+		if drift != 0 {
+			slog.Warn("stats drift corrected", "symbol", ss.Symbol, "drift", drift)
+		}
+	}
+}
+
+func unixMilliPtr(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.UnixMilli(v.Int64).UTC()
+	return &t
+}
@@ -0,0 +1,587 @@
+// Package storetest is the conformance suite every database.Store backend
+// must pass. Backends call Run from their own tests with a constructor that
+// returns a fresh, empty store.
+package storetest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+// Run executes the conformance suite. open must return a new empty store for
+// each call; Run closes it.
+func Run(t *testing.T, open func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s database.Store)
+	}{
+		{"SymbolSettings", testSymbolSettings},
+		{"InvalidSymbol", testInvalidSymbol},
+		{"EnsurePriceTableIdempotent", testEnsurePriceTableIdempotent},
+		{"InsertAndStats", testInsertAndStats},
+		{"InsertPricesDeduplicates", testInsertPricesDeduplicates},
+		{"DuplicatesDroppedAndCounted", testDuplicatesDroppedAndCounted},
+		{"GetPricesOrderAndPaging", testGetPricesOrderAndPaging},
+		{"NonPositiveTimestamps", testNonPositiveTimestamps},
+		{"UnknownSymbol", testUnknownSymbol},
+		{"ReconcileStats", testReconcileStats},
+		{"ExportRecords", testExportRecords},
+		{"RetireSymbol", testRetireSymbol},
+		{"MarkDelisted", testMarkDelisted},
+		{"SymbolConnections", testSymbolConnections},
+		{"SymbolMetadata", testSymbolMetadata},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			tt.fn(t, s)
+		})
+	}
+}
+
+func mustEnsure(t *testing.T, s database.Store, symbol string) {
+	t.Helper()
+	if err := s.EnsurePriceTable(symbol); err != nil {
+		t.Fatalf("EnsurePriceTable(%s) failed: %v", symbol, err)
+	}
+}
+
+func mustInsert(t *testing.T, s database.Store, symbol string, aggID, ts int64, price float64) {
+	t.Helper()
+	if err := s.InsertPrice(symbol, aggID, ts, price); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+}
+
+func testSymbolSettings(t *testing.T, s database.Store) {
+	settings, err := s.GetSymbolSettings()
+	if err != nil {
+		t.Fatalf("GetSymbolSettings failed: %v", err)
+	}
+	if len(settings) != 0 {
+		t.Fatalf("expected empty settings, got %+v", settings)
+	}
+
+	if err := s.SetSymbolEnabled("BTCUSDT", true); err != nil {
+		t.Fatalf("SetSymbolEnabled failed: %v", err)
+	}
+	if err := s.SetSymbolEnabled("ethusdt", true); err != nil {
+		t.Fatalf("SetSymbolEnabled failed: %v", err)
+	}
+	if err := s.SetSymbolEnabled("ETHUSDT", false); err != nil {
+		t.Fatalf("SetSymbolEnabled failed: %v", err)
+	}
+
+	settings, err = s.GetSymbolSettings()
+	if err != nil {
+		t.Fatalf("GetSymbolSettings failed: %v", err)
+	}
+	got := make(map[string]bool)
+	states := make(map[string]string)
+	for _, ss := range settings {
+		got[ss.Symbol] = ss.Enabled
+		states[ss.Symbol] = ss.State
+	}
+	if len(got) != 2 || !got["BTCUSDT"] || got["ETHUSDT"] {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+	if states["BTCUSDT"] != database.StateActive || states["ETHUSDT"] != database.StatePaused {
+		t.Errorf("unexpected states: %+v", settings)
+	}
+}
+
+func testInvalidSymbol(t *testing.T, s database.Store) {
+	if err := s.EnsurePriceTable("BTC;DROP TABLE"); err == nil {
+		t.Error("expected error from EnsurePriceTable")
+	}
+	if err := s.SetSymbolEnabled("BTC-USDT", true); err == nil {
+		t.Error("expected error from SetSymbolEnabled")
+	}
+	if _, err := s.GetStats("BTC USDT"); err == nil {
+		t.Error("expected error from GetStats")
+	}
+}
+
+func testEnsurePriceTableIdempotent(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 1, 1000, 1.5)
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 2, 2000, 2.5)
+
+	if count, _ := s.GetCount("BTCUSDT"); count != 2 {
+		t.Errorf("expected 2 ticks, got %d", count)
+	}
+}
+
+func testInsertAndStats(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 2, 2000, 42000.5)
+	mustInsert(t, s, "BTCUSDT", 1, 1000, 42000.25)
+	mustInsert(t, s, "BTCUSDT", 3, 3000, 42001)
+
+	stats, err := s.GetStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Count != 3 {
+		t.Errorf("expected count 3, got %d", stats.Count)
+	}
+	if stats.FirstTS == nil || stats.FirstTS.UnixMilli() != 1000 {
+		t.Errorf("expected first 1000, got %v", stats.FirstTS)
+	}
+	if stats.LastTS == nil || stats.LastTS.UnixMilli() != 3000 {
+		t.Errorf("expected last 3000, got %v", stats.LastTS)
+	}
+	if stats.LastInsertAt == nil || time.Since(*stats.LastInsertAt) > time.Minute {
+		t.Errorf("unexpected last insert time %v", stats.LastInsertAt)
+	}
+
+	dr, err := s.GetDateRange("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetDateRange failed: %v", err)
+	}
+	if dr.From == nil || dr.To == nil || dr.From.UnixMilli() != 1000 || dr.To.UnixMilli() != 3000 {
+		t.Errorf("unexpected date range %v - %v", dr.From, dr.To)
+	}
+	if count, _ := s.GetCount("BTCUSDT"); count != 3 {
+		t.Errorf("expected count 3, got %d", count)
+	}
+}
+
+func testInsertPricesDeduplicates(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 101, 1100, 1)
+
+	n, err := s.InsertPrices("BTCUSDT", []database.Price{
+		{AggID: 100, Timestamp: 1000, Price: 1},
+		{AggID: 101, Timestamp: 1100, Price: 1},
+		{AggID: 102, Timestamp: 1200, Price: 1},
+	})
+	if err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 inserted, got %d", n)
+	}
+
+	n, err = s.InsertPrices("BTCUSDT", []database.Price{{AggID: 100, Timestamp: 1000, Price: 1}})
+	if err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected duplicate skipped, got %d inserted", n)
+	}
+
+	stats, _ := s.GetStats("BTCUSDT")
+	if stats.Count != 3 || stats.FirstTS == nil || stats.FirstTS.UnixMilli() != 1000 {
+		t.Errorf("unexpected stats after batch: %+v", stats)
+	}
+}
+
+func testDuplicatesDroppedAndCounted(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 1, 1000, 1)
+	mustInsert(t, s, "BTCUSDT", 1, 1000, 1)
+
+	n, err := s.InsertPrices("BTCUSDT", []database.Price{
+		{AggID: 1, Timestamp: 1000, Price: 1},
+		{AggID: 2, Timestamp: 2000, Price: 2},
+		{AggID: 2, Timestamp: 2000, Price: 2},
+	})
+	if err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 inserted, got %d", n)
+	}
+
+	// Ticks without an aggregate id are never treated as duplicates
+	mustInsert(t, s, "BTCUSDT", 0, 3000, 3)
+	mustInsert(t, s, "BTCUSDT", 0, 3000, 3)
+
+	stats, err := s.GetStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Count != 4 || stats.Duplicates != 3 {
+		t.Errorf("expected 4 ticks and 3 duplicates, got %+v", stats)
+	}
+	if stats.LastTS == nil || stats.LastTS.UnixMilli() != 3000 {
+		t.Errorf("unexpected last timestamp %v", stats.LastTS)
+	}
+
+	if _, err := s.ReconcileStats("BTCUSDT"); err != nil {
+		t.Fatalf("ReconcileStats failed: %v", err)
+	}
+	if stats, _ := s.GetStats("BTCUSDT"); stats.Duplicates != 3 {
+		t.Errorf("expected reconcile to keep duplicate count, got %d", stats.Duplicates)
+	}
+}
+
+func testGetPricesOrderAndPaging(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	for i, ts := range []int64{5000, 1000, 3000, 2000, 2000, 4000} {
+		mustInsert(t, s, "BTCUSDT", int64(i+1), ts, float64(ts)/10)
+	}
+
+	it := database.NewPriceIterator(s, "BTCUSDT", 2000, 5000, 2)
+	var got []database.Price
+	for it.Next() {
+		got = append(got, it.Price())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator failed: %v", err)
+	}
+
+	want := []int64{2000, 2000, 3000, 4000}
+	if len(got) != len(want) {
+		t.Fatalf("expected timestamps %v, got %+v", want, got)
+	}
+	for i, p := range got {
+		if p.Timestamp != want[i] {
+			t.Fatalf("expected timestamps %v, got %+v", want, got)
+		}
+		if p.Price != float64(p.Timestamp)/10 {
+			t.Errorf("unexpected price %v for %d", p.Price, p.Timestamp)
+		}
+		if p.ID == 0 || p.AggID == 0 {
+			t.Errorf("expected ids to be set: %+v", p)
+		}
+	}
+	if got[0].ID >= got[1].ID {
+		t.Errorf("expected ties ordered by id: %+v", got[:2])
+	}
+}
+
+// testNonPositiveTimestamps checks that the first page is not mistaken for
+// one following a tick at timestamp 0.
+func testNonPositiveTimestamps(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	for i, ts := range []int64{-5, 0, 10} {
+		mustInsert(t, s, "BTCUSDT", int64(i+1), ts, 1)
+	}
+
+	it := database.NewPriceIterator(s, "BTCUSDT", math.MinInt64, math.MaxInt64, 2)
+	var got []int64
+	for it.Next() {
+		got = append(got, it.Price().Timestamp)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator failed: %v", err)
+	}
+	if len(got) != 3 || got[0] != -5 || got[1] != 0 || got[2] != 10 {
+		t.Errorf("expected timestamps [-5 0 10], got %v", got)
+	}
+	if count, err := s.GetCount("BTCUSDT"); err != nil || count != 3 {
+		t.Errorf("expected count 3, got %d (%v)", count, err)
+	}
+}
+
+func testUnknownSymbol(t *testing.T, s database.Store) {
+	stats, err := s.GetStats("NONEXISTENT")
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Count != 0 || stats.FirstTS != nil {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	dr, err := s.GetDateRange("NONEXISTENT")
+	if err != nil {
+		t.Fatalf("GetDateRange failed: %v", err)
+	}
+	if dr.From != nil || dr.To != nil {
+		t.Error("expected nil date range")
+	}
+
+	prices, err := s.GetPrices("NONEXISTENT", database.PriceQuery{To: math.MaxInt64})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(prices) != 0 {
+		t.Errorf("expected no prices, got %d", len(prices))
+	}
+}
+
+func testReconcileStats(t *testing.T, s database.Store) {
+	mustEnsure(t, s, "BTCUSDT")
+	mustInsert(t, s, "BTCUSDT", 1, 1000, 1)
+	mustInsert(t, s, "BTCUSDT", 2, 2000, 1)
+
+	drift, err := s.ReconcileStats("BTCUSDT")
+	if err != nil {
+		t.Fatalf("ReconcileStats failed: %v", err)
+	}
+	if drift != 0 {
+		t.Errorf("expected no drift for maintained stats, got %d", drift)
+	}
+	stats, _ := s.GetStats("BTCUSDT")
+	if stats.Count != 2 || stats.FirstTS.UnixMilli() != 1000 || stats.LastTS.UnixMilli() != 2000 {
+		t.Errorf("unexpected stats after reconcile: %+v", stats)
+	}
+}
+
+func testExportRecords(t *testing.T, s database.Store) {
+	rec := database.ExportRecord{
+		Symbol:     "BTCUSDT",
+		Day:        "2024-01-15",
+		Path:       "/tmp/a.parquet",
+		Rows:       10,
+		ExportedAt: time.UnixMilli(1705300000000).UTC(),
+	}
+	if err := s.MarkExported(rec); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+	rec.Rows = 12
+	if err := s.MarkExported(rec); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+	if err := s.MarkExported(database.ExportRecord{Symbol: "BTCUSDT", Day: "2024-01-14", ExportedAt: rec.ExportedAt}); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+
+	records, err := s.GetExportRecords("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetExportRecords failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	if records[0].Day != "2024-01-14" || records[1].Rows != 12 || !records[1].ExportedAt.Equal(rec.ExportedAt) {
+		t.Errorf("unexpected records: %+v", records)
+	}
+
+	if others, _ := s.GetExportRecords("ETHUSDT"); len(others) != 0 {
+		t.Errorf("expected no records for ETHUSDT, got %+v", others)
+	}
+
+	// Symbols are matched regardless of case
+	if err := s.MarkExported(database.ExportRecord{Symbol: "ethusdt", Day: "2024-01-15", ExportedAt: rec.ExportedAt}); err != nil {
+		t.Fatalf("MarkExported failed: %v", err)
+	}
+	if records, _ := s.GetExportRecords("ETHUSDT"); len(records) != 1 || records[0].Symbol != "ETHUSDT" {
+		t.Errorf("expected the lowercase record under ETHUSDT, got %+v", records)
+	}
+	if records, _ := s.GetExportRecords("btcusdt"); len(records) != 2 {
+		t.Errorf("expected 2 records for btcusdt, got %+v", records)
+	}
+}
+
+func testRetireSymbol(t *testing.T, s database.Store) {
+	retirer, ok := s.(database.Retirer)
+	if !ok {
+		t.Skip("store cannot retire symbols")
+	}
+
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		s.SetSymbolEnabled(symbol, true)
+		mustEnsure(t, s, symbol)
+		mustInsert(t, s, symbol, 1, 1000, 1.5)
+	}
+	mustEnsure(t, s, "XRPUSDT") // no settings row
+
+	symbols, err := retirer.PriceSymbols()
+	if err != nil {
+		t.Fatalf("PriceSymbols failed: %v", err)
+	}
+	if len(symbols) != 3 || symbols[0] != "BTCUSDT" || symbols[2] != "XRPUSDT" {
+		t.Errorf("unexpected price symbols: %v", symbols)
+	}
+	settings, _ := s.GetSymbolSettings()
+	if orphans := database.Orphans(symbols, settings); len(orphans) != 1 || orphans[0] != "XRPUSDT" {
+		t.Errorf("unexpected orphans: %v", orphans)
+	}
+
+	if err := retirer.RetireSymbol("ethusdt"); err != nil {
+		t.Fatalf("RetireSymbol failed: %v", err)
+	}
+	symbols, _ = retirer.PriceSymbols()
+	if len(symbols) != 2 || symbols[0] != "BTCUSDT" || symbols[1] != "XRPUSDT" {
+		t.Errorf("unexpected price symbols after retire: %v", symbols)
+	}
+	if stats, err := s.GetStats("ETHUSDT"); err != nil || stats.Count != 0 {
+		t.Errorf("expected no stats after retire, got %+v, %v", stats, err)
+	}
+	if prices, err := s.GetPrices("ETHUSDT", database.PriceQuery{To: math.MaxInt64}); err != nil || len(prices) != 0 {
+		t.Errorf("expected no prices after retire, got %v, %v", prices, err)
+	}
+
+	states := make(map[string]database.SymbolSettings)
+	settings, _ = s.GetSymbolSettings()
+	for _, ss := range settings {
+		states[ss.Symbol] = ss
+	}
+	if ss := states["ETHUSDT"]; ss.Enabled || ss.State != database.StateRetired {
+		t.Errorf("expected ETHUSDT retired, got %+v", ss)
+	}
+	if ss := states["BTCUSDT"]; ss.State != database.StateActive {
+		t.Errorf("expected BTCUSDT untouched, got %+v", ss)
+	}
+
+	// Pausing keeps a symbol retired; enabling it starts over
+	s.SetSymbolEnabled("ETHUSDT", false)
+	settings, _ = s.GetSymbolSettings()
+	for _, ss := range settings {
+		if ss.Symbol == "ETHUSDT" && ss.State != database.StateRetired {
+			t.Errorf("expected ETHUSDT still retired, got %+v", ss)
+		}
+	}
+	s.SetSymbolEnabled("ETHUSDT", true)
+	mustEnsure(t, s, "ETHUSDT")
+	mustInsert(t, s, "ETHUSDT", 1, 2000, 2.5)
+	if count, _ := s.GetCount("ETHUSDT"); count != 1 {
+		t.Errorf("expected a fresh table after re-enabling, got %d ticks", count)
+	}
+}
+
+func testMarkDelisted(t *testing.T, s database.Store) {
+	delister, ok := s.(database.Delister)
+	if !ok {
+		t.Skip("store cannot mark symbols delisted")
+	}
+
+	s.SetSymbolEnabled("SHIBUSDT", true)
+	mustEnsure(t, s, "SHIBUSDT")
+	mustInsert(t, s, "SHIBUSDT", 1, 1000, 0.00001)
+
+	if err := delister.MarkDelisted("shibusdt", "1000shibusdt"); err != nil {
+		t.Fatalf("MarkDelisted failed: %v", err)
+	}
+	if err := delister.MarkDelisted("LUNAUSDT", ""); err != nil {
+		t.Fatalf("MarkDelisted failed: %v", err)
+	}
+	if err := delister.MarkDelisted("SHIBUSDT", "1000-SHIB"); err == nil {
+		t.Error("expected error for an invalid successor")
+	}
+
+	settings, err := s.GetSymbolSettings()
+	if err != nil {
+		t.Fatalf("GetSymbolSettings failed: %v", err)
+	}
+	got := make(map[string]database.SymbolSettings)
+	for _, ss := range settings {
+		got[ss.Symbol] = ss
+	}
+	if ss := got["SHIBUSDT"]; ss.Enabled || ss.State != database.StateDelisted || ss.Successor != "1000SHIBUSDT" {
+		t.Errorf("unexpected SHIBUSDT settings: %+v", ss)
+	}
+	if ss := got["LUNAUSDT"]; ss.State != database.StateDelisted || ss.Successor != "" {
+		t.Errorf("unexpected LUNAUSDT settings: %+v", ss)
+	}
+	if count, _ := s.GetCount("SHIBUSDT"); count != 1 {
+		t.Errorf("delisting must keep ticks, got %d", count)
+	}
+
+	// Pausing keeps a symbol delisted; enabling it reactivates it
+	s.SetSymbolEnabled("SHIBUSDT", false)
+	s.SetSymbolEnabled("LUNAUSDT", true)
+	settings, _ = s.GetSymbolSettings()
+	for _, ss := range settings {
+		got[ss.Symbol] = ss
+	}
+	if got["SHIBUSDT"].State != database.StateDelisted || got["LUNAUSDT"].State != database.StateActive {
+		t.Errorf("unexpected states: %+v", settings)
+	}
+}
+
+func testSymbolMetadata(t *testing.T, s database.Store) {
+	ms, ok := s.(database.MetadataStore)
+	if !ok {
+		t.Skip("store cannot keep contract metadata")
+	}
+
+	if metas, err := ms.GetSymbolMetadata(); err != nil || len(metas) != 0 {
+		t.Fatalf("expected no metadata, got %+v, %v", metas, err)
+	}
+
+	updated := time.UnixMilli(1705300000000).UTC()
+	meta := database.SymbolMetadata{
+		Symbol:            "ethusdt",
+		ContractType:      "PERPETUAL",
+		TickSize:          "0.01",
+		StepSize:          "0.001",
+		PricePrecision:    2,
+		QuantityPrecision: 3,
+		UpdatedAt:         updated,
+	}
+	if err := ms.SetSymbolMetadata(meta); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+	meta.TickSize = "0.05"
+	if err := ms.SetSymbolMetadata(meta); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+	if err := ms.SetSymbolMetadata(database.SymbolMetadata{Symbol: "BTCUSDT", TickSize: "0.10", UpdatedAt: updated}); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+	if err := ms.SetSymbolMetadata(database.SymbolMetadata{Symbol: "BTC-USDT"}); err == nil {
+		t.Error("expected error for an invalid symbol")
+	}
+
+	metas, err := ms.GetSymbolMetadata()
+	if err != nil {
+		t.Fatalf("GetSymbolMetadata failed: %v", err)
+	}
+	if len(metas) != 2 || metas[0].Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected metadata: %+v", metas)
+	}
+	got := metas[1]
+	if got.Symbol != "ETHUSDT" || got.TickSize != "0.05" || got.StepSize != "0.001" || got.ContractType != "PERPETUAL" ||
+		got.PricePrecision != 2 || got.QuantityPrecision != 3 || !got.UpdatedAt.Equal(updated) {
+		t.Errorf("unexpected metadata: %+v", got)
+	}
+}
+
+func testSymbolConnections(t *testing.T, s database.Store) {
+	r, ok := s.(database.Redundancer)
+	if !ok {
+		t.Skip("store cannot set connections")
+	}
+
+	s.SetSymbolEnabled("BTCUSDT", true)
+	if err := r.SetSymbolConnections("btcusdt", 3); err != nil {
+		t.Fatalf("SetSymbolConnections failed: %v", err)
+	}
+	if err := r.SetSymbolConnections("ETHUSDT", 2); err != nil {
+		t.Fatalf("SetSymbolConnections failed: %v", err)
+	}
+	for _, n := range []int{0, database.MaxConnections + 1} {
+		if err := r.SetSymbolConnections("BTCUSDT", n); err == nil {
+			t.Errorf("expected error for %d connections", n)
+		}
+	}
+	s.SetSymbolEnabled("SOLUSDT", true)
+
+	settings, err := s.GetSymbolSettings()
+	if err != nil {
+		t.Fatalf("GetSymbolSettings failed: %v", err)
+	}
+	got := make(map[string]database.SymbolSettings)
+	for _, ss := range settings {
+		got[ss.Symbol] = ss
+	}
+	if ss := got["BTCUSDT"]; ss.Connections != 3 || ss.State != database.StateActive {
+		t.Errorf("unexpected BTCUSDT settings: %+v", ss)
+	}
+	if ss := got["ETHUSDT"]; ss.Connections != 2 || ss.State != database.StatePaused {
+		t.Errorf("expected ETHUSDT added paused, got %+v", ss)
+	}
+	if ss := got["SOLUSDT"]; ss.Connections != 1 {
+		t.Errorf("expected 1 connection by default, got %+v", ss)
+	}
+
+	// Enabling again keeps the connections
+	s.SetSymbolEnabled("BTCUSDT", false)
+	s.SetSymbolEnabled("BTCUSDT", true)
+	settings, _ = s.GetSymbolSettings()
+	for _, ss := range settings {
+		if ss.Symbol == "BTCUSDT" && ss.Connections != 3 {
+			t.Errorf("expected the connections kept, got %+v", ss)
+		}
+	}
+}
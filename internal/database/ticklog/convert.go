@@ -0,0 +1,62 @@
+package ticklog
+
+import (
+	"fmt"
+	"math"
+
+	"binance-tick-store/internal/database"
+)
+
+// Convert copies every tick of symbol from src, typically a SQLite
+// prices_<SYMBOL> table, into the log in timestamp order. Ticks get new ids
+// in that order. The destination must not hold ticks for symbol yet, so an
+// interrupted conversion is retried by deleting <dir>/<SYMBOL> first.
+func Convert(src database.Store, dst *Log, symbol string) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	if n, err := dst.GetCount(symbol); err != nil {
+		return 0, err
+	} else if n > 0 {
+		return 0, fmt.Errorf("tick log already holds %d ticks for %s", n, symbol)
+	}
+
+	sl, err := dst.symbol(symbol, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var converted int64
+	batch := make([]database.Price, 0, blockTicks)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := sl.append(batch, false); err != nil {
+			return err
+		}
+		converted += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	it := database.NewPriceIterator(src, symbol, math.MinInt64, math.MaxInt64, database.DefaultPageSize)
+	for it.Next() {
+		batch = append(batch, it.Price())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return converted, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return converted, err
+	}
+	if err := flush(); err != nil {
+		return converted, err
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return converted, sl.flush()
+}
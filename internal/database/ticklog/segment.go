@@ -0,0 +1,347 @@
+package ticklog
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	indexEntrySize = 64
+
+	// Block payload encodings. Prices are stored as integers scaled by the
+	// symbol's tick size decimals when its contract metadata is known, else
+	// by 1e8, which covers every Binance quote; anything else falls back to
+	// the raw float bits. Smaller scales give smaller deltas.
+	encodingScaled  = 1
+	encodingRaw     = 2
+	encodingDecimal = 3 // followed by the number of decimals
+
+	priceScale  = 1e8
+	maxDecimals = 18
+)
+
+// blockIndex describes one compressed block in a segment file.
+type blockIndex struct {
+	Offset  int64
+	Size    int64
+	Count   int64
+	FirstID int64
+	LastID  int64
+	MinTS   int64
+	MaxTS   int64
+
+	// Range of the block's nonzero aggregate ids; MinAggID > MaxAggID if it
+	// has none.
+	MinAggID int64
+	MaxAggID int64
+}
+
+// hasAggID reports whether id may be among the block's aggregate ids.
+func (b blockIndex) hasAggID(id int64) bool {
+	return b.MinAggID <= id && id <= b.MaxAggID
+}
+
+func (b blockIndex) encode() []byte {
+	buf := make([]byte, indexEntrySize)
+	binary.LittleEndian.PutUint64(buf[0:], uint64(b.Offset))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(b.Size))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(b.Count))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(b.FirstID))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(b.LastID))
+	binary.LittleEndian.PutUint64(buf[32:], uint64(b.MinTS))
+	binary.LittleEndian.PutUint64(buf[40:], uint64(b.MaxTS))
+	binary.LittleEndian.PutUint64(buf[48:], uint64(b.MinAggID))
+	binary.LittleEndian.PutUint64(buf[56:], uint64(b.MaxAggID))
+	return buf
+}
+
+func decodeIndexEntry(buf []byte) blockIndex {
+	return blockIndex{
+		Offset:  int64(binary.LittleEndian.Uint64(buf[0:])),
+		Size:    int64(binary.LittleEndian.Uint32(buf[8:])),
+		Count:   int64(binary.LittleEndian.Uint32(buf[12:])),
+		FirstID: int64(binary.LittleEndian.Uint64(buf[16:])),
+		LastID:  int64(binary.LittleEndian.Uint64(buf[24:])),
+		MinTS:   int64(binary.LittleEndian.Uint64(buf[32:])),
+		MaxTS:   int64(binary.LittleEndian.Uint64(buf[40:])),
+
+		MinAggID: int64(binary.LittleEndian.Uint64(buf[48:])),
+		MaxAggID: int64(binary.LittleEndian.Uint64(buf[56:])),
+	}
+}
+
+// segment is a pair of append-only files: <first id>.seg holds compressed
+// blocks back to back and <first id>.idx holds one fixed-size entry per
+// block. The index entry is written after its block, so a crash leaves at
+// most an unindexed tail that open truncates away.
+type segment struct {
+	data   *os.File
+	index  *os.File
+	blocks []blockIndex
+	size   int64
+}
+
+func openSegment(base string, create bool) (*segment, error) {
+	flags := os.O_RDWR | os.O_APPEND
+	if create {
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	data, err := os.OpenFile(base+".seg", flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open segment: %w", err)
+	}
+	index, err := os.OpenFile(base+".idx", flags, 0644)
+	if err != nil {
+		data.Close()
+		return nil, fmt.Errorf("open segment index: %w", err)
+	}
+
+	seg := &segment{data: data, index: index}
+	if err := seg.load(); err != nil {
+		seg.close()
+		return nil, err
+	}
+	return seg, nil
+}
+
+// load reads the index and truncates both files to the last complete block.
+func (seg *segment) load() error {
+	raw, err := io.ReadAll(io.NewSectionReader(seg.index, 0, math.MaxInt64))
+	if err != nil {
+		return fmt.Errorf("read segment index: %w", err)
+	}
+	n := len(raw) / indexEntrySize
+	if len(raw) != n*indexEntrySize {
+		if err := seg.index.Truncate(int64(n * indexEntrySize)); err != nil {
+			return fmt.Errorf("truncate segment index: %w", err)
+		}
+	}
+
+	seg.blocks = make([]blockIndex, n)
+	for i := range seg.blocks {
+		seg.blocks[i] = decodeIndexEntry(raw[i*indexEntrySize:])
+	}
+	if n > 0 {
+		last := seg.blocks[n-1]
+		seg.size = last.Offset + last.Size
+	}
+
+	info, err := seg.data.Stat()
+	if err != nil {
+		return fmt.Errorf("stat segment: %w", err)
+	}
+	if info.Size() < seg.size {
+		return fmt.Errorf("segment %s is shorter than its index", seg.data.Name())
+	}
+	if info.Size() > seg.size {
+		if err := seg.data.Truncate(seg.size); err != nil {
+			return fmt.Errorf("truncate segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// append writes one block of ticks in id order. decimals is the symbol's
+// storage scale, or -1 if unknown.
+func (seg *segment) append(prices []database.Price, decimals int) (blockIndex, error) {
+	payload, err := encodeBlock(prices, decimals)
+	if err != nil {
+		return blockIndex{}, err
+	}
+
+	b := blockIndex{
+		Offset:  seg.size,
+		Size:    int64(len(payload)),
+		Count:   int64(len(prices)),
+		FirstID: prices[0].ID,
+		LastID:  prices[len(prices)-1].ID,
+		MinTS:   math.MaxInt64,
+		MaxTS:   math.MinInt64,
+	}
+	for _, p := range prices {
+		b.MinTS = min(b.MinTS, p.Timestamp)
+		b.MaxTS = max(b.MaxTS, p.Timestamp)
+	}
+	b.setAggRange(prices)
+
+	if _, err := seg.data.Write(payload); err != nil {
+		seg.data.Truncate(seg.size)
+		return blockIndex{}, fmt.Errorf("write block: %w", err)
+	}
+	if _, err := seg.index.Write(b.encode()); err != nil {
+		seg.data.Truncate(seg.size)
+		seg.index.Truncate(int64(len(seg.blocks) * indexEntrySize))
+		return blockIndex{}, fmt.Errorf("write block index: %w", err)
+	}
+
+	seg.blocks = append(seg.blocks, b)
+	seg.size += b.Size
+	return b, nil
+}
+
+// setAggRange sets the aggregate id range from the block's ticks.
+func (b *blockIndex) setAggRange(prices []database.Price) {
+	b.MinAggID, b.MaxAggID = math.MaxInt64, math.MinInt64
+	for _, p := range prices {
+		if p.AggID != 0 {
+			b.MinAggID = min(b.MinAggID, p.AggID)
+			b.MaxAggID = max(b.MaxAggID, p.AggID)
+		}
+	}
+}
+
+func (seg *segment) read(b blockIndex) ([]database.Price, error) {
+	buf := make([]byte, b.Size)
+	if _, err := seg.data.ReadAt(buf, b.Offset); err != nil {
+		return nil, fmt.Errorf("read block: %w", err)
+	}
+	return decodeBlock(buf)
+}
+
+func (seg *segment) sync() error {
+	return errors.Join(seg.data.Sync(), seg.index.Sync())
+}
+
+func (seg *segment) close() error {
+	return errors.Join(seg.data.Close(), seg.index.Close())
+}
+
+// encodeBlock delta-encodes prices and deflates the result:
+//
+//	encoding byte, [decimals byte,] count uvarint, then per tick
+//	id delta uvarint, agg_id delta varint, timestamp delta varint, price delta varint
+//
+// Deltas are taken against the previous tick (zero for the first), and the
+// price is the value times 10^decimals, the value times 1e8 or its IEEE 754
+// bits, whichever is the first to hold every price of the block exactly.
+// decimals is -1 to skip the first.
+func encodeBlock(prices []database.Price, decimals int) ([]byte, error) {
+	encoding, scale := byte(encodingRaw), 0.0
+	switch {
+	case decimals >= 0 && decimals <= maxDecimals && fitsScale(prices, math.Pow10(decimals)):
+		encoding, scale = encodingDecimal, math.Pow10(decimals)
+	case fitsScale(prices, priceScale):
+		encoding, scale = encodingScaled, priceScale
+	}
+
+	raw := make([]byte, 0, 2+binary.MaxVarintLen64+len(prices)*12)
+	raw = append(raw, encoding)
+	if encoding == encodingDecimal {
+		raw = append(raw, byte(decimals))
+	}
+	raw = binary.AppendUvarint(raw, uint64(len(prices)))
+
+	var prev database.Price
+	var prevPrice int64
+	for _, p := range prices {
+		price := int64(math.Float64bits(p.Price))
+		if encoding != encodingRaw {
+			price, _ = scaledPrice(p.Price, scale)
+		}
+		raw = binary.AppendUvarint(raw, uint64(p.ID-prev.ID))
+		raw = binary.AppendVarint(raw, p.AggID-prev.AggID)
+		raw = binary.AppendVarint(raw, p.Timestamp-prev.Timestamp)
+		raw = binary.AppendVarint(raw, price-prevPrice)
+		prev, prevPrice = p, price
+	}
+
+	var buf bytes.Buffer
+	zw, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return nil, fmt.Errorf("compress block: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress block: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeBlock(payload []byte) ([]database.Price, error) {
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("decompress block: %w", err)
+	}
+
+	r := bytes.NewReader(raw)
+	encoding, err := r.ReadByte()
+	scale := float64(priceScale)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("corrupt block: %w", err)
+	case encoding == encodingDecimal:
+		decimals, err := r.ReadByte()
+		if err != nil || decimals > maxDecimals {
+			return nil, fmt.Errorf("corrupt block: bad decimals")
+		}
+		scale = math.Pow10(int(decimals))
+	case encoding != encodingScaled && encoding != encodingRaw:
+		return nil, fmt.Errorf("corrupt block: bad encoding")
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt block: %w", err)
+	}
+	// Each tick takes at least one byte per field
+	if count > uint64(r.Len())/4 {
+		return nil, fmt.Errorf("corrupt block: %d ticks in %d bytes", count, r.Len())
+	}
+
+	prices := make([]database.Price, count)
+	var prev database.Price
+	var prevPrice int64
+	for i := range prices {
+		id, err1 := binary.ReadUvarint(r)
+		aggID, err2 := binary.ReadVarint(r)
+		ts, err3 := binary.ReadVarint(r)
+		price, err4 := binary.ReadVarint(r)
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("corrupt block: %w", err)
+		}
+
+		p := database.Price{
+			ID:        prev.ID + int64(id),
+			AggID:     prev.AggID + aggID,
+			Timestamp: prev.Timestamp + ts,
+		}
+		prevPrice += price
+		if encoding != encodingRaw {
+			p.Price = float64(prevPrice) / scale
+		} else {
+			p.Price = math.Float64frombits(uint64(prevPrice))
+		}
+		prices[i] = p
+		prev = p
+	}
+	return prices, nil
+}
+
+// fitsScale reports whether every price survives scaledPrice.
+func fitsScale(prices []database.Price, scale float64) bool {
+	for _, p := range prices {
+		if _, ok := scaledPrice(p.Price, scale); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// scaledPrice returns price*scale if that integer converts back to exactly
+// the same float.
+func scaledPrice(price, scale float64) (int64, bool) {
+	scaled := math.Round(price * scale)
+	if math.Abs(scaled) > 1<<62 || scaled/scale != price {
+		return 0, false
+	}
+	return int64(scaled), true
+}
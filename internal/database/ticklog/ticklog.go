@@ -0,0 +1,663 @@
+// Package ticklog is a compact storage engine for high-volume symbols. Ticks
+// are appended to per-symbol segment files as delta-encoded, deflated blocks
+// of up to blockTicks ticks; a fixed-size index entry per block records its
+// id, timestamp and aggregate id range so time range reads and duplicate
+// checks only decode the blocks they need. A tick costs a few bytes on disk
+// instead of the ~40 of a SQLite row and its timestamp index.
+//
+// Layout under the log directory:
+//
+//	<SYMBOL>/<first id>.seg   compressed blocks
+//	<SYMBOL>/<first id>.idx   64-byte index entry per block
+//
+// Ticks are buffered in memory until a block fills or flushDelay passes, so
+// a crash loses at most that much. Duplicate counts are kept in memory and
+// restart from zero when the log is reopened. Symbol settings, contract
+// metadata and the export log are kept by a separate metadata store; a
+// symbol's tick size sets the integer scale its prices are stored at.
+package ticklog
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	blockTicks  = 4096
+	segmentSize = 64 << 20
+	flushDelay  = time.Second
+)
+
+// Log is a database.Store that keeps ticks in segment files. Settings and
+// export records are delegated to meta.
+type Log struct {
+	dir     string
+	meta    database.Store
+	mu      sync.Mutex // guards symbols
+	symbols map[string]*symbolLog
+}
+
+// Open opens or creates a tick log in dir. The log takes ownership of meta
+// and closes it on Close.
+func Open(dir string, meta database.Store) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	return &Log{
+		dir:     dir,
+		meta:    meta,
+		symbols: make(map[string]*symbolLog),
+	}, nil
+}
+
+// Close flushes buffered ticks and closes all segments.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for _, sl := range l.symbols {
+		errs = append(errs, sl.close())
+	}
+	l.symbols = make(map[string]*symbolLog)
+	errs = append(errs, l.meta.Close())
+	return errors.Join(errs...)
+}
+
+// Flush writes buffered ticks of every open symbol to disk.
+func (l *Log) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for _, sl := range l.symbols {
+		sl.mu.Lock()
+		errs = append(errs, sl.flush())
+		sl.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Log) GetSymbolSettings() ([]database.SymbolSettings, error) {
+	return l.meta.GetSymbolSettings()
+}
+
+func (l *Log) SetSymbolEnabled(symbol string, enabled bool) error {
+	return l.meta.SetSymbolEnabled(symbol, enabled)
+}
+
+// RetireSymbol marks symbol retired in the metadata store, which must be a
+// database.Retirer, and deletes its segments.
+func (l *Log) RetireSymbol(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	symbol = strings.ToUpper(symbol)
+
+	retirer, ok := l.meta.(database.Retirer)
+	if !ok {
+		return fmt.Errorf("metadata store cannot retire symbols")
+	}
+	if err := retirer.RetireSymbol(symbol); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sl, ok := l.symbols[symbol]; ok {
+		sl.close()
+		delete(l.symbols, symbol)
+	}
+	if err := os.RemoveAll(filepath.Join(l.dir, symbol)); err != nil {
+		return fmt.Errorf("remove symbol directory: %w", err)
+	}
+	return nil
+}
+
+// MarkDelisted records the delisting in the metadata store, which must be a
+// database.Delister. The symbol's ticks are kept.
+func (l *Log) MarkDelisted(symbol, successor string) error {
+	delister, ok := l.meta.(database.Delister)
+	if !ok {
+		return fmt.Errorf("metadata store cannot mark symbols delisted")
+	}
+	return delister.MarkDelisted(symbol, successor)
+}
+
+// SetSymbolConnections stores the connections in the metadata store, which
+// must be a database.Redundancer.
+func (l *Log) SetSymbolConnections(symbol string, n int) error {
+	r, ok := l.meta.(database.Redundancer)
+	if !ok {
+		return fmt.Errorf("metadata store cannot set connections")
+	}
+	return r.SetSymbolConnections(symbol, n)
+}
+
+// SetSymbolMetadata stores contract metadata in the metadata store, which
+// must be a database.MetadataStore. Blocks written from then on use the
+// tick size's scale; earlier blocks keep theirs.
+func (l *Log) SetSymbolMetadata(meta database.SymbolMetadata) error {
+	ms, ok := l.meta.(database.MetadataStore)
+	if !ok {
+		return fmt.Errorf("metadata store cannot keep contract metadata")
+	}
+	if err := ms.SetSymbolMetadata(meta); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	sl := l.symbols[strings.ToUpper(meta.Symbol)]
+	l.mu.Unlock()
+	if sl != nil {
+		sl.mu.Lock()
+		sl.decimals = meta.TickDecimals()
+		sl.mu.Unlock()
+	}
+	return nil
+}
+
+func (l *Log) GetSymbolMetadata() ([]database.SymbolMetadata, error) {
+	ms, ok := l.meta.(database.MetadataStore)
+	if !ok {
+		return nil, nil
+	}
+	return ms.GetSymbolMetadata()
+}
+
+// PriceSymbols lists the symbol directories. Other entries, such as the
+// metadata store's directory, are not upper case and are skipped.
+func (l *Log) PriceSymbols() ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list symbols: %w", err)
+	}
+	var symbols []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() && name == strings.ToUpper(name) && database.ValidateSymbol(name) == nil {
+			symbols = append(symbols, name)
+		}
+	}
+	return symbols, nil
+}
+
+func (l *Log) MarkExported(rec database.ExportRecord) error {
+	return l.meta.MarkExported(rec)
+}
+
+func (l *Log) GetExportRecords(symbol string) ([]database.ExportRecord, error) {
+	return l.meta.GetExportRecords(symbol)
+}
+
+func (l *Log) EnsurePriceTable(symbol string) error {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return err
+	}
+	_, err := l.symbol(symbol, true)
+	return err
+}
+
+func (l *Log) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
+	sl, err := l.symbol(symbol, false)
+	if err != nil {
+		return err
+	}
+	if sl == nil {
+		return fmt.Errorf("no tick log for symbol %s", symbol)
+	}
+
+	_, err = sl.append([]database.Price{{AggID: aggID, Timestamp: timestamp, Price: price}}, true)
+	return err
+}
+
+func (l *Log) InsertPrices(symbol string, prices []database.Price) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	sl, err := l.symbol(symbol, true)
+	if err != nil {
+		return 0, err
+	}
+	return sl.append(prices, true)
+}
+
+func (l *Log) GetDateRange(symbol string) (database.DateRange, error) {
+	stats, err := l.GetStats(symbol)
+	if err != nil {
+		return database.DateRange{}, err
+	}
+	return database.DateRange{From: stats.FirstTS, To: stats.LastTS}, nil
+}
+
+func (l *Log) GetCount(symbol string) (int64, error) {
+	stats, err := l.GetStats(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Count, nil
+}
+
+func (l *Log) GetStats(symbol string) (database.SymbolStats, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return database.SymbolStats{}, err
+	}
+
+	stats := database.SymbolStats{Symbol: strings.ToUpper(symbol)}
+	sl, err := l.symbol(symbol, false)
+	if err != nil || sl == nil {
+		return stats, err
+	}
+	return sl.stats(stats), nil
+}
+
+// ReconcileStats recomputes the stats from the block index.
+func (l *Log) ReconcileStats(symbol string) (int64, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return 0, err
+	}
+	sl, err := l.symbol(symbol, false)
+	if err != nil || sl == nil {
+		return 0, err
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	stored := sl.count
+	sl.recount()
+	return sl.count - stored, nil
+}
+
+func (l *Log) GetPrices(symbol string, q database.PriceQuery) ([]database.Price, error) {
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = database.DefaultPageSize
+	}
+
+	sl, err := l.symbol(symbol, false)
+	if err != nil || sl == nil {
+		return nil, err
+	}
+	return sl.query(q)
+}
+
+// symbol returns the open log for symbol, opening its directory on first
+// use. Missing logs are created only when create is set; otherwise symbol
+// returns nil.
+func (l *Log) symbol(symbol string, create bool) (*symbolLog, error) {
+	symbol = strings.ToUpper(symbol)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sl, ok := l.symbols[symbol]; ok {
+		return sl, nil
+	}
+
+	dir := filepath.Join(l.dir, symbol)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return nil, nil
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create symbol directory: %w", err)
+		}
+	}
+
+	decimals, err := l.tickDecimals(symbol)
+	if err != nil {
+		return nil, err
+	}
+	sl, err := openSymbolLog(symbol, dir)
+	if err != nil {
+		return nil, err
+	}
+	sl.decimals = decimals
+	l.symbols[symbol] = sl
+	return sl, nil
+}
+
+// tickDecimals returns the decimals of symbol's tick size, or -1 if its
+// contract metadata is unknown.
+func (l *Log) tickDecimals(symbol string) (int, error) {
+	metas, err := database.MetadataBySymbol(l.meta)
+	if err != nil {
+		return -1, err
+	}
+	if meta, ok := metas[symbol]; ok {
+		return meta.TickDecimals(), nil
+	}
+	return -1, nil
+}
+
+// blockRef locates a block within the symbol's segments.
+type blockRef struct {
+	seg *segment
+	blockIndex
+}
+
+type symbolLog struct {
+	symbol   string
+	dir      string
+	mu       sync.RWMutex
+	segments []*segment
+	blocks   []blockRef // all blocks in id order
+	ordered  bool       // each block starts at or after the previous one ends
+	pending  []database.Price
+	timer    *time.Timer // flushes pending after flushDelay
+	nextID   int64
+	decimals int // price storage scale from the tick size, -1 if unknown
+
+	count      int64
+	minTS      int64
+	maxTS      int64
+	lastInsert time.Time
+	maxAggID   int64              // highest stored aggregate id
+	aggBlock   int                // index+1 of the block aggIDs holds, 0 if none
+	aggIDs     map[int64]struct{} // aggregate ids of the last block checked for a duplicate
+	duplicates int64              // dropped since the log was opened
+}
+
+func openSymbolLog(symbol, dir string) (*symbolLog, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names) // names are zero-padded first ids
+
+	sl := &symbolLog{symbol: symbol, dir: dir, nextID: 1, decimals: -1}
+	for _, name := range names {
+		seg, err := openSegment(strings.TrimSuffix(name, ".seg"), false)
+		if err != nil {
+			sl.closeSegments()
+			return nil, err
+		}
+		sl.segments = append(sl.segments, seg)
+		for _, b := range seg.blocks {
+			sl.blocks = append(sl.blocks, blockRef{seg: seg, blockIndex: b})
+			sl.maxAggID = max(sl.maxAggID, b.MaxAggID)
+		}
+		if info, err := seg.index.Stat(); err == nil {
+			sl.lastInsert = info.ModTime()
+		}
+	}
+	if n := len(sl.blocks); n > 0 {
+		sl.nextID = sl.blocks[n-1].LastID + 1
+	}
+	sl.recount()
+	return sl, nil
+}
+
+// recount rebuilds stats and the ordered flag from the block index. Must be
+// called with sl.mu held for writing.
+func (sl *symbolLog) recount() {
+	sl.count = 0
+	sl.minTS, sl.maxTS = math.MaxInt64, math.MinInt64
+	sl.ordered = true
+	for _, b := range sl.blocks {
+		if sl.count > 0 && b.MinTS < sl.maxTS {
+			sl.ordered = false
+		}
+		sl.count += b.Count
+		sl.minTS = min(sl.minTS, b.MinTS)
+		sl.maxTS = max(sl.maxTS, b.MaxTS)
+	}
+	for _, p := range sl.pending {
+		sl.count++
+		sl.minTS = min(sl.minTS, p.Timestamp)
+		sl.maxTS = max(sl.maxTS, p.Timestamp)
+	}
+}
+
+// append buffers prices, assigning ids. With dedupe set, ticks whose
+// aggregate id is already stored are dropped and counted. Binance ids only
+// grow, so only a tick at or below the highest stored id is looked up, in
+// the pending ticks and the blocks whose aggregate id range holds it.
+func (sl *symbolLog) append(prices []database.Price, dedupe bool) (int64, error) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	var added int64
+	for _, p := range prices {
+		if p.AggID != 0 {
+			if dedupe && p.AggID <= sl.maxAggID {
+				stored, err := sl.hasAggID(p.AggID)
+				if err != nil {
+					return added, err
+				}
+				if stored {
+					sl.duplicates++
+					continue
+				}
+			}
+			sl.maxAggID = max(sl.maxAggID, p.AggID)
+		}
+
+		p.ID = sl.nextID
+		sl.nextID++
+		sl.pending = append(sl.pending, p)
+		sl.count++
+		sl.minTS = min(sl.minTS, p.Timestamp)
+		sl.maxTS = max(sl.maxTS, p.Timestamp)
+		added++
+
+		if len(sl.pending) == blockTicks {
+			if err := sl.flush(); err != nil {
+				return added, err
+			}
+		}
+	}
+	if added > 0 {
+		sl.lastInsert = time.Now()
+	}
+
+	if len(sl.pending) > 0 && sl.timer == nil {
+		sl.timer = time.AfterFunc(flushDelay, func() {
+			sl.mu.Lock()
+			defer sl.mu.Unlock()
+			if err := sl.flush(); err != nil {
+				slog.Error("tick log flush failed", "symbol", sl.symbol, "error", err)
+			}
+		})
+	}
+	return added, nil
+}
+
+// flush writes pending ticks as one block. Must be called with sl.mu held
+// for writing.
+func (sl *symbolLog) flush() error {
+	if sl.timer != nil {
+		sl.timer.Stop()
+		sl.timer = nil
+	}
+	if len(sl.pending) == 0 {
+		return nil
+	}
+
+	seg := sl.active()
+	if seg == nil || seg.size >= segmentSize {
+		base := filepath.Join(sl.dir, fmt.Sprintf("%020d", sl.pending[0].ID))
+		var err error
+		if seg, err = openSegment(base, true); err != nil {
+			return err
+		}
+		sl.segments = append(sl.segments, seg)
+	}
+
+	b, err := seg.append(sl.pending, sl.decimals)
+	if err != nil {
+		return err
+	}
+	if n := len(sl.blocks); n > 0 && b.MinTS < sl.blockMaxTS() {
+		sl.ordered = false
+	}
+	sl.blocks = append(sl.blocks, blockRef{seg: seg, blockIndex: b})
+	sl.pending = sl.pending[:0]
+	return nil
+}
+
+func (sl *symbolLog) active() *segment {
+	if len(sl.segments) == 0 {
+		return nil
+	}
+	return sl.segments[len(sl.segments)-1]
+}
+
+// blockMaxTS is the latest timestamp of any written block. With ordered
+// blocks that is the last block's.
+func (sl *symbolLog) blockMaxTS() int64 {
+	if sl.ordered {
+		return sl.blocks[len(sl.blocks)-1].MaxTS
+	}
+	ts := int64(math.MinInt64)
+	for _, b := range sl.blocks {
+		ts = max(ts, b.MaxTS)
+	}
+	return ts
+}
+
+// hasAggID reports whether a tick with aggregate id id is stored, decoding
+// only blocks whose range holds it. The ids of the last such block are
+// kept, since duplicates tend to arrive in runs. Must be called with sl.mu
+// held for writing.
+func (sl *symbolLog) hasAggID(id int64) (bool, error) {
+	for _, p := range sl.pending {
+		if p.AggID == id {
+			return true, nil
+		}
+	}
+	for i := len(sl.blocks) - 1; i >= 0; i-- {
+		b := sl.blocks[i]
+		if !b.hasAggID(id) {
+			continue
+		}
+		if sl.aggBlock != i+1 {
+			prices, err := b.seg.read(b.blockIndex)
+			if err != nil {
+				return false, err
+			}
+			sl.aggIDs = make(map[int64]struct{}, len(prices))
+			for _, p := range prices {
+				sl.aggIDs[p.AggID] = struct{}{}
+			}
+			sl.aggBlock = i + 1
+		}
+		if _, ok := sl.aggIDs[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (sl *symbolLog) stats(stats database.SymbolStats) database.SymbolStats {
+	sl.mu.RLock()
+	defer sl.mu.RUnlock()
+
+	stats.Count = sl.count
+	stats.Duplicates = sl.duplicates
+	if sl.count > 0 {
+		first := time.UnixMilli(sl.minTS).UTC()
+		last := time.UnixMilli(sl.maxTS).UTC()
+		insert := sl.lastInsert.UTC()
+		stats.FirstTS, stats.LastTS, stats.LastInsertAt = &first, &last, &insert
+	}
+	return stats
+}
+
+// query visits blocks in order of their earliest timestamp and stops once
+// the page is full and no remaining block can hold an earlier tick.
+func (sl *symbolLog) query(q database.PriceQuery) ([]database.Price, error) {
+	sl.mu.RLock()
+	defer sl.mu.RUnlock()
+
+	from, match := q.Start(), q.Includes
+
+	var candidates []database.Price
+	for _, p := range sl.pending {
+		if match(p) {
+			candidates = append(candidates, p)
+		}
+	}
+
+	blocks := sl.blocks
+	if sl.ordered {
+		start := sort.Search(len(blocks), func(i int) bool { return blocks[i].MaxTS >= from })
+		blocks = blocks[start:]
+	} else {
+		blocks = append([]blockRef(nil), blocks...)
+		sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].MinTS < blocks[j].MinTS })
+	}
+
+	for _, b := range blocks {
+		if b.MinTS >= q.To {
+			break
+		}
+		if b.MaxTS < from {
+			continue
+		}
+		if len(candidates) >= q.Limit {
+			candidates = firstN(candidates, q.Limit)
+			if b.MinTS > candidates[q.Limit-1].Timestamp {
+				break
+			}
+		}
+
+		prices, err := b.seg.read(b.blockIndex)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prices {
+			if match(p) {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+	return firstN(candidates, q.Limit), nil
+}
+
+// firstN sorts prices by (timestamp, id) and keeps the first n.
+func firstN(prices []database.Price, n int) []database.Price {
+	sort.Slice(prices, func(i, j int) bool {
+		if prices[i].Timestamp != prices[j].Timestamp {
+			return prices[i].Timestamp < prices[j].Timestamp
+		}
+		return prices[i].ID < prices[j].ID
+	})
+	if len(prices) > n {
+		prices = prices[:n]
+	}
+	return prices
+}
+
+func (sl *symbolLog) close() error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	err := sl.flush()
+	for _, seg := range sl.segments {
+		err = errors.Join(err, seg.sync())
+	}
+	return errors.Join(err, sl.closeSegments())
+}
+
+func (sl *symbolLog) closeSegments() error {
+	var errs []error
+	for _, seg := range sl.segments {
+		errs = append(errs, seg.close())
+	}
+	sl.segments = nil
+	return errors.Join(errs...)
+}
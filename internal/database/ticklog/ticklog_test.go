@@ -0,0 +1,283 @@
+package ticklog
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/database/flatfile"
+	"binance-tick-store/internal/database/storetest"
+)
+
+func openLog(t *testing.T, dir string) *Log {
+	t.Helper()
+	meta, err := flatfile.Open(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("open meta: %v", err)
+	}
+	l, err := Open(dir, meta)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return l
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return openLog(t, t.TempDir())
+	})
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		decimals int
+		prices   []database.Price
+	}{
+		{-1, []database.Price{{ID: 1, AggID: 10, Timestamp: 1000, Price: 42000.12345678}, {ID: 2, AggID: 9, Timestamp: 999, Price: 41999.5}}},
+		{-1, []database.Price{{ID: 5, AggID: 1, Timestamp: -5, Price: math.Pi}, {ID: 7, AggID: 2, Timestamp: 5, Price: 1e-12}}},
+		{1, []database.Price{{ID: 1, AggID: 1, Timestamp: 1000, Price: 42000.1}, {ID: 2, AggID: 2, Timestamp: 1001, Price: 41999.9}}},
+		// Off-tick prices fall back to a scale that holds them
+		{1, []database.Price{{ID: 1, AggID: 1, Timestamp: 1000, Price: 42000.1}, {ID: 2, AggID: 2, Timestamp: 1001, Price: 42000.15}}},
+		{0, []database.Price{{ID: 1, AggID: 1, Timestamp: 1000, Price: 0.5}, {ID: 2, AggID: 2, Timestamp: 1001, Price: math.Pi}}},
+	} {
+		prices := tc.prices
+		payload, err := encodeBlock(prices, tc.decimals)
+		if err != nil {
+			t.Fatalf("encodeBlock failed: %v", err)
+		}
+		got, err := decodeBlock(payload)
+		if err != nil {
+			t.Fatalf("decodeBlock failed: %v", err)
+		}
+		if len(got) != len(prices) {
+			t.Fatalf("expected %d ticks, got %d", len(prices), len(got))
+		}
+		for i := range prices {
+			if got[i] != prices[i] {
+				t.Errorf("tick %d: expected %+v, got %+v", i, prices[i], got[i])
+			}
+		}
+	}
+}
+
+func TestDecodeBlockRejectsBadCount(t *testing.T) {
+	// A block claiming 2^40 ticks in a few bytes
+	raw := binary.AppendUvarint([]byte{encodingScaled}, 1<<40)
+	raw = append(raw, 1, 1, 1, 1)
+	var buf bytes.Buffer
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	zw.Write(raw)
+	zw.Close()
+
+	if _, err := decodeBlock(buf.Bytes()); err == nil {
+		t.Error("expected an error for a count larger than the block")
+	}
+}
+
+func TestTickSizeScale(t *testing.T) {
+	prices := make([]database.Price, blockTicks)
+	for i := range prices {
+		prices[i] = database.Price{
+			ID:        int64(i + 1),
+			AggID:     int64(i + 1),
+			Timestamp: int64(i) * 37,
+			Price:     float64(4200000+(i*7919)%1000) / 100,
+		}
+	}
+	fine, _ := encodeBlock(prices, -1)
+	coarse, _ := encodeBlock(prices, 2)
+	if len(coarse) >= len(fine) {
+		t.Errorf("expected the tick size scale to shrink the block, got %d bytes vs %d", len(coarse), len(fine))
+	}
+
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	l.EnsurePriceTable("BTCUSDT")
+	meta := database.SymbolMetadata{Symbol: "BTCUSDT", ContractType: "PERPETUAL", TickSize: "0.01", StepSize: "0.001", PricePrecision: 2}
+	if err := l.SetSymbolMetadata(meta); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+	if _, err := l.InsertPrices("BTCUSDT", prices); err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	l.Close()
+
+	l = openLog(t, dir)
+	defer l.Close()
+	if sl, _ := l.symbol("BTCUSDT", false); sl.decimals != 2 {
+		t.Errorf("expected the scale to be restored on open, got %d decimals", sl.decimals)
+	}
+	got, err := l.GetPrices("BTCUSDT", database.PriceQuery{From: 0, To: math.MaxInt64, Limit: blockTicks})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(got) != len(prices) {
+		t.Fatalf("expected %d ticks, got %d", len(prices), len(got))
+	}
+	for i := range prices {
+		if got[i] != prices[i] {
+			t.Fatalf("tick %d: expected %+v, got %+v", i, prices[i], got[i])
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+
+	const n = 3 * blockTicks
+	prices := make([]database.Price, n)
+	for i := range prices {
+		prices[i] = database.Price{
+			AggID:     3000000000 + int64(i),
+			Timestamp: 1700000000000 + int64(i)*37,
+			Price:     42000 + float64(i%50)/100,
+		}
+	}
+	if _, err := l.InsertPrices("BTCUSDT", prices); err != nil {
+		t.Fatalf("InsertPrices failed: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "BTCUSDT", "00000000000000000001.seg"))
+	if err != nil {
+		t.Fatalf("stat segment: %v", err)
+	}
+	if perTick := float64(info.Size()) / n; perTick > 4 {
+		t.Errorf("expected under 4 bytes per tick, got %.1f", perTick)
+	}
+
+	// Reopen and read a range from the middle
+	l = openLog(t, dir)
+	defer l.Close()
+	from := prices[5000].Timestamp
+	got, err := l.GetPrices("BTCUSDT", database.PriceQuery{From: from, To: math.MaxInt64, Limit: 3})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(got) != 3 || got[0].Timestamp != from || got[0].ID != 5001 || got[2].Price != prices[5002].Price {
+		t.Errorf("unexpected page: %+v", got)
+	}
+	if count, _ := l.GetCount("BTCUSDT"); count != n {
+		t.Errorf("expected %d ticks after reopen, got %d", n, count)
+	}
+}
+
+func TestOutOfOrderBlocks(t *testing.T) {
+	l := openLog(t, t.TempDir())
+	defer l.Close()
+
+	l.EnsurePriceTable("BTCUSDT")
+	for _, ts := range []int64{5000, 6000, 1000, 2000, 3000} {
+		l.InsertPrices("BTCUSDT", []database.Price{{AggID: ts, Timestamp: ts, Price: 1}})
+		l.Flush() // one block per tick
+	}
+
+	var got []int64
+	it := database.NewPriceIterator(l, "BTCUSDT", 0, math.MaxInt64, 2)
+	for it.Next() {
+		got = append(got, it.Price().Timestamp)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator failed: %v", err)
+	}
+	want := []int64{1000, 2000, 3000, 5000, 6000}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestReopenTruncatesTornBlock(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	l.InsertPrices("BTCUSDT", []database.Price{{AggID: 1, Timestamp: 1000, Price: 1}})
+	l.Close()
+
+	// A block written without its index entry
+	f, _ := os.OpenFile(filepath.Join(dir, "BTCUSDT", "00000000000000000001.seg"), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{1, 2, 3, 4})
+	f.Close()
+
+	l = openLog(t, dir)
+	defer l.Close()
+	n, err := l.InsertPrices("BTCUSDT", []database.Price{{AggID: 1, Timestamp: 1000}, {AggID: 2, Timestamp: 2000, Price: 2}})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertPrices = %d, %v", n, err)
+	}
+	l.Flush()
+
+	prices, err := l.GetPrices("BTCUSDT", database.PriceQuery{To: math.MaxInt64})
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(prices) != 2 || prices[1].ID != 2 || prices[1].Price != 2 {
+		t.Errorf("unexpected prices: %+v", prices)
+	}
+}
+
+func TestDedupeAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	for agg := int64(1); agg <= 3; agg++ {
+		l.InsertPrices("BTCUSDT", []database.Price{{AggID: agg * 10, Timestamp: agg * 1000, Price: 1}})
+		l.Flush() // a block each
+	}
+	l.Close()
+
+	l = openLog(t, dir)
+	defer l.Close()
+	n, err := l.InsertPrices("BTCUSDT", []database.Price{{AggID: 20, Timestamp: 2000}, {AggID: 15, Timestamp: 1500, Price: 2}})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertPrices = %d, %v", n, err)
+	}
+	l.Flush()
+	if stats, _ := l.GetStats("BTCUSDT"); stats.Count != 4 || stats.Duplicates != 1 {
+		t.Errorf("expected 4 ticks and 1 duplicate, got %+v", stats)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	src, err := database.Open(filepath.Join(dir, "ticks.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer src.Close()
+
+	src.EnsurePriceTable("BTCUSDT")
+	for i, ts := range []int64{3000, 1000, 2000} {
+		src.InsertPrice("BTCUSDT", int64(i+1), ts, float64(ts))
+	}
+
+	l := openLog(t, filepath.Join(dir, "log"))
+	defer l.Close()
+
+	n, err := Convert(src, l, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 converted, got %d", n)
+	}
+
+	prices, _ := l.GetPrices("BTCUSDT", database.PriceQuery{To: math.MaxInt64})
+	if len(prices) != 3 || prices[0].Timestamp != 1000 || prices[0].AggID != 2 || prices[2].Price != 3000 {
+		t.Errorf("unexpected prices: %+v", prices)
+	}
+
+	if _, err := Convert(src, l, "BTCUSDT"); err == nil {
+		t.Error("expected error converting into a non-empty log")
+	}
+}
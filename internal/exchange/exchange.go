@@ -0,0 +1,135 @@
+// Package exchange reads symbol listings from the Binance futures REST API.
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+// requestTimeout bounds one exchange info request.
+const requestTimeout = 10 * time.Second
+
+// Symbol is a contract as listed by the exchange.
+type Symbol struct {
+	Symbol            string
+	Status            string
+	ContractType      string
+	TickSize          string // PRICE_FILTER tickSize
+	StepSize          string // LOT_SIZE stepSize
+	PricePrecision    int
+	QuantityPrecision int
+}
+
+// symbolJSON is a symbol as encoded in the exchange info response.
+type symbolJSON struct {
+	Symbol            string `json:"symbol"`
+	Status            string `json:"status"`
+	ContractType      string `json:"contractType"`
+	PricePrecision    int    `json:"pricePrecision"`
+	QuantityPrecision int    `json:"quantityPrecision"`
+	Filters           []struct {
+		FilterType string `json:"filterType"`
+		TickSize   string `json:"tickSize"`
+		StepSize   string `json:"stepSize"`
+	} `json:"filters"`
+}
+
+func (j symbolJSON) symbol() Symbol {
+	s := Symbol{
+		Symbol:            j.Symbol,
+		Status:            j.Status,
+		ContractType:      j.ContractType,
+		PricePrecision:    j.PricePrecision,
+		QuantityPrecision: j.QuantityPrecision,
+	}
+	for _, f := range j.Filters {
+		switch f.FilterType {
+		case "PRICE_FILTER":
+			s.TickSize = f.TickSize
+		case "LOT_SIZE":
+			s.StepSize = f.StepSize
+		}
+	}
+	return s
+}
+
+// Metadata returns the contract metadata to store for the symbol.
+func (s Symbol) Metadata(updated time.Time) database.SymbolMetadata {
+	return database.SymbolMetadata{
+		Symbol:            s.Symbol,
+		ContractType:      s.ContractType,
+		TickSize:          s.TickSize,
+		StepSize:          s.StepSize,
+		PricePrecision:    s.PricePrecision,
+		QuantityPrecision: s.QuantityPrecision,
+		UpdatedAt:         updated,
+	}
+}
+
+// Listed reports whether the exchange still streams trades for the symbol,
+// or is about to. Delivered, settled and closed contracts are not listed.
+func (s Symbol) Listed() bool {
+	switch s.Status {
+	case "TRADING", "PENDING_TRADING", "PRE_DELIVERING", "DELIVERING":
+		return true
+	default:
+		return false
+	}
+}
+
+// Client fetches exchange info.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a client for the REST API at baseURL, e.g.
+// https://fapi.binance.com or a local stand-in in tests.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Symbols returns every symbol the exchange lists, keyed by name.
+func (c *Client) Symbols(ctx context.Context) (map[string]Symbol, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/fapi/v1/exchangeInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch exchange info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fetch exchange info: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var info struct {
+		Symbols []symbolJSON `json:"symbols"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("parse exchange info: %w", err)
+	}
+	// An empty list would mark every symbol delisted
+	if len(info.Symbols) == 0 {
+		return nil, fmt.Errorf("exchange info lists no symbols")
+	}
+
+	symbols := make(map[string]Symbol, len(info.Symbols))
+	for _, s := range info.Symbols {
+		symbols[s.Symbol] = s.symbol()
+	}
+	return symbols, nil
+}
@@ -0,0 +1,167 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const exchangeInfo = `{"symbols":[
+	{"symbol":"BTCUSDT","status":"TRADING","contractType":"PERPETUAL","pricePrecision":2,"quantityPrecision":3,
+	 "filters":[{"filterType":"PRICE_FILTER","tickSize":"0.10"},{"filterType":"LOT_SIZE","stepSize":"0.001"}]},
+	{"symbol":"1000SHIBUSDT","status":"TRADING","contractType":"PERPETUAL"},
+	{"symbol":"LUNAUSDT","status":"SETTLING","contractType":"PERPETUAL"},
+	{"symbol":"NEWUSDT","status":"PENDING_TRADING","contractType":"PERPETUAL"}
+]}`
+
+func newExchange(t *testing.T, body string, code int) *Client {
+	client, _ := newCountingExchange(t, body, code)
+	return client
+}
+
+// newCountingExchange also returns the number of requests served.
+func newCountingExchange(t *testing.T, body string, code int) (*Client, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/exchangeInfo" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL + "/"), &requests
+}
+
+func TestClient_Symbols(t *testing.T) {
+	symbols, err := newExchange(t, exchangeInfo, http.StatusOK).Symbols(context.Background())
+	if err != nil {
+		t.Fatalf("Symbols failed: %v", err)
+	}
+	if len(symbols) != 4 || !symbols["BTCUSDT"].Listed() || symbols["LUNAUSDT"].Listed() || !symbols["NEWUSDT"].Listed() {
+		t.Errorf("unexpected symbols: %+v", symbols)
+	}
+	btc := symbols["BTCUSDT"]
+	if btc.TickSize != "0.10" || btc.StepSize != "0.001" || btc.PricePrecision != 2 || btc.QuantityPrecision != 3 {
+		t.Errorf("unexpected BTCUSDT contract: %+v", btc)
+	}
+
+	for _, c := range []struct {
+		body string
+		code int
+	}{
+		{`{"code":-1003,"msg":"banned"}`, http.StatusTeapot},
+		{`{"symbols":[]}`, http.StatusOK},
+		{`not json`, http.StatusOK},
+	} {
+		if _, err := newExchange(t, c.body, c.code).Symbols(context.Background()); err == nil {
+			t.Errorf("expected error for %d %s", c.code, c.body)
+		}
+	}
+}
+
+func TestMonitor_MarksDelisted(t *testing.T) {
+	store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	for symbol, enabled := range map[string]bool{
+		"BTCUSDT": true, "SHIBUSDT": true, "LUNAUSDT": false, "NEWUSDT": true,
+	} {
+		store.SetSymbolEnabled(symbol, enabled)
+	}
+
+	NewMonitor(store, NewListings(newExchange(t, exchangeInfo, http.StatusOK), 0), 0).RunOnce(context.Background())
+
+	settings, _ := store.GetSymbolSettings()
+	want := map[string]string{
+		"BTCUSDT":  database.StateActive,
+		"NEWUSDT":  database.StateActive,
+		"SHIBUSDT": database.StateDelisted, // missing
+		"LUNAUSDT": database.StateDelisted, // settling, paused before
+	}
+	for _, s := range settings {
+		if s.State != want[s.Symbol] || s.Successor != "" {
+			t.Errorf("%s: got state %q successor %q, want %q", s.Symbol, s.State, s.Successor, want[s.Symbol])
+		}
+	}
+
+	metas, _ := store.(database.MetadataStore).GetSymbolMetadata()
+	if len(metas) != 3 || metas[0].Symbol != "BTCUSDT" || metas[0].TickSize != "0.10" || metas[0].ContractType != "PERPETUAL" {
+		t.Errorf("expected metadata of listed symbols, got %+v", metas)
+	}
+
+	// A failed check changes nothing
+	store.SetSymbolEnabled("SHIBUSDT", true)
+	NewMonitor(store, NewListings(newExchange(t, "", http.StatusBadGateway), 0), 0).RunOnce(context.Background())
+	settings, _ = store.GetSymbolSettings()
+	for _, s := range settings {
+		if s.Symbol == "SHIBUSDT" && s.State != database.StateActive {
+			t.Errorf("expected SHIBUSDT untouched after a failed check, got %s", s.State)
+		}
+	}
+}
+
+func TestLikelySuccessor(t *testing.T) {
+	listed := map[string]Symbol{
+		"1000SHIBUSDT": {Status: "TRADING"},
+		"PEPEUSDT":     {Status: "TRADING"},
+		"1000XECUSDT":  {Status: "SETTLING"},
+	}
+	for symbol, want := range map[string]string{
+		"SHIBUSDT":     "1000SHIBUSDT",
+		"1000PEPEUSDT": "PEPEUSDT",
+		"XECUSDT":      "",
+		"LUNAUSDT":     "",
+	} {
+		if got := likelySuccessor(listed, symbol); got != want {
+			t.Errorf("likelySuccessor(%s) = %q, want %q", symbol, got, want)
+		}
+	}
+}
+
+func TestListings_CacheAndCheck(t *testing.T) {
+	client, requests := newCountingExchange(t, exchangeInfo, http.StatusOK)
+	listings := NewListings(client, time.Minute)
+	now := time.Now()
+	listings.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := listings.Check(ctx, "btcusdt"); err != nil {
+		t.Errorf("expected BTCUSDT listed: %v", err)
+	}
+	if err := listings.Check(ctx, "BTCUSTD"); !errors.Is(err, ErrNotListed) {
+		t.Errorf("expected ErrNotListed for a typo, got %v", err)
+	}
+	if err := listings.Check(ctx, "LUNAUSDT"); !errors.Is(err, ErrNotListed) {
+		t.Errorf("expected ErrNotListed for a settling contract, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected one request while the list is fresh, got %d", n)
+	}
+
+	now = now.Add(2 * time.Minute)
+	listings.Check(ctx, "BTCUSDT")
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected a refetch once stale, got %d requests", n)
+	}
+}
+
+func TestListings_UnreachableLetsSymbolsThrough(t *testing.T) {
+	listings := NewListings(newExchange(t, "", http.StatusServiceUnavailable), time.Minute)
+	if err := listings.Check(context.Background(), "BTCUSTD"); err != nil {
+		t.Errorf("expected no error without a list, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotListed is returned by Listings.Check for a symbol the exchange does
+// not list, or no longer trades.
+var ErrNotListed = errors.New("not listed by the exchange")
+
+// Listings caches the exchange's symbol list.
+type Listings struct {
+	client *Client
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex // held while fetching so concurrent callers share one request
+	symbols map[string]Symbol
+	fetched time.Time
+}
+
+// NewListings creates a cache that refetches the list once it is older than
+// maxAge; maxAge <= 0 fetches on every lookup.
+func NewListings(client *Client, maxAge time.Duration) *Listings {
+	return &Listings{client: client, maxAge: maxAge, now: time.Now}
+}
+
+// Refresh fetches the list, replacing the cached one.
+func (l *Listings) Refresh(ctx context.Context) (map[string]Symbol, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.refresh(ctx)
+}
+
+func (l *Listings) refresh(ctx context.Context) (map[string]Symbol, error) {
+	symbols, err := l.client.Symbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.symbols, l.fetched = symbols, l.now()
+	return symbols, nil
+}
+
+// Symbols returns the cached list, fetching it if it is missing or stale. If
+// the fetch fails, a stale list is returned along with the error.
+func (l *Listings) Symbols(ctx context.Context) (map[string]Symbol, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.symbols != nil && l.now().Sub(l.fetched) < l.maxAge {
+		return l.symbols, nil
+	}
+	symbols, err := l.refresh(ctx)
+	if err != nil {
+		return l.symbols, err
+	}
+	return symbols, nil
+}
+
+// Check returns an error wrapping ErrNotListed unless the exchange lists
+// symbol. When no list can be fetched the symbol is let through, so an
+// unreachable API does not stop capture.
+func (l *Listings) Check(ctx context.Context, symbol string) error {
+	symbol = strings.ToUpper(symbol)
+	symbols, err := l.Symbols(ctx)
+	if symbols == nil {
+		slog.Warn("cannot verify symbol listing", "symbol", symbol, "error", err)
+		return nil
+	}
+
+	s, ok := symbols[symbol]
+	switch {
+	case !ok:
+		return fmt.Errorf("%s is %w", symbol, ErrNotListed)
+	case !s.Listed():
+		return fmt.Errorf("%s is %w (status %s)", symbol, ErrNotListed, s.Status)
+	}
+	return nil
+}
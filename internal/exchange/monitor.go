@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+// Monitor refreshes the listings periodically. It marks symbols the
+// exchange no longer lists as delisted, which stops their clients instead of
+// leaving them connected to a silent stream, and stores the contract
+// metadata of every configured symbol.
+type Monitor struct {
+	store    database.Store
+	delister database.Delister
+	listings *Listings
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewMonitor creates a monitor that refreshes listings every interval.
+// store must implement database.Delister; metadata is stored if it is also
+// a database.MetadataStore.
+func NewMonitor(store database.Store, listings *Listings, interval time.Duration) *Monitor {
+	delister, _ := store.(database.Delister)
+	return &Monitor{
+		store:    store,
+		delister: delister,
+		listings: listings,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start checks once and then every interval until ctx is cancelled. It does
+// nothing if the interval is not positive or the store cannot record
+// delistings.
+func (m *Monitor) Start(ctx context.Context) {
+	if m.interval <= 0 || m.delister == nil {
+		return
+	}
+	go func() {
+		m.RunOnce(ctx)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce refreshes the listings, marks every active or paused symbol that
+// is missing from them, or no longer trading, as delisted and updates the
+// stored metadata of the others.
+func (m *Monitor) RunOnce(ctx context.Context) {
+	listed, err := m.listings.Refresh(ctx)
+	if err != nil {
+		slog.Error("failed to check symbol listings", "error", err)
+		return
+	}
+	settings, err := m.store.GetSymbolSettings()
+	if err != nil {
+		slog.Error("failed to get symbol settings", "error", err)
+		return
+	}
+
+	metas, _ := m.store.(database.MetadataStore)
+	now := m.now().UTC()
+
+	for _, s := range settings {
+		if s.State == database.StateRetired {
+			continue
+		}
+		info, ok := listed[s.Symbol]
+		if ok && metas != nil {
+			if err := metas.SetSymbolMetadata(info.Metadata(now)); err != nil {
+				slog.Error("failed to store symbol metadata", "symbol", s.Symbol, "error", err)
+			}
+		}
+		if s.State != database.StateActive && s.State != database.StatePaused {
+			continue
+		}
+		if ok && info.Listed() {
+			continue
+		}
+
+		if err := m.delister.MarkDelisted(s.Symbol, ""); err != nil {
+			slog.Error("failed to mark symbol delisted", "symbol", s.Symbol, "error", err)
+			continue
+		}
+		attrs := []any{"symbol", s.Symbol, "status", info.Status}
+		if successor := likelySuccessor(listed, s.Symbol); successor != "" {
+			attrs = append(attrs, "possible_successor", successor)
+		}
+		slog.Warn("symbol delisted by exchange, capture stopped", attrs...)
+	}
+}
+
+// likelySuccessor guesses the contract a delisted symbol continues as. The
+// exchange relists low-priced coins with a 1000 multiplier (SHIBUSDT became
+// 1000SHIBUSDT) and occasionally reverses that.
+func likelySuccessor(listed map[string]Symbol, symbol string) string {
+	candidates := []string{"1000" + symbol, "1000000" + symbol, "1M" + symbol}
+	if rest, ok := strings.CutPrefix(symbol, "1000"); ok {
+		candidates = append(candidates, rest)
+	}
+	for _, c := range candidates {
+		if s, ok := listed[c]; ok && s.Listed() {
+			return c
+		}
+	}
+	return ""
+}
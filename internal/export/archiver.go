@@ -0,0 +1,67 @@
+package export
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+// Archiver periodically exports every closed day that has not been exported.
+type Archiver struct {
+	store    database.Store
+	exporter *Exporter
+	interval time.Duration
+}
+
+// NewArchiver creates an archiver that checks for closed days every interval.
+func NewArchiver(store database.Store, exporter *Exporter, interval time.Duration) *Archiver {
+	return &Archiver{
+		store:    store,
+		exporter: exporter,
+		interval: interval,
+	}
+}
+
+// Start runs the archiver in the background until ctx is cancelled.
+func (a *Archiver) Start(ctx context.Context) {
+	go func() {
+		a.RunOnce(ctx)
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce exports pending days for every configured symbol.
+func (a *Archiver) RunOnce(ctx context.Context) {
+	settings, err := a.store.GetSymbolSettings()
+	if err != nil {
+		slog.Error("failed to get symbol settings", "error", err)
+		return
+	}
+
+	for _, s := range settings {
+		if ctx.Err() != nil {
+			return
+		}
+
+		records, err := a.exporter.ExportPending(s.Symbol)
+		for _, rec := range records {
+			slog.Info("day archived", "symbol", rec.Symbol, "day", rec.Day, "rows", rec.Rows)
+		}
+		if err != nil {
+			slog.Error("failed to archive", "symbol", s.Symbol, "error", err)
+		}
+	}
+}
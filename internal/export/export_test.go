@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+
+	"binance-tick-store/internal/database"
+)
+
+func TestParquetWriter_RoundTrip(t *testing.T) {
+	type tick struct {
+		ID        int64   `parquet:"id"`
+		AggID     int64   `parquet:"agg_id"`
+		Timestamp int64   `parquet:"timestamp"`
+		Price     float64 `parquet:"price"`
+	}
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		pw := NewParquetWriter(&buf, compress, map[string]string{"symbol": "BTCUSDT"})
+		pw.rowGroupSize = 3
+
+		var want []tick
+		for i := int64(1); i <= 7; i++ {
+			p := database.Price{ID: i, AggID: 100 + i, Timestamp: 1700000000000 + i*1000, Price: 42000 + float64(i)/4}
+			want = append(want, tick(p))
+			if err := pw.Write(p); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("OpenFile failed (compress=%v): %v", compress, err)
+		}
+		if f.NumRows() != 7 || len(f.RowGroups()) != 3 {
+			t.Errorf("expected 7 rows in 3 row groups, got %d in %d", f.NumRows(), len(f.RowGroups()))
+		}
+		if symbol, ok := f.Lookup("symbol"); !ok || symbol != "BTCUSDT" {
+			t.Errorf("expected symbol metadata, got %q", symbol)
+		}
+		if typ := f.Schema().Fields()[2].Type().LogicalType(); typ == nil || typ.Timestamp == nil {
+			t.Errorf("expected a timestamp logical type, got %v", typ)
+		}
+		codec := format.Uncompressed
+		if compress {
+			codec = format.Gzip
+		}
+		if got := f.Metadata().RowGroups[0].Columns[0].MetaData.Codec; got != codec {
+			t.Errorf("expected codec %v, got %v", codec, got)
+		}
+
+		r := parquet.NewGenericReader[tick](f)
+		got := make([]tick, 8)
+		n, err := r.Read(got)
+		if err != nil && err != io.EOF {
+			t.Fatalf("Read failed (compress=%v): %v", compress, err)
+		}
+		r.Close()
+		if n != len(want) {
+			t.Fatalf("expected %d rows, read %d", len(want), n)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("row %d (compress=%v): expected %+v, got %+v", i, compress, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestExporter_ExportPending(t *testing.T) {
+	dir := t.TempDir()
+	store, err := database.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+
+	day1 := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	day3 := time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)
+	for i, ts := range []time.Time{day1, day1.Add(time.Minute), day3} {
+		if err := store.InsertPrice("BTCUSDT", int64(i), ts.UnixMilli(), 42000); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+
+	exporter := New(store, filepath.Join(dir, "export"))
+	exporter.now = func() time.Time { return day3 }
+
+	// Only Jan 15 and the empty Jan 16 are closed
+	records, err := exporter.ExportPending("BTCUSDT")
+	if err != nil {
+		t.Fatalf("ExportPending failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Rows != 2 || records[1].Rows != 0 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	data, err := os.ReadFile(exporter.DayPath("BTCUSDT", day1))
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if f.NumRows() != 2 {
+		t.Errorf("expected 2 exported ticks, got %d", f.NumRows())
+	}
+	if _, err := os.Stat(exporter.DayPath("BTCUSDT", day1.AddDate(0, 0, 1))); !os.IsNotExist(err) {
+		t.Error("expected no file for empty day")
+	}
+
+	// Already exported days are skipped
+	records, err = exporter.ExportPending("BTCUSDT")
+	if err != nil {
+		t.Fatalf("ExportPending failed: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected nothing pending, got %+v", records)
+	}
+}
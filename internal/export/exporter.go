@@ -0,0 +1,185 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	dayLayout = "2006-01-02"
+
+	// closeGrace delays archiving a day so late ticks for it are not missed.
+	closeGrace = 10 * time.Minute
+)
+
+// Exporter writes ticks to Parquet files partitioned by symbol and UTC day,
+// using a Hive-style layout that pandas and DuckDB read directly:
+//
+//	<dir>/symbol=BTCUSDT/date=2024-01-15/BTCUSDT-2024-01-15.parquet
+type Exporter struct {
+	store database.Store
+	dir   string
+	now   func() time.Time
+}
+
+// New creates an exporter writing below dir.
+func New(store database.Store, dir string) *Exporter {
+	return &Exporter{
+		store: store,
+		dir:   dir,
+		now:   time.Now,
+	}
+}
+
+// DayPath returns the file path for symbol's ticks on the given UTC day.
+func (e *Exporter) DayPath(symbol string, day time.Time) string {
+	date := day.UTC().Format(dayLayout)
+	return filepath.Join(e.dir, "symbol="+symbol, "date="+date, symbol+"-"+date+".parquet")
+}
+
+// ExportDay writes all ticks of symbol on the given UTC day. Closed days are
+// recorded in the store's export log; the current day can be exported but is
+// not recorded, so the archiver will export it again once it is complete.
+func (e *Exporter) ExportDay(symbol string, day time.Time) (database.ExportRecord, error) {
+	start := truncateDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	rec := database.ExportRecord{
+		Symbol: symbol,
+		Day:    start.Format(dayLayout),
+	}
+
+	path := e.DayPath(symbol, start)
+	rows, err := e.writeFile(path, symbol, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return rec, err
+	}
+	rec.Rows = rows
+	if rows > 0 {
+		rec.Path = path
+	}
+	rec.ExportedAt = e.now().UTC()
+
+	if !e.now().Before(end.Add(closeGrace)) {
+		if err := e.store.MarkExported(rec); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
+
+// ExportRange exports every UTC day between from and to (inclusive).
+func (e *Exporter) ExportRange(symbol string, from, to time.Time) ([]database.ExportRecord, error) {
+	var records []database.ExportRecord
+	for day := truncateDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		rec, err := e.ExportDay(symbol, day)
+		if err != nil {
+			return records, fmt.Errorf("export %s %s: %w", symbol, rec.Day, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// ExportPending exports every closed day of symbol that is not yet in the
+// export log.
+func (e *Exporter) ExportPending(symbol string) ([]database.ExportRecord, error) {
+	dr, err := e.store.GetDateRange(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if dr.From == nil {
+		return nil, nil
+	}
+
+	done, err := e.store.GetExportRecords(symbol)
+	if err != nil {
+		return nil, err
+	}
+	exported := make(map[string]bool, len(done))
+	for _, rec := range done {
+		exported[rec.Day] = true
+	}
+
+	var records []database.ExportRecord
+	for day := truncateDay(*dr.From); !e.now().Before(day.AddDate(0, 0, 1).Add(closeGrace)); day = day.AddDate(0, 0, 1) {
+		if exported[day.Format(dayLayout)] {
+			continue
+		}
+		rec, err := e.ExportDay(symbol, day)
+		if err != nil {
+			return records, fmt.Errorf("export %s %s: %w", symbol, rec.Day, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// ArchiveSymbol writes every tick of symbol to a single file,
+//
+//	<dir>/retired/BTCUSDT-20240115T120000Z.parquet
+//
+// for keeping a symbol's history once its ticks are dropped. The path is
+// empty when there were no ticks.
+func (e *Exporter) ArchiveSymbol(symbol string) (string, int64, error) {
+	name := fmt.Sprintf("%s-%s.parquet", symbol, e.now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(e.dir, "retired", name)
+	rows, err := e.writeFile(path, symbol, math.MinInt64, math.MaxInt64)
+	if err != nil || rows == 0 {
+		return "", rows, err
+	}
+	return path, rows, nil
+}
+
+// writeFile writes ticks in [from, to) to path via a temporary file, so
+// readers never see a partial file. Nothing is written for an empty range.
+func (e *Exporter) writeFile(path, symbol string, from, to int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("create export dir: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	pw := NewParquetWriter(f, true, map[string]string{"symbol": symbol})
+	it := database.NewPriceIterator(e.store, symbol, from, to, 0)
+
+	var rows int64
+	for it.Next() {
+		if err := pw.Write(it.Price()); err != nil {
+			return 0, fmt.Errorf("write parquet: %w", err)
+		}
+		rows++
+	}
+	if err := it.Err(); err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, nil
+	}
+
+	if err := pw.Close(); err != nil {
+		return 0, fmt.Errorf("write parquet: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("close file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("rename file: %w", err)
+	}
+	return rows, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
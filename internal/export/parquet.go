@@ -0,0 +1,71 @@
+package export
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+
+	"binance-tick-store/internal/database"
+)
+
+const defaultRowGroupSize = 128 * 1024
+
+// tickRow is the Parquet schema of exported ticks.
+type tickRow struct {
+	ID        int64   `parquet:"id"`
+	AggID     int64   `parquet:"agg_id"`
+	Timestamp int64   `parquet:"timestamp,timestamp(millisecond)"`
+	Price     float64 `parquet:"price"`
+}
+
+// ParquetWriter writes ticks to a Parquet file. Rows are buffered and flushed
+// as a row group every rowGroupSize rows, optionally gzip compressed.
+type ParquetWriter struct {
+	w            *parquet.GenericWriter[tickRow]
+	rowGroupSize int
+	buf          []tickRow
+}
+
+// NewParquetWriter creates a writer that emits gzip-compressed pages when
+// compress is true. metadata is stored as key/value pairs in the footer.
+func NewParquetWriter(w io.Writer, compress bool, metadata map[string]string) *ParquetWriter {
+	opts := []parquet.WriterOption{parquet.Compression(&parquet.Uncompressed)}
+	if compress {
+		opts[0] = parquet.Compression(&parquet.Gzip)
+	}
+	for k, v := range metadata {
+		opts = append(opts, parquet.KeyValueMetadata(k, v))
+	}
+	return &ParquetWriter{
+		w:            parquet.NewGenericWriter[tickRow](w, opts...),
+		rowGroupSize: defaultRowGroupSize,
+	}
+}
+
+// Write buffers a tick, flushing a row group when the buffer is full.
+func (pw *ParquetWriter) Write(p database.Price) error {
+	pw.buf = append(pw.buf, tickRow(p))
+	if len(pw.buf) >= pw.rowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+// Close flushes remaining rows and writes the footer. It does not close the
+// underlying writer.
+func (pw *ParquetWriter) Close() error {
+	if len(pw.buf) > 0 {
+		if _, err := pw.w.Write(pw.buf); err != nil {
+			return err
+		}
+	}
+	return pw.w.Close()
+}
+
+func (pw *ParquetWriter) flush() error {
+	if _, err := pw.w.Write(pw.buf); err != nil {
+		return err
+	}
+	pw.buf = pw.buf[:0]
+	return pw.w.Flush()
+}
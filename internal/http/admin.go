@@ -0,0 +1,31 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin reports whether r may call a state-changing endpoint, and
+// answers it if not. With an admin token configured the request must carry
+// it as a bearer token; without one only loopback clients are allowed, as
+// the server listens on every interface.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err == nil && ip != nil && ip.IsLoopback() {
+			return true
+		}
+		http.Error(w, "admin endpoints are only served to localhost unless ADMIN_TOKEN is set", http.StatusForbidden)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or wrong admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
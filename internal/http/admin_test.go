@@ -0,0 +1,54 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// adminRequest is a POST from localhost, which admin endpoints accept when
+// no token is configured.
+func adminRequest(target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.RemoteAddr = "127.0.0.1:40000"
+	return req
+}
+
+func TestAdminEndpointsNeedAuthorization(t *testing.T) {
+	reloader := stubReloader{}
+	for _, target := range []string{"/api/v1/admin/reload", "/api/v1/symbols?symbol=BTCUSDT&state=paused"} {
+		open := NewHandler(newTestStore(t), staticStatus{}, Options{Reloader: reloader})
+		rec := httptest.NewRecorder()
+		open.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil)) // from 192.0.2.1
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s from a remote client: expected 403, got %d", target, rec.Code)
+		}
+
+		h := NewHandler(newTestStore(t), staticStatus{}, Options{Reloader: reloader, AdminToken: "s3cret"})
+		for auth, code := range map[string]int{
+			"":              http.StatusUnauthorized,
+			"Bearer wrong":  http.StatusUnauthorized,
+			"s3cret":        http.StatusUnauthorized,
+			"Bearer s3cret": http.StatusOK,
+		} {
+			req := adminRequest(target, nil) // loopback is not enough with a token
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != code {
+				t.Errorf("%s with %q: expected %d, got %d: %s", target, auth, code, rec.Code, rec.Body.String())
+			}
+		}
+	}
+
+	// Reading stays open
+	h := NewHandler(newTestStore(t), staticStatus{}, Options{AdminToken: "s3cret"})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/symbols", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected GET /api/v1/symbols to need no token, got %d", rec.Code)
+	}
+}
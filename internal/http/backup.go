@@ -0,0 +1,47 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type backupResponse struct {
+	Path       string   `json:"path"`
+	Size       int64    `json:"size"`
+	DurationMS int64    `json:"duration_ms"`
+	Removed    []string `json:"removed,omitempty"`
+}
+
+// serveBackup takes a verified snapshot of the live database:
+//
+//	POST /api/v1/backup
+func (h *Handler) serveBackup(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	res, err := h.backups.Run()
+	if err != nil {
+		slog.Error("backup failed", "error", err)
+		http.Error(w, "backup failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("backup complete", "path", res.Path, "size", res.Size, "duration", res.Duration)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backupResponse{
+		Path:       res.Path,
+		Size:       res.Size,
+		DurationMS: res.Duration.Milliseconds(),
+		Removed:    res.Removed,
+	})
+}
@@ -0,0 +1,382 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	// exportFlushRows is how many rows are buffered before a chunk is sent.
+	exportFlushRows = 5000
+	// exportWriteTimeout bounds each chunk write; it is extended on every
+	// flush so long exports are not cut off by the server's WriteTimeout.
+	exportWriteTimeout = 30 * time.Second
+)
+
+// serveExport streams ticks as CSV or NDJSON:
+//
+//	GET /api/v1/export?symbol=BTCUSDT,ETHUSDT&from=2024-01-01&to=2024-01-02&format=csv
+//
+// Rows of several symbols are merged in timestamp order. Ticks are read page
+// by page from the read pool, so live inserts continue during the export.
+// With history=true the ticks of symbols renamed to a requested one are
+// included under the requested name.
+func (h *Handler) serveExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	symbols, err := parseSymbols(q["symbol"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTime(q.Get("from"), 0)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime(q.Get("to"), math.MaxInt64)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var labels map[string]string
+	if v := q.Get("history"); v != "" {
+		history, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid history: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if history {
+			if symbols, labels, err = h.withPredecessors(symbols); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	metas, err := database.MetadataBySymbol(h.store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	var enc rowEncoder
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		enc = &csvEncoder{}
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = &ndjsonEncoder{}
+	default:
+		http.Error(w, "invalid format: must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export.%s"`, format))
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	bw := bufio.NewWriterSize(out, 64*1024)
+	enc.init(bw)
+
+	rc := http.NewResponseController(w)
+	flush := func() error {
+		rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	merged := newMergeIterator(h.store, symbols, from, to)
+	rows := 0
+	for merged.Next() {
+		source := merged.Symbol()
+		label := source
+		if l, ok := labels[source]; ok {
+			label = l
+		}
+		// Predecessors keep their own precision under the successor's name
+		if err := enc.encode(label, metas[source], merged.Price()); err != nil {
+			slog.Warn("export aborted", "error", err)
+			return
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := r.Context().Err(); err != nil {
+				slog.Warn("export aborted", "error", err)
+				return
+			}
+			if err := flush(); err != nil {
+				slog.Warn("export aborted", "error", err)
+				return
+			}
+		}
+	}
+	if err := merged.Err(); err != nil {
+		// Headers are already sent; truncating the stream is all we can do.
+		slog.Error("export failed", "symbols", symbols, "error", err)
+		return
+	}
+
+	if err := flush(); err != nil {
+		slog.Warn("export aborted", "error", err)
+		return
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	slog.Debug("export complete", "symbols", symbols, "rows", rows)
+}
+
+// parseSymbols accepts repeated and comma-separated symbol parameters.
+func parseSymbols(values []string) ([]string, error) {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			if s == "" || seen[s] {
+				continue
+			}
+			if err := database.ValidateSymbol(s); err != nil {
+				return nil, err
+			}
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	return symbols, nil
+}
+
+// withPredecessors adds the symbols renamed to each of symbols, returning
+// the extended list and the requested name to export each addition under.
+func (h *Handler) withPredecessors(symbols []string) ([]string, map[string]string, error) {
+	settings, err := h.store.GetSymbolSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	labels := make(map[string]string)
+	all := slices.Clone(symbols)
+	for _, symbol := range symbols {
+		for _, p := range database.Predecessors(settings, symbol) {
+			if _, ok := labels[p]; !ok && !slices.Contains(symbols, p) {
+				labels[p] = symbol
+				all = append(all, p)
+			}
+		}
+	}
+	return all, labels, nil
+}
+
+// parseTime accepts Unix milliseconds, RFC 3339 or a YYYY-MM-DD date (UTC).
+func parseTime(v string, fallback int64) (int64, error) {
+	if v == "" {
+		return fallback, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return ms, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UnixMilli(), nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return 0, fmt.Errorf("expected unix ms, RFC 3339 or YYYY-MM-DD")
+	}
+	return t.UnixMilli(), nil
+}
+
+// rowEncoder writes export rows in one output format.
+type rowEncoder interface {
+	init(w io.Writer)
+	// encode writes one tick. meta is the zero value if the contract's
+	// metadata is unknown.
+	encode(symbol string, meta database.SymbolMetadata, p database.Price) error
+	flush() error
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) init(w io.Writer) {
+	e.w = csv.NewWriter(w)
+	e.w.Write([]string{"symbol", "id", "agg_id", "timestamp", "price"})
+}
+
+// encode writes the price to the contract's precision, so a 0.10 tick size
+// reads 42000.10 rather than 42000.1.
+func (e *csvEncoder) encode(symbol string, meta database.SymbolMetadata, p database.Price) error {
+	return e.w.Write([]string{
+		symbol,
+		strconv.FormatInt(p.ID, 10),
+		strconv.FormatInt(p.AggID, 10),
+		strconv.FormatInt(p.Timestamp, 10),
+		meta.FormatPrice(p.Price),
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+type ndjsonRow struct {
+	Symbol    string      `json:"symbol"`
+	ID        int64       `json:"id"`
+	AggID     int64       `json:"agg_id"`
+	Timestamp int64       `json:"timestamp"`
+	Price     json.Number `json:"price"`
+}
+
+func (e *ndjsonEncoder) init(w io.Writer) {
+	e.enc = json.NewEncoder(w)
+}
+
+// encode writes the price as a JSON number to the contract's precision, the
+// same digits the CSV encoder writes.
+func (e *ndjsonEncoder) encode(symbol string, meta database.SymbolMetadata, p database.Price) error {
+	price := json.Number(meta.FormatPrice(p.Price))
+	return e.enc.Encode(ndjsonRow{Symbol: symbol, ID: p.ID, AggID: p.AggID, Timestamp: p.Timestamp, Price: price})
+}
+
+func (e *ndjsonEncoder) flush() error { return nil }
+
+// mergeIterator merges per-symbol iterators into one stream ordered by
+// timestamp. Ties are broken by symbol order, then id.
+type mergeIterator struct {
+	sources []*database.PriceIterator
+	symbols []string
+	heap    mergeHeap
+	started bool
+	current mergeItem
+	err     error
+}
+
+func newMergeIterator(store database.Store, symbols []string, from, to int64) *mergeIterator {
+	m := &mergeIterator{symbols: symbols}
+	for _, s := range symbols {
+		m.sources = append(m.sources, database.NewPriceIterator(store, s, from, to, 0))
+	}
+	return m
+}
+
+func (m *mergeIterator) Next() bool {
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			m.push(i)
+		}
+	} else {
+		// Refill from the source of the row just returned
+		m.push(m.current.source)
+	}
+
+	if m.err != nil || m.heap.Len() == 0 {
+		return false
+	}
+	m.current = heap.Pop(&m.heap).(mergeItem)
+	return true
+}
+
+func (m *mergeIterator) push(i int) {
+	it := m.sources[i]
+	if it.Next() {
+		heap.Push(&m.heap, mergeItem{price: it.Price(), source: i})
+		return
+	}
+	if err := it.Err(); err != nil && m.err == nil {
+		m.err = fmt.Errorf("%s: %w", m.symbols[i], err)
+	}
+}
+
+func (m *mergeIterator) Symbol() string {
+	return m.symbols[m.current.source]
+}
+
+func (m *mergeIterator) Price() database.Price {
+	return m.current.price
+}
+
+func (m *mergeIterator) Err() error {
+	return m.err
+}
+
+type mergeItem struct {
+	price  database.Price
+	source int
+}
+
+type mergeHeap struct {
+	items []mergeItem
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.price.Timestamp != b.price.Timestamp {
+		return a.price.Timestamp < b.price.Timestamp
+	}
+	if a.source != b.source {
+		return a.source < b.source
+	}
+	return a.price.ID < b.price.ID
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
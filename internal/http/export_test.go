@@ -0,0 +1,166 @@
+package http
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binance-tick-store/internal/database"
+)
+
+type staticStatus map[string]bool
+
+func (s staticStatus) GetActiveSymbols() map[string]bool { return s }
+
+func newTestStore(t *testing.T) database.Store {
+	t.Helper()
+	store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ticks := map[string][]int64{
+		"BTCUSDT": {1000, 3000, 5000},
+		"ETHUSDT": {2000, 3000, 4000},
+	}
+	for symbol, timestamps := range ticks {
+		if err := store.EnsurePriceTable(symbol); err != nil {
+			t.Fatalf("EnsurePriceTable failed: %v", err)
+		}
+		for _, ts := range timestamps {
+			if err := store.InsertPrice(symbol, ts/1000, ts, float64(ts)/10); err != nil {
+				t.Fatalf("InsertPrice failed: %v", err)
+			}
+		}
+	}
+	return store
+}
+
+func TestExport_CSVMergesSymbols(t *testing.T) {
+	h := NewHandler(newTestStore(t), staticStatus{}, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export?symbol=btcusdt,ETHUSDT&from=2000&to=5000", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	want := strings.Join([]string{
+		"symbol,id,agg_id,timestamp,price",
+		"ETHUSDT,1,2,2000,200",
+		"BTCUSDT,2,3,3000,300",
+		"ETHUSDT,2,3,3000,300",
+		"ETHUSDT,3,4,4000,400",
+		"",
+	}, "\n")
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected body:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExport_NDJSONGzip(t *testing.T) {
+	h := NewHandler(newTestStore(t), staticStatus{}, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export?symbol=BTCUSDT&format=ndjson", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected gzip response")
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 rows, got %d: %s", len(lines), body)
+	}
+	var row ndjsonRow
+	if err := json.Unmarshal([]byte(lines[2]), &row); err != nil {
+		t.Fatalf("decode row: %v", err)
+	}
+	if row.Symbol != "BTCUSDT" || row.Timestamp != 5000 || row.Price != "500" {
+		t.Errorf("unexpected row: %+v", row)
+	}
+}
+
+func TestExport_FormatsAgreeOnPrecision(t *testing.T) {
+	store := newTestStore(t)
+	store.EnsurePriceTable("SOLUSDT")
+	store.InsertPrice("SOLUSDT", 1, 1000, 0.1+0.2) // 0.30000000000000004
+	store.(database.MetadataStore).SetSymbolMetadata(database.SymbolMetadata{Symbol: "SOLUSDT", TickSize: "0.010"})
+	h := NewHandler(store, staticStatus{}, Options{})
+
+	body := func(format string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export?symbol=SOLUSDT&format="+format, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	if got := body("csv"); !strings.HasSuffix(got, ",0.30\n") {
+		t.Errorf("expected the CSV price at contract precision, got %q", got)
+	}
+	if got := body("ndjson"); !strings.Contains(got, `"price":0.30}`) {
+		t.Errorf("expected the NDJSON price to match the CSV, got %q", got)
+	}
+}
+
+func TestExport_BadRequest(t *testing.T) {
+	h := NewHandler(newTestStore(t), staticStatus{}, Options{})
+
+	for _, query := range []string{
+		"",
+		"symbol=BTC;DROP",
+		"symbol=BTCUSDT&format=xml",
+		"symbol=BTCUSDT&from=yesterday",
+		"symbol=BTCUSDT&history=maybe",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export?"+query, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestExport_HistoryIncludesPredecessors(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.(database.Delister).MarkDelisted("ETHUSDT", "BTCUSDT"); err != nil {
+		t.Fatalf("MarkDelisted failed: %v", err)
+	}
+	h := NewHandler(store, staticStatus{}, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export?symbol=BTCUSDT&history=true&to=3500", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	want := strings.Join([]string{
+		"symbol,id,agg_id,timestamp,price",
+		"BTCUSDT,1,1,1000,100",
+		"BTCUSDT,1,2,2000,200",
+		"BTCUSDT,2,3,3000,300",
+		"BTCUSDT,2,3,3000,300",
+		"",
+	}, "\n")
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected body:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"binance-tick-store/internal/config"
+)
+
+// Reloader reloads the configuration and applies what can change live.
+type Reloader interface {
+	Reload() ([]config.Change, error)
+}
+
+type reloadResponse struct {
+	Changes []config.Change `json:"changes"`
+}
+
+// serveReload reloads the configuration, like SIGHUP:
+//
+//	POST /api/v1/admin/reload
+//
+// The response lists the changed settings and whether each was applied or
+// waits for a restart. An invalid configuration is rejected with 422 and
+// the running one is kept.
+func (h *Handler) serveReload(w http.ResponseWriter, r *http.Request) {
+	if h.reloader == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	changes, err := h.reloader.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if changes == nil {
+		changes = []config.Change{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reloadResponse{Changes: changes})
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"binance-tick-store/internal/config"
+)
+
+type stubReloader struct {
+	changes []config.Change
+	err     error
+}
+
+func (s stubReloader) Reload() ([]config.Change, error) { return s.changes, s.err }
+
+func TestReload(t *testing.T) {
+	changes := []config.Change{{Key: "log_level", From: "info", To: "debug", Applied: true}}
+	h := NewHandler(newTestStore(t), staticStatus{}, Options{Reloader: stubReloader{changes: changes}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, adminRequest("/api/v1/admin/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp reloadResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Changes) != 1 || resp.Changes[0] != changes[0] {
+		t.Errorf("unexpected changes: %+v", resp.Changes)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+
+	h = NewHandler(newTestStore(t), staticStatus{}, Options{Reloader: stubReloader{err: errors.New("watcher_interval: must be positive")}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, adminRequest("/api/v1/admin/reload", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid config, got %d", rec.Code)
+	}
+}
@@ -1,13 +1,16 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
+	"binance-tick-store/internal/backup"
 	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/export"
 )
 
 // StatusProvider provides current connection status.
@@ -15,29 +18,73 @@ type StatusProvider interface {
 	GetActiveSymbols() map[string]bool
 }
 
+// SymbolChecker verifies that the exchange lists a symbol before it is
+// enabled.
+type SymbolChecker interface {
+	Check(ctx context.Context, symbol string) error
+}
+
+// Options configures the optional endpoints. Nil or empty fields disable
+// what they serve.
+type Options struct {
+	Backups      *backup.Manager  // backup endpoint
+	Archive      *export.Exporter // receives the ticks of retired symbols; nil only allows purging
+	Listings     SymbolChecker    // rejects unlisted symbols when enabling
+	Reloader     Reloader         // config reload endpoint
+	VerifyReport string           // file written by `server verify`, for the verification summary
+	AdminToken   string           // required by state-changing endpoints; empty allows loopback clients only
+}
+
 // Handler handles HTTP requests.
 type Handler struct {
-	store     database.Store
-	status    StatusProvider
-	startTime time.Time
+	store        database.Store
+	status       StatusProvider
+	backups      *backup.Manager
+	archive      *export.Exporter
+	listings     SymbolChecker
+	reloader     Reloader
+	verifyReport string
+	adminToken   string
+	startTime    time.Time
 }
 
 // NewHandler creates a new HTTP handler.
-func NewHandler(store database.Store, status StatusProvider) *Handler {
+func NewHandler(store database.Store, status StatusProvider, opts Options) *Handler {
 	return &Handler{
-		store:     store,
-		status:    status,
-		startTime: time.Now(),
+		store:        store,
+		status:       status,
+		backups:      opts.Backups,
+		archive:      opts.Archive,
+		listings:     opts.Listings,
+		reloader:     opts.Reloader,
+		verifyReport: opts.VerifyReport,
+		adminToken:   opts.AdminToken,
+		startTime:    time.Now(),
 	}
 }
 
-// ServeHTTP handles the /status endpoint.
+// ServeHTTP routes requests to the status and API endpoints.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/status" {
+	switch r.URL.Path {
+	case "/status":
+		h.serveStatus(w, r)
+	case "/api/v1/export":
+		h.serveExport(w, r)
+	case "/api/v1/backup":
+		h.serveBackup(w, r)
+	case "/api/v1/verify":
+		h.serveVerifyReport(w, r)
+	case "/api/v1/symbols":
+		h.serveSymbols(w, r)
+	case "/api/v1/admin/reload":
+		h.serveReload(w, r)
+	default:
 		http.NotFound(w, r)
-		return
 	}
+}
 
+// serveStatus handles the /status endpoint.
+func (h *Handler) serveStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 
@@ -47,7 +94,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var sb strings.Builder
 	sb.WriteString("Binance Last Price Store\n\n")
 	sb.WriteString(fmt.Sprintf("Status:     running\n"))
-	sb.WriteString(fmt.Sprintf("Uptime:     %s\n\n", uptime))
+	sb.WriteString(fmt.Sprintf("Uptime:     %s\n", uptime))
+	if summary := h.verifySummary(); summary != "" {
+		sb.WriteString(fmt.Sprintf("Verified:   %s\n", summary))
+	}
+	if m, ok := h.store.(database.Maintainer); ok {
+		if info, err := m.MaintenanceInfo(); err == nil {
+			sb.WriteString(fmt.Sprintf("WAL:        %s\n", walSummary(info)))
+		}
+	}
+	sb.WriteString("\n")
 
 	settings, err := h.store.GetSymbolSettings()
 	if err != nil {
@@ -56,12 +112,24 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	orphans, err := h.orphans(settings)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("Error: %v\n", err))
+	}
+
 	if len(settings) == 0 {
 		sb.WriteString("No symbols configured\n")
+		writeOrphans(&sb, orphans)
 		w.Write([]byte(sb.String()))
 		return
 	}
 
+	// Prices are shown to each contract's precision where it is known
+	metas, err := database.MetadataBySymbol(h.store)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("Error: %v\n", err))
+	}
+
 	// Sort symbols alphabetically
 	sort.Slice(settings, func(i, j int) bool {
 		return settings[i].Symbol < settings[j].Symbol
@@ -69,25 +137,73 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for _, s := range settings {
 		status := "off"
-		if s.Enabled && active[s.Symbol] {
+		switch {
+		case s.State != database.StateActive && s.State != "":
+			status = s.State
+		case s.Enabled && active[s.Symbol]:
 			status = "on"
 		}
 
 		dateRange := "(no data yet)"
-		count, _ := h.store.GetCount(s.Symbol)
-		dr, err := h.store.GetDateRange(s.Symbol)
-		if err == nil && dr.From != nil && dr.To != nil {
+		stats, err := h.store.GetStats(s.Symbol)
+		if err == nil && stats.FirstTS != nil && stats.LastTS != nil {
 			dateRange = fmt.Sprintf("%s  ->  %s",
-				dr.From.Format("2006-01-02 15:04:05"),
-				dr.To.Format("2006-01-02 15:04:05"))
+				stats.FirstTS.Format("2006-01-02 15:04:05"),
+				stats.LastTS.Format("2006-01-02 15:04:05"))
 		}
 
-		sb.WriteString(fmt.Sprintf("%-12s%-8s%-10d%s\n", s.Symbol, status, count, dateRange))
+		if stats.Duplicates > 0 {
+			dateRange += fmt.Sprintf("  (%d duplicates dropped)", stats.Duplicates)
+		}
+		if s.Successor != "" {
+			dateRange += "  (renamed to " + s.Successor + ")"
+		}
+
+		price := "-"
+		if p, ok := h.lastPrice(s.Symbol, stats.LastTS); ok {
+			price = metas[s.Symbol].FormatPrice(p)
+		}
+
+		sb.WriteString(fmt.Sprintf("%-12s%-8s%-10d%-14s%s\n", s.Symbol, status, stats.Count, price, dateRange))
 	}
+	writeOrphans(&sb, orphans)
 
 	w.Write([]byte(sb.String()))
 }
 
+// lastPrice returns the price of the tick at last, the symbol's latest
+// timestamp. Of several ticks at that millisecond the last stored wins.
+func (h *Handler) lastPrice(symbol string, last *time.Time) (float64, bool) {
+	if last == nil {
+		return 0, false
+	}
+	ts := last.UnixMilli()
+	prices, err := h.store.GetPrices(symbol, database.PriceQuery{From: ts, To: ts + 1})
+	if err != nil || len(prices) == 0 {
+		return 0, false
+	}
+	return prices[len(prices)-1].Price, true
+}
+
+// writeOrphans lists price tables left behind by deleted settings rows.
+func writeOrphans(sb *strings.Builder, orphans []string) {
+	if len(orphans) > 0 {
+		sb.WriteString(fmt.Sprintf("\nOrphaned price tables (no settings row): %s\n", strings.Join(orphans, ", ")))
+	}
+}
+
+// walSummary describes the WAL size and when it was last checkpointed.
+func walSummary(info database.MaintenanceInfo) string {
+	summary := fmt.Sprintf("%.1f MB", float64(info.WALSize)/(1<<20))
+	if !info.LastCheckpoint.IsZero() {
+		summary += fmt.Sprintf(", checkpointed %s ago", formatDuration(time.Since(info.LastCheckpoint)))
+	}
+	if info.FreeBytes > 0 {
+		summary += fmt.Sprintf(", %.1f MB free in database", float64(info.FreeBytes)/(1<<20))
+	}
+	return summary
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/settings"
+	"binance-tick-store/internal/websocket"
+)
+
+// SymbolStopper is implemented by status providers that can stop capturing
+// a symbol right away rather than at the settings watcher's next check.
+// StopSymbol is called once the pause is stored.
+type SymbolStopper interface {
+	StopSymbol(symbol string)
+}
+
+// ConnectionReporter is implemented by status providers that can report on
+// the connections capturing each symbol.
+type ConnectionReporter interface {
+	// SymbolConnections returns nil if symbol is not running.
+	SymbolConnections(symbol string) []websocket.ConnectionStats
+}
+
+// QuarantineReporter is implemented by status providers that pause symbols
+// whose clients panic repeatedly.
+type QuarantineReporter interface {
+	// SymbolQuarantine returns why symbol was quarantined, or "".
+	SymbolQuarantine(symbol string) string
+}
+
+type symbolInfo struct {
+	Symbol         string `json:"symbol"`
+	State          string `json:"state"`
+	Successor      string `json:"successor,omitempty"`
+	Ticks          int64  `json:"ticks"`
+	LastPrice      string `json:"last_price,omitempty"` // formatted to the contract's precision
+	TickSize       string `json:"tick_size,omitempty"`
+	PricePrecision *int   `json:"price_precision,omitempty"`
+	Connections    int    `json:"connections,omitempty"`
+	// Feeds reports on each running connection, including how many ticks
+	// it delivered first.
+	Feeds []websocket.ConnectionStats `json:"feeds,omitempty"`
+	// Quarantine is why a paused symbol was stopped after repeated panics.
+	Quarantine string `json:"quarantine,omitempty"`
+}
+
+type symbolsResponse struct {
+	Symbols []symbolInfo `json:"symbols"`
+	Orphans []string     `json:"orphans,omitempty"` // price tables without a settings row
+}
+
+// serveSymbols lists symbols with their lifecycle state, or changes one:
+//
+//	GET  /api/v1/symbols
+//	POST /api/v1/symbols?symbol=BTCUSDT&state=active|paused|retired[&archive=false&confirm=BTCUSDT]
+//	POST /api/v1/symbols?symbol=SHIBUSDT&state=delisted[&successor=1000SHIBUSDT]
+//	POST /api/v1/symbols?symbol=BTCUSDT&connections=2
+//
+// Changes are admin requests. Retiring archives the symbol's ticks to a
+// Parquet file and drops them; archive=false purges them without a copy and
+// must be confirmed by repeating the symbol. A successor maps a delisted symbol's
+// history to the symbol it was renamed to. Connections sets how many
+// redundant connections capture the symbol, from the next settings check.
+func (h *Handler) serveSymbols(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSymbols(w)
+	case http.MethodPost:
+		if h.authorizeAdmin(w, r) {
+			h.setSymbolState(w, r)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) listSymbols(w http.ResponseWriter) {
+	all, err := h.store.GetSymbolSettings()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Symbol < all[j].Symbol })
+	metas, err := database.MetadataBySymbol(h.store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := symbolsResponse{Symbols: make([]symbolInfo, 0, len(all))}
+	for _, s := range all {
+		stats, _ := h.store.GetStats(s.Symbol)
+		info := symbolInfo{Symbol: s.Symbol, State: s.State, Successor: s.Successor, Ticks: stats.Count, Connections: s.Connections}
+		if reporter, ok := h.status.(ConnectionReporter); ok {
+			info.Feeds = reporter.SymbolConnections(s.Symbol)
+		}
+		if reporter, ok := h.status.(QuarantineReporter); ok {
+			info.Quarantine = reporter.SymbolQuarantine(s.Symbol)
+		}
+		meta, ok := metas[s.Symbol]
+		if ok {
+			info.TickSize = meta.TickSize
+			info.PricePrecision = &meta.PricePrecision
+		}
+		if p, ok := h.lastPrice(s.Symbol, stats.LastTS); ok {
+			info.LastPrice = meta.FormatPrice(p)
+		}
+		resp.Symbols = append(resp.Symbols, info)
+	}
+	if orphans, err := h.orphans(all); err == nil {
+		resp.Orphans = orphans
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) setSymbolState(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	symbol := strings.ToUpper(q.Get("symbol"))
+	if err := database.ValidateSymbol(symbol); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if q.Has("connections") {
+		h.setSymbolConnections(w, symbol, q.Get("connections"))
+		return
+	}
+
+	var err error
+	var result any
+	switch state := q.Get("state"); state {
+	case database.StateActive, database.StatePaused:
+		if state == database.StateActive && h.listings != nil {
+			if err := h.listings.Check(r.Context(), symbol); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		err = h.store.SetSymbolEnabled(symbol, state == database.StateActive)
+		if err == nil && state == database.StatePaused {
+			h.stopSymbol(symbol)
+		}
+		result = symbolInfo{Symbol: symbol, State: state}
+	case database.StateDelisted:
+		successor := strings.ToUpper(q.Get("successor"))
+		if successor != "" {
+			if err := database.ValidateSymbol(successor); err != nil {
+				http.Error(w, "invalid successor: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		delister, ok := h.store.(database.Delister)
+		if !ok {
+			http.Error(w, "storage backend cannot mark symbols delisted", http.StatusNotImplemented)
+			return
+		}
+		err = delister.MarkDelisted(symbol, successor)
+		if err == nil {
+			h.stopSymbol(symbol)
+		}
+		result = symbolInfo{Symbol: symbol, State: state, Successor: successor}
+	case database.StateRetired:
+		archive := true
+		if v := q.Get("archive"); v != "" {
+			if archive, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if archive && h.archive == nil {
+			http.Error(w, "archiving is not configured; retry with archive=false&confirm="+symbol+" to purge", http.StatusBadRequest)
+			return
+		}
+		if !archive && !strings.EqualFold(q.Get("confirm"), symbol) {
+			http.Error(w, "purging drops the ticks without a copy; confirm with confirm="+symbol, http.StatusBadRequest)
+			return
+		}
+		if _, ok := h.store.(database.Retirer); !ok {
+			http.Error(w, "storage backend cannot retire symbols", http.StatusNotImplemented)
+			return
+		}
+
+		exporter := h.archive
+		if !archive {
+			exporter = nil
+		}
+		var res settings.RetireResult
+		res, err = settings.Retire(h.store, exporter, symbol, h.stopSymbol)
+		if err == nil {
+			slog.Info("symbol retired", "symbol", symbol, "archive", res.ArchivePath, "ticks", res.Archived)
+		}
+		result = res
+	default:
+		http.Error(w, "invalid state: must be active, paused, delisted or retired", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, settings.ErrUnknownSymbol) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to change symbol state", "symbol", symbol, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) setSymbolConnections(w http.ResponseWriter, symbol, value string) {
+	n, err := strconv.Atoi(value)
+	if err == nil {
+		err = database.ValidateConnections(n)
+	}
+	if err != nil {
+		http.Error(w, "invalid connections: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	r, ok := h.store.(database.Redundancer)
+	if !ok {
+		http.Error(w, "storage backend cannot set connections", http.StatusNotImplemented)
+		return
+	}
+	if err := r.SetSymbolConnections(symbol, n); err != nil {
+		slog.Error("failed to set connections", "symbol", symbol, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(symbolInfo{Symbol: symbol, Connections: n})
+}
+
+func (h *Handler) stopSymbol(symbol string) {
+	if stopper, ok := h.status.(SymbolStopper); ok {
+		stopper.StopSymbol(symbol)
+	}
+}
+
+// orphans lists price tables that have no settings row. Stores that cannot
+// list their tables report none.
+func (h *Handler) orphans(all []database.SymbolSettings) ([]string, error) {
+	retirer, ok := h.store.(database.Retirer)
+	if !ok {
+		return nil, nil
+	}
+	tables, err := retirer.PriceSymbols()
+	if err != nil {
+		return nil, err
+	}
+	return database.Orphans(tables, all), nil
+}
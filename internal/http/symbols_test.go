@@ -0,0 +1,250 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/export"
+	"binance-tick-store/internal/settings"
+	"binance-tick-store/internal/websocket"
+)
+
+// stoppingStatus records the symbols it was asked to stop.
+type stoppingStatus struct {
+	staticStatus
+	stopped []string
+}
+
+func (s *stoppingStatus) StopSymbol(symbol string) { s.stopped = append(s.stopped, symbol) }
+
+func getSymbols(t *testing.T, h http.Handler) symbolsResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/symbols", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp symbolsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestSymbols_RetireOrphanWithArchive(t *testing.T) {
+	store := newTestStore(t)
+	store.SetSymbolEnabled("BTCUSDT", true)
+	status := &stoppingStatus{}
+	h := NewHandler(store, status, Options{Archive: export.New(store, t.TempDir())})
+
+	resp := getSymbols(t, h)
+	if len(resp.Symbols) != 1 || resp.Symbols[0].State != "active" || resp.Symbols[0].Ticks != 3 {
+		t.Errorf("unexpected symbols: %+v", resp.Symbols)
+	}
+	if len(resp.Orphans) != 1 || resp.Orphans[0] != "ETHUSDT" {
+		t.Errorf("expected ETHUSDT orphaned, got %v", resp.Orphans)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, adminRequest("/api/v1/symbols?symbol=ethusdt&state=retired", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res settings.RetireResult
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res.Archived != 3 || !strings.Contains(res.ArchivePath, "retired") {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(res.ArchivePath); err != nil {
+		t.Errorf("archive missing: %v", err)
+	}
+	if len(status.stopped) != 1 || status.stopped[0] != "ETHUSDT" {
+		t.Errorf("expected ETHUSDT client stopped, got %v", status.stopped)
+	}
+
+	resp = getSymbols(t, h)
+	if len(resp.Symbols) != 2 || resp.Symbols[1].State != "retired" || resp.Symbols[1].Ticks != 0 || len(resp.Orphans) != 0 {
+		t.Errorf("unexpected symbols after retire: %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if !strings.Contains(rec.Body.String(), "ETHUSDT     retired 0") {
+		t.Errorf("expected retired symbol in status:\n%s", rec.Body.String())
+	}
+}
+
+func TestSymbols_Errors(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store, staticStatus{}, Options{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if !strings.Contains(rec.Body.String(), "Orphaned price tables (no settings row): BTCUSDT, ETHUSDT") {
+		t.Errorf("expected orphans in status:\n%s", rec.Body.String())
+	}
+
+	for query, code := range map[string]int{
+		"symbol=BTCUSDT&state=retired":                               http.StatusBadRequest, // no archive configured
+		"symbol=BTCUSDT&state=retired&archive=false":                 http.StatusBadRequest, // not confirmed
+		"symbol=BTCUSDT&state=retired&archive=false&confirm=ETHUSDT": http.StatusBadRequest,
+		"symbol=XRPUSDT&state=retired&archive=false&confirm=XRPUSDT": http.StatusNotFound,
+		"symbol=BTCUSDT&state=gone":                                  http.StatusBadRequest,
+		"symbol=BTC-USDT&state=paused":                               http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, adminRequest("/api/v1/symbols?"+query, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", query, code, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Purging needs no archive
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, adminRequest("/api/v1/symbols?symbol=BTCUSDT&state=retired&archive=false&confirm=btcusdt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if count, _ := store.GetCount("BTCUSDT"); count != 0 {
+		t.Errorf("expected BTCUSDT purged, %d ticks left", count)
+	}
+}
+
+// knownSymbols is a SymbolChecker listing a fixed set of symbols.
+type knownSymbols map[string]bool
+
+func (k knownSymbols) Check(ctx context.Context, symbol string) error {
+	if !k[symbol] {
+		return fmt.Errorf("%s is not listed", symbol)
+	}
+	return nil
+}
+
+func TestSymbols_EnableChecksListings(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store, staticStatus{}, Options{Listings: knownSymbols{"BTCUSDT": true}})
+
+	for query, code := range map[string]int{
+		"symbol=btcusdt&state=active": http.StatusOK,
+		"symbol=BTCUSTD&state=active": http.StatusUnprocessableEntity,
+		"symbol=BTCUSTD&state=paused": http.StatusOK, // only enabling is checked
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, adminRequest("/api/v1/symbols?"+query, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", query, code, rec.Code, rec.Body.String())
+		}
+	}
+
+	settings, _ := store.GetSymbolSettings()
+	for _, s := range settings {
+		if s.Symbol == "BTCUSTD" && s.Enabled {
+			t.Error("unlisted symbol was enabled")
+		}
+	}
+}
+
+func TestSymbols_PricesUseContractPrecision(t *testing.T) {
+	store := newTestStore(t)
+	store.SetSymbolEnabled("BTCUSDT", true)
+	store.SetSymbolEnabled("ETHUSDT", true)
+	meta := database.SymbolMetadata{Symbol: "BTCUSDT", ContractType: "PERPETUAL", TickSize: "0.10", StepSize: "0.001", PricePrecision: 2}
+	if err := store.(database.MetadataStore).SetSymbolMetadata(meta); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+	h := NewHandler(store, staticStatus{}, Options{})
+
+	resp := getSymbols(t, h)
+	btc, eth := resp.Symbols[0], resp.Symbols[1]
+	if btc.LastPrice != "500.00" || btc.TickSize != "0.10" || btc.PricePrecision == nil || *btc.PricePrecision != 2 {
+		t.Errorf("unexpected BTCUSDT: %+v", btc)
+	}
+	// Without metadata prices are printed as short as possible
+	if eth.LastPrice != "400" || eth.TickSize != "" || eth.PricePrecision != nil {
+		t.Errorf("unexpected ETHUSDT: %+v", eth)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if !strings.Contains(rec.Body.String(), "BTCUSDT     off     3         500.00") {
+		t.Errorf("expected formatted last price in status:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/export?symbol=BTCUSDT,ETHUSDT&to=2500", nil))
+	want := "symbol,id,agg_id,timestamp,price\nBTCUSDT,1,1,1000,100.00\nETHUSDT,1,2,2000,200\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected export:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// feedStatus reports two connections for every symbol.
+type feedStatus struct{ staticStatus }
+
+func (feedStatus) SymbolConnections(symbol string) []websocket.ConnectionStats {
+	return []websocket.ConnectionStats{
+		{URL: "wss://fstream.binance.com/ws", Connected: true, First: 7},
+		{URL: "wss://fstream-mm.binance.com/ws", Connected: false, First: 3},
+	}
+}
+
+func TestSymbols_Connections(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store, feedStatus{}, Options{})
+
+	for query, code := range map[string]int{
+		"symbol=BTCUSDT&connections=2": http.StatusOK,
+		"symbol=BTCUSDT&connections=0": http.StatusBadRequest,
+		"symbol=BTCUSDT&connections=x": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, adminRequest("/api/v1/symbols?"+query, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", query, code, rec.Code, rec.Body.String())
+		}
+	}
+
+	var btc symbolInfo
+	for _, s := range getSymbols(t, h).Symbols {
+		if s.Symbol == "BTCUSDT" {
+			btc = s
+		}
+	}
+	if btc.Connections != 2 || len(btc.Feeds) != 2 || btc.Feeds[0].First != 7 || btc.Feeds[1].Connected {
+		t.Errorf("unexpected BTCUSDT: %+v", btc)
+	}
+}
+
+// quarantineStatus reports BTCUSDT as quarantined.
+type quarantineStatus struct{ staticStatus }
+
+func (quarantineStatus) SymbolQuarantine(symbol string) string {
+	if symbol == "BTCUSDT" {
+		return "quarantined after repeated panics: 5 panics within 10m0s, last: bad tick"
+	}
+	return ""
+}
+
+func TestSymbols_Quarantine(t *testing.T) {
+	store := newTestStore(t)
+	store.SetSymbolEnabled("BTCUSDT", false)
+	store.SetSymbolEnabled("ETHUSDT", true)
+	h := NewHandler(store, quarantineStatus{}, Options{})
+
+	var quarantined []string
+	for _, s := range getSymbols(t, h).Symbols {
+		if s.Quarantine != "" {
+			quarantined = append(quarantined, s.Symbol)
+		}
+	}
+	if len(quarantined) != 1 || quarantined[0] != "BTCUSDT" {
+		t.Errorf("expected BTCUSDT quarantined, got %v", quarantined)
+	}
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"binance-tick-store/internal/verify"
+)
+
+// serveVerifyReport returns the last report written by `server verify`:
+//
+//	GET /api/v1/verify
+func (h *Handler) serveVerifyReport(w http.ResponseWriter, r *http.Request) {
+	if h.verifyReport == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := os.ReadFile(h.verifyReport)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no verification has run", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// verifySummary is the /status line describing the last verification.
+func (h *Handler) verifySummary() string {
+	if h.verifyReport == "" {
+		return ""
+	}
+	report, err := verify.Load(h.verifyReport)
+	if errors.Is(err, os.ErrNotExist) {
+		return "never run"
+	}
+	if err != nil {
+		return fmt.Sprintf("unreadable report (%v)", err)
+	}
+
+	when := report.FinishedAt.Format("2006-01-02 15:04:05")
+	if report.OK {
+		return fmt.Sprintf("%s  ok (%d symbols)", when, len(report.Symbols))
+	}
+
+	var problems []string
+	if len(report.Integrity) > 0 && (len(report.Integrity) != 1 || report.Integrity[0] != "ok") {
+		problems = append(problems, "integrity check failed")
+	}
+	for _, sr := range report.Symbols {
+		if n := sr.Problems(); n > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %d", sr.Symbol, n))
+		}
+	}
+	return fmt.Sprintf("%s  PROBLEMS (%s)", when, strings.Join(problems, ", "))
+}
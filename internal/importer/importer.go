@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	batchSize = 10000
+
+	// microsThreshold separates millisecond from microsecond timestamps.
+	// Spot archives switched to microseconds in 2025; a millisecond value
+	// this large would be thousands of years in the future.
+	microsThreshold = 1e14
+)
+
+// Result summarizes one imported file.
+type Result struct {
+	Symbol     string
+	File       string
+	Read       int64
+	Inserted   int64
+	Duplicates int64
+}
+
+// Importer loads Binance public aggTrades archives (data.binance.vision)
+// into the store. Files may be the published .zip archives or extracted .csv
+// files; ticks whose aggregate id is already stored are skipped.
+type Importer struct {
+	store database.Store
+}
+
+// New creates an importer.
+func New(store database.Store) *Importer {
+	return &Importer{store: store}
+}
+
+// SymbolFromFilename extracts the symbol from archive names such as
+// BTCUSDT-aggTrades-2024-01-15.zip.
+func SymbolFromFilename(path string) (string, error) {
+	base := filepath.Base(path)
+	i := strings.Index(base, "-aggTrades-")
+	if i <= 0 {
+		return "", fmt.Errorf("cannot derive symbol from %q: expected <SYMBOL>-aggTrades-<date>", base)
+	}
+	symbol := strings.ToUpper(base[:i])
+	if err := database.ValidateSymbol(symbol); err != nil {
+		return "", err
+	}
+	return symbol, nil
+}
+
+// ImportFile imports one .zip or .csv file into symbol's price table.
+func (im *Importer) ImportFile(path, symbol string) (Result, error) {
+	res := Result{Symbol: symbol, File: path}
+
+	if err := im.store.EnsurePriceTable(symbol); err != nil {
+		return res, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return res, fmt.Errorf("open zip: %w", err)
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if !strings.EqualFold(filepath.Ext(f.Name), ".csv") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return res, fmt.Errorf("open %s: %w", f.Name, err)
+			}
+			err = im.importCSV(rc, &res)
+			rc.Close()
+			if err != nil {
+				return res, fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		return res, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return res, fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	return res, im.importCSV(f, &res)
+}
+
+// importCSV reads aggTrades rows:
+//
+//	agg_trade_id,price,quantity,first_trade_id,last_trade_id,transact_time,is_buyer_maker[,is_best_match]
+//
+// A header line is present in newer archives and skipped.
+func (im *Importer) importCSV(r io.Reader, res *Result) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	batch := make([]database.Price, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := im.store.InsertPrices(res.Symbol, batch)
+		if err != nil {
+			return err
+		}
+		res.Inserted += n
+		res.Duplicates += int64(len(batch)) - n
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read csv: %w", err)
+		}
+
+		p, err := parseRow(rec)
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		res.Read++
+		batch = append(batch, p)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+func parseRow(rec []string) (database.Price, error) {
+	if len(rec) < 6 {
+		return database.Price{}, fmt.Errorf("expected at least 6 fields, got %d", len(rec))
+	}
+
+	aggID, err := strconv.ParseInt(rec[0], 10, 64)
+	if err != nil {
+		return database.Price{}, fmt.Errorf("parse agg_trade_id: %w", err)
+	}
+	price, err := strconv.ParseFloat(rec[1], 64)
+	if err != nil {
+		return database.Price{}, fmt.Errorf("parse price: %w", err)
+	}
+	ts, err := strconv.ParseInt(rec[5], 10, 64)
+	if err != nil {
+		return database.Price{}, fmt.Errorf("parse transact_time: %w", err)
+	}
+	if ts > microsThreshold {
+		ts /= 1000
+	}
+
+	return database.Price{AggID: aggID, Timestamp: ts, Price: price}, nil
+}
@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"binance-tick-store/internal/database"
+)
+
+const futuresCSV = `agg_trade_id,price,quantity,first_trade_id,last_trade_id,transact_time,is_buyer_maker
+100,42000.10,0.5,1000,1001,1705276800000,true
+101,42000.20,0.1,1002,1002,1705276800100,false
+102,42000.30,1.2,1003,1005,1705276800200,true
+`
+
+func TestSymbolFromFilename(t *testing.T) {
+	tests := []struct {
+		path   string
+		symbol string
+		ok     bool
+	}{
+		{"/data/BTCUSDT-aggTrades-2024-01-15.zip", "BTCUSDT", true},
+		{"ethusdt-aggTrades-2024-01.csv", "ETHUSDT", true},
+		{"trades.csv", "", false},
+		{"BTC_USDT-aggTrades-2024-01-15.zip", "", false},
+	}
+
+	for _, tt := range tests {
+		symbol, err := SymbolFromFilename(tt.path)
+		if tt.ok && (err != nil || symbol != tt.symbol) {
+			t.Errorf("SymbolFromFilename(%q) = %q, %v; want %q", tt.path, symbol, err, tt.symbol)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("SymbolFromFilename(%q) = %q, want error", tt.path, symbol)
+		}
+	}
+}
+
+func TestParseRow_Microseconds(t *testing.T) {
+	p, err := parseRow([]string{"5", "1.5", "2", "7", "7", "1735689600123456", "true", "true"})
+	if err != nil {
+		t.Fatalf("parseRow failed: %v", err)
+	}
+	if p.Timestamp != 1735689600123 {
+		t.Errorf("expected millisecond timestamp, got %d", p.Timestamp)
+	}
+}
+
+func TestImportFile_ZipDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	store, err := database.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	// Tick 101 was already captured live
+	if err := store.EnsurePriceTable("BTCUSDT"); err != nil {
+		t.Fatalf("EnsurePriceTable failed: %v", err)
+	}
+	if err := store.InsertPrice("BTCUSDT", 101, 1705276800100, 42000.20); err != nil {
+		t.Fatalf("InsertPrice failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "BTCUSDT-aggTrades-2024-01-15.zip")
+	writeZip(t, path, "BTCUSDT-aggTrades-2024-01-15.csv", futuresCSV)
+
+	res, err := New(store).ImportFile(path, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+	if res.Read != 3 || res.Inserted != 2 || res.Duplicates != 1 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	// Importing again inserts nothing
+	res, err = New(store).ImportFile(path, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+	if res.Inserted != 0 || res.Duplicates != 3 {
+		t.Errorf("unexpected result on re-import: %+v", res)
+	}
+
+	count, err := store.GetCount("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetCount failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 ticks, got %d", count)
+	}
+}
+
+func writeZip(t *testing.T, path, name, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatalf("create zip entry: %v", err)
+	}
+	w.Write([]byte(content))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package logging builds the server's slog logger: text or JSON output to
+// stdout or a size-rotated file, levels per subsystem and rate limiting of
+// repeated warnings and errors.
+//
+// A record's subsystem is the package that logged it (websocket, http,
+// ticklog, ...; main for the server itself), so packages keep calling the
+// slog functions directly.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subsystems are the names per-subsystem levels can be set for.
+var Subsystems = []string{
+	"backup", "database", "exchange", "export", "flatfile", "http", "importer",
+	"main", "postgres", "settings", "ticklog", "verify", "websocket",
+}
+
+// rateWindow is the period RateLimit counts records in.
+const rateWindow = time.Minute
+
+// Options configures the logger.
+type Options struct {
+	Format    string // text or json
+	File      string // empty logs to stdout
+	MaxSizeMB int    // rotate the file once it grows past this size, 0 never
+	MaxFiles  int    // rotated files kept next to the current one
+	RateLimit int    // WARN and above records per message per minute, 0 unlimited
+}
+
+// New creates a logger filtered by levels. The closer closes the log file,
+// if any.
+func New(opts Options, levels *Levels) (*slog.Logger, io.Closer, error) {
+	var out io.WriteCloser = nopCloser{os.Stdout}
+	if opts.File != "" {
+		f, err := OpenRotatingFile(opts.File, int64(opts.MaxSizeMB)<<20, opts.MaxFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = f
+	}
+
+	// The inner handler passes everything; handler filters by subsystem
+	inner := &slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+	var h slog.Handler
+	switch opts.Format {
+	case "", "text":
+		h = slog.NewTextHandler(out, inner)
+	case "json":
+		h = slog.NewJSONHandler(out, inner)
+	default:
+		out.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	s := &state{levels: levels, limit: opts.RateLimit, seen: make(map[string]*rateEntry), now: time.Now}
+	return slog.New(&handler{inner: h, state: s}), out, nil
+}
+
+// Levels holds the default level and per-subsystem overrides. It can be
+// changed while logging.
+type Levels struct {
+	mu         sync.RWMutex
+	def        slog.Level
+	subsystems map[string]slog.Level
+	min        slog.Level // lowest of all, for Enabled
+}
+
+// NewLevels creates levels; subsystems may be nil.
+func NewLevels(def slog.Level, subsystems map[string]slog.Level) *Levels {
+	l := &Levels{}
+	l.Set(def, subsystems)
+	return l
+}
+
+// Set replaces the levels.
+func (l *Levels) Set(def slog.Level, subsystems map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.def, l.min = def, def
+	l.subsystems = make(map[string]slog.Level, len(subsystems))
+	for name, level := range subsystems {
+		l.subsystems[name] = level
+		l.min = min(l.min, level)
+	}
+}
+
+// For returns the level of subsystem.
+func (l *Levels) For(subsystem string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level, ok := l.subsystems[subsystem]; ok {
+		return level
+	}
+	return l.def
+}
+
+func (l *Levels) lowest() slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.min
+}
+
+// ParseLevels parses per-subsystem levels such as "websocket=debug,http=warn"
+// with parseLevel.
+func ParseLevels(s string, parseLevel func(string) (slog.Level, error)) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not subsystem=level", part)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known(name) {
+			return nil, fmt.Errorf("unknown subsystem %q (%s)", name, strings.Join(Subsystems, ", "))
+		}
+		level, err := parseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// FormatLevels is the inverse of ParseLevels, sorted by subsystem.
+func FormatLevels(levels map[string]slog.Level) string {
+	parts := make([]string, 0, len(levels))
+	for name, level := range levels {
+		parts = append(parts, name+"="+strings.ToLower(level.String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func known(subsystem string) bool {
+	for _, s := range Subsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// state is shared by a handler and those derived from it with WithAttrs
+// and WithGroup.
+type state struct {
+	levels *Levels
+	limit  int
+	now    func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]*rateEntry // by level and message
+}
+
+type rateEntry struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+type handler struct {
+	inner slog.Handler
+	state *state
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	// The subsystem is only known from the record's caller, so let through
+	// anything some subsystem would log and filter in Handle
+	return level >= h.state.levels.lowest()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.state.levels.For(subsystemOf(r.PC)) {
+		return nil
+	}
+	if r.Level >= slog.LevelWarn && h.state.limit > 0 {
+		suppressed, ok := h.state.allow(r.Level, r.Message)
+		if !ok {
+			return nil
+		}
+		if suppressed > 0 {
+			r = r.Clone()
+			r.AddAttrs(slog.Int("suppressed", suppressed))
+		}
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{inner: h.inner.WithAttrs(attrs), state: h.state}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), state: h.state}
+}
+
+// allow reports whether a record may be logged, and how many like it were
+// dropped since the last one that was.
+func (s *state) allow(level slog.Level, msg string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := level.String() + "\x00" + msg
+	now := s.now()
+	e, ok := s.seen[key]
+	if !ok {
+		e = &rateEntry{start: now}
+		s.seen[key] = e
+	}
+	if now.Sub(e.start) >= rateWindow {
+		e.start, e.count = now, 0
+	}
+	e.count++
+	if e.count > s.limit {
+		e.suppressed++
+		return 0, false
+	}
+	suppressed := e.suppressed
+	e.suppressed = 0
+	return suppressed, true
+}
+
+// subsystems caches subsystemOf by program counter.
+var subsystems sync.Map
+
+// subsystemOf returns the last element of the package path of the function
+// at pc: "websocket" for binance-tick-store/internal/websocket.(*client).Run.
+func subsystemOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	if s, ok := subsystems.Load(pc); ok {
+		return s.(string)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	name := frame.Function
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	subsystems.Store(pc, name)
+	return name
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
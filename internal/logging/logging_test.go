@@ -0,0 +1,209 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(buf *bytes.Buffer, levels *Levels, limit int) (*slog.Logger, *state) {
+	s := &state{levels: levels, limit: limit, seen: make(map[string]*rateEntry), now: time.Now}
+	h := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(&handler{inner: h, state: s}), s
+}
+
+func lines(buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			panic(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	levels := NewLevels(slog.LevelInfo, map[string]slog.Level{"logging": slog.LevelDebug})
+	logger, _ := newTestLogger(&buf, levels, 0)
+
+	// Records logged from this package belong to the logging subsystem
+	logger.Debug("debug here")
+	if n := len(lines(&buf)); n != 1 {
+		t.Fatalf("expected the subsystem's debug record, got %d records", n)
+	}
+
+	buf.Reset()
+	levels.Set(slog.LevelInfo, map[string]slog.Level{"websocket": slog.LevelDebug})
+	logger.Debug("debug here")
+	logger.Info("info here")
+	records := lines(&buf)
+	if len(records) != 1 || records[0]["msg"] != "info here" {
+		t.Errorf("expected only the info record, got %v", records)
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug enabled while a subsystem logs at debug")
+	}
+
+	levels.Set(slog.LevelWarn, nil)
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("expected info disabled at warn")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var buf bytes.Buffer
+	logger, s := newTestLogger(&buf, NewLevels(slog.LevelInfo, nil), 2)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		logger.Error("failed to insert price", "symbol", "BTCUSDT")
+		logger.Info("client started")
+	}
+	logger.Error("other error")
+
+	var insert, info, other int
+	for _, r := range lines(&buf) {
+		switch r["msg"] {
+		case "failed to insert price":
+			insert++
+		case "client started":
+			info++
+		case "other error":
+			other++
+		}
+	}
+	if insert != 2 || info != 5 || other != 1 {
+		t.Errorf("expected 2 insert errors, 5 infos and 1 other error, got %d, %d, %d", insert, info, other)
+	}
+
+	// The next window reports what was dropped
+	buf.Reset()
+	now = now.Add(rateWindow)
+	logger.Error("failed to insert price", "symbol", "BTCUSDT")
+	records := lines(&buf)
+	if len(records) != 1 || records[0]["suppressed"] != float64(3) {
+		t.Errorf("expected 3 suppressed reported, got %v", records)
+	}
+}
+
+func TestNew_JSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	logger, closer, err := New(Options{Format: "json", File: path}, NewLevels(slog.LevelInfo, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.With("symbol", "BTCUSDT").Info("client started")
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r map[string]any
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("expected a JSON record, got %q", data)
+	}
+	if r["msg"] != "client started" || r["symbol"] != "BTCUSDT" {
+		t.Errorf("unexpected record %v", r)
+	}
+
+	if _, _, err := New(Options{Format: "xml"}, NewLevels(slog.LevelInfo, nil)); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"aaaaaaa\n", "bbbbbbb\n", "ccccccc\n", "ddddddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "ddddddd\n",
+		path + ".1": "ccccccc\n",
+		path + ".2": "bbbbbbb\n",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil || string(data) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", filepath.Base(name), want, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected files past the number kept to be removed")
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	parse := func(s string) (slog.Level, error) {
+		var l slog.Level
+		return l, l.UnmarshalText([]byte(s))
+	}
+
+	levels, err := ParseLevels("websocket=debug, http=WARN", parse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatLevels(levels); got != "http=warn,websocket=debug" {
+		t.Errorf("unexpected levels %s", got)
+	}
+
+	for _, bad := range []string{"websocket", "nosuch=debug", "http=loud"} {
+		if _, err := ParseLevels(bad, parse); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRotatingFile_KeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	f, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A non-empty directory where the rotated file goes makes the rename fail
+	os.MkdirAll(filepath.Join(path+".1", "x"), 0755)
+
+	f.Write([]byte("aaaaaaa\n"))
+	if _, err := f.Write([]byte("bbbbbbb\n")); err == nil {
+		t.Error("expected the failed rotation to be reported")
+	}
+	if _, err := f.Write([]byte("ccccccc\n")); err != nil {
+		t.Errorf("expected a failed rotation to be reported once, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "aaaaaaa\nbbbbbbb\nccccccc\n" {
+		t.Errorf("expected writes to continue in the current file, got %q", data)
+	}
+
+	os.RemoveAll(path + ".1")
+	for _, line := range []string{"ddddddd\n", "eeeeeee\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "eeeeeee\n" {
+		t.Errorf("expected rotation to resume, got %q", data)
+	}
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file that is renamed to path.1 once it grows past a
+// size, shifting older files to path.2 and so on and removing those past
+// the number kept.
+type RotatingFile struct {
+	path     string
+	maxSize  int64 // 0 never rotates
+	maxFiles int
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	retryAt int64 // size to retry at after a failed rotation, 0 if none
+}
+
+// OpenRotatingFile opens path for appending.
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	f, size, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	r.f, r.size = f, size
+	return r, nil
+}
+
+func (r *RotatingFile) open() (*os.File, int64, error) {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("stat log file: %w", err)
+	}
+	return f, info.Size(), nil
+}
+
+// Write appends p, rotating first if p would take the file past its size.
+// A record is never split across files. If rotation fails, p still goes to
+// the current file and the error is returned; rotation is retried once
+// another maxSize has been written, and further failures are not returned
+// until one succeeds.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rotateErr error
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > max(r.maxSize, r.retryAt) {
+		failing := r.retryAt > 0
+		if err := r.rotate(); err != nil {
+			r.retryAt = r.size + r.maxSize
+			if !failing {
+				rotateErr = err
+			}
+		} else {
+			r.retryAt = 0
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+// rotate moves the file aside and opens a new one at path. The current file
+// is only closed once its replacement is open, so a failure leaves writes
+// going to it.
+func (r *RotatingFile) rotate() error {
+	if r.maxFiles <= 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(r.name(r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			os.Rename(r.name(i), r.name(i+1))
+		}
+		if err := os.Rename(r.path, r.name(1)); err != nil {
+			return fmt.Errorf("rotate log file: %w", err)
+		}
+	}
+
+	f, size, err := r.open()
+	if err != nil {
+		return err
+	}
+	r.f.Close()
+	r.f, r.size = f, size
+	return nil
+}
+
+func (r *RotatingFile) name(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
@@ -0,0 +1,79 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"binance-tick-store/internal/database"
+	"binance-tick-store/internal/export"
+)
+
+// ErrUnknownSymbol is returned by Retire for a symbol with neither a settings
+// row nor stored ticks.
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// RetireResult describes a retired symbol.
+type RetireResult struct {
+	Symbol      string `json:"symbol"`
+	ArchivePath string `json:"archive_path,omitempty"` // empty if not archived or there were no ticks
+	Archived    int64  `json:"archived"`
+}
+
+// Retire stops capture of symbol, archives its ticks with exporter (nil
+// purges them without an archive) and drops them from the store. The symbol
+// is paused first so a running server's watcher stops its client; ticks
+// that arrive before it does are not archived. stop, if not nil, is called
+// once the pause is stored to stop the client right away.
+func Retire(store database.Store, exporter *export.Exporter, symbol string, stop func(symbol string)) (RetireResult, error) {
+	symbol = strings.ToUpper(symbol)
+	res := RetireResult{Symbol: symbol}
+
+	retirer, ok := store.(database.Retirer)
+	if !ok {
+		return res, fmt.Errorf("store cannot retire symbols")
+	}
+	if known, err := isKnown(store, retirer, symbol); err != nil {
+		return res, err
+	} else if !known {
+		return res, fmt.Errorf("%w %s", ErrUnknownSymbol, symbol)
+	}
+	if err := store.SetSymbolEnabled(symbol, false); err != nil {
+		return res, err
+	}
+	if stop != nil {
+		stop(symbol)
+	}
+
+	if exporter != nil {
+		path, rows, err := exporter.ArchiveSymbol(symbol)
+		if err != nil {
+			return res, fmt.Errorf("archive %s: %w", symbol, err)
+		}
+		res.ArchivePath, res.Archived = path, rows
+	}
+
+	if err := retirer.RetireSymbol(symbol); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// isKnown reports whether symbol has a settings row or stored ticks.
+func isKnown(store database.Store, retirer database.Retirer, symbol string) (bool, error) {
+	settings, err := store.GetSymbolSettings()
+	if err != nil {
+		return false, err
+	}
+	for _, s := range settings {
+		if s.Symbol == symbol {
+			return true, nil
+		}
+	}
+	symbols, err := retirer.PriceSymbols()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(symbols, symbol), nil
+}
@@ -3,26 +3,40 @@ package settings
 import (
 	"context"
 	"log/slog"
+	"sync"
 	"time"
 
 	"binance-tick-store/internal/database"
 )
 
-// SymbolChange represents a change in symbol state.
+// SymbolChange represents a change in symbol state or in its number of
+// connections.
 type SymbolChange struct {
-	Symbol  string
-	Enabled bool
+	Symbol      string
+	Enabled     bool
+	Connections int
 }
 
 // Watcher monitors symbol_settings for changes.
 type Watcher interface {
 	Start(ctx context.Context) <-chan SymbolChange
+	// SetInterval changes how often a running watcher polls, starting a
+	// new interval now.
+	SetInterval(interval time.Duration)
+	// Forget makes the next check report symbol's settings even if they
+	// look unchanged, for a client stopped without the watcher, e.g. by a
+	// pause through the API. Call it after the stop is stored.
+	Forget(symbol string)
 }
 
 type watcher struct {
 	store    database.Store
 	interval time.Duration
-	known    map[string]bool
+	known    map[string]SymbolChange
+
+	mu     sync.Mutex         // serializes SetInterval and guards forget
+	reset  chan time.Duration // holds at most the latest interval
+	forget map[string]bool    // symbols to drop from known at the next check
 }
 
 // New creates a new settings watcher.
@@ -30,8 +44,28 @@ func New(store database.Store, interval time.Duration) Watcher {
 	return &watcher{
 		store:    store,
 		interval: interval,
-		known:    make(map[string]bool),
+		known:    make(map[string]SymbolChange),
+		reset:    make(chan time.Duration, 1),
+		forget:   make(map[string]bool),
+	}
+}
+
+func (w *watcher) SetInterval(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Replace an interval the loop has not picked up yet
+	select {
+	case <-w.reset:
+	default:
 	}
+	w.reset <- interval
+}
+
+func (w *watcher) Forget(symbol string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.forget[symbol] = true
 }
 
 func (w *watcher) Start(ctx context.Context) <-chan SymbolChange {
@@ -50,6 +84,8 @@ func (w *watcher) Start(ctx context.Context) <-chan SymbolChange {
 			select {
 			case <-ctx.Done():
 				return
+			case interval := <-w.reset:
+				ticker.Reset(interval)
 			case <-ticker.C:
 				w.check(ch)
 			}
@@ -60,23 +96,31 @@ func (w *watcher) Start(ctx context.Context) <-chan SymbolChange {
 }
 
 func (w *watcher) check(ch chan<- SymbolChange) {
+	// Taken before reading the settings, so they include the stored stop
+	w.mu.Lock()
+	for symbol := range w.forget {
+		delete(w.known, symbol)
+	}
+	clear(w.forget)
+	w.mu.Unlock()
+
 	settings, err := w.store.GetSymbolSettings()
 	if err != nil {
 		slog.Error("failed to get symbol settings", "error", err)
 		return
 	}
 
-	current := make(map[string]bool)
+	current := make(map[string]SymbolChange)
 	for _, s := range settings {
-		current[s.Symbol] = s.Enabled
+		current[s.Symbol] = SymbolChange{Symbol: s.Symbol, Enabled: s.Enabled, Connections: max(s.Connections, 1)}
 	}
 
 	// Detect new or changed symbols
-	for symbol, enabled := range current {
+	for symbol, change := range current {
 		prev, exists := w.known[symbol]
-		if !exists || prev != enabled {
-			slog.Info("symbol settings changed", "symbol", symbol, "enabled", enabled)
-			ch <- SymbolChange{Symbol: symbol, Enabled: enabled}
+		if !exists || prev != change {
+			slog.Info("symbol settings changed", "symbol", symbol, "enabled", change.Enabled, "connections", change.Connections)
+			ch <- change
 		}
 	}
 
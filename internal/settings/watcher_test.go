@@ -2,6 +2,7 @@ package settings
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,19 +10,48 @@ import (
 )
 
 type mockStore struct {
+	mu       sync.Mutex // the watcher reads settings while tests change them
 	settings []database.SymbolSettings
 }
 
+// setSettings replaces the settings returned by later polls.
+func (m *mockStore) setSettings(settings []database.SymbolSettings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings = settings
+}
+
 func (m *mockStore) Close() error { return nil }
 func (m *mockStore) GetSymbolSettings() ([]database.SymbolSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.settings, nil
 }
-func (m *mockStore) EnsurePriceTable(symbol string) error                         { return nil }
-func (m *mockStore) InsertPrice(symbol string, timestamp int64, price float64) error { return nil }
+func (m *mockStore) EnsurePriceTable(symbol string) error { return nil }
+func (m *mockStore) InsertPrice(symbol string, aggID, timestamp int64, price float64) error {
+	return nil
+}
+func (m *mockStore) InsertPrices(symbol string, prices []database.Price) (int64, error) {
+	return int64(len(prices)), nil
+}
 func (m *mockStore) GetDateRange(symbol string) (database.DateRange, error) {
 	return database.DateRange{}, nil
 }
 func (m *mockStore) GetCount(symbol string) (int64, error) { return 0, nil }
+func (m *mockStore) GetStats(symbol string) (database.SymbolStats, error) {
+	return database.SymbolStats{Symbol: symbol}, nil
+}
+func (m *mockStore) ReconcileStats(symbol string) (int64, error) { return 0, nil }
+func (m *mockStore) SetSymbolEnabled(symbol string, enabled bool) error {
+	return nil
+}
+func (m *mockStore) GetPrices(symbol string, q database.PriceQuery) ([]database.Price, error) {
+	return nil, nil
+}
+func (m *mockStore) MarkExported(rec database.ExportRecord) error { return nil }
+func (m *mockStore) GetExportRecords(symbol string) ([]database.ExportRecord, error) {
+	return nil, nil
+}
 
 func TestWatcher_InitialLoad(t *testing.T) {
 	store := &mockStore{
@@ -65,9 +95,9 @@ func TestWatcher_DetectsChanges(t *testing.T) {
 	time.Sleep(20 * time.Millisecond)
 
 	// Add a symbol
-	store.settings = []database.SymbolSettings{
+	store.setSettings([]database.SymbolSettings{
 		{Symbol: "BTCUSDT", Enabled: true},
-	}
+	})
 
 	// Wait for next check
 	select {
@@ -79,3 +109,59 @@ func TestWatcher_DetectsChanges(t *testing.T) {
 		t.Error("expected change event")
 	}
 }
+
+func TestWatcher_SetInterval(t *testing.T) {
+	store := &mockStore{
+		settings: []database.SymbolSettings{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := New(store, time.Hour)
+	changes := watcher.Start(ctx)
+	watcher.SetInterval(10 * time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	store.setSettings([]database.SymbolSettings{
+		{Symbol: "BTCUSDT", Enabled: true},
+	})
+
+	select {
+	case change := <-changes:
+		if change.Symbol != "BTCUSDT" {
+			t.Errorf("unexpected change: %+v", change)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("expected the shortened interval to pick up the change")
+	}
+}
+
+func TestWatcher_DetectsConnectionChanges(t *testing.T) {
+	store := &mockStore{
+		settings: []database.SymbolSettings{{Symbol: "BTCUSDT", Enabled: true}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := New(store, 10*time.Millisecond)
+	changes := watcher.Start(ctx)
+
+	if change := <-changes; change.Connections != 1 {
+		t.Errorf("expected 1 connection by default, got %+v", change)
+	}
+
+	store.setSettings([]database.SymbolSettings{
+		{Symbol: "BTCUSDT", Enabled: true, Connections: 2},
+	})
+
+	select {
+	case change := <-changes:
+		if change.Symbol != "BTCUSDT" || !change.Enabled || change.Connections != 2 {
+			t.Errorf("unexpected change: %+v", change)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Error("expected change event")
+	}
+}
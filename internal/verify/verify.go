@@ -0,0 +1,257 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+const (
+	// DefaultOutlierRatio flags a tick that differs by more than 10% from
+	// both of its neighbours.
+	DefaultOutlierRatio = 0.10
+
+	// maxSamples bounds the example issues kept per symbol.
+	maxSamples = 20
+)
+
+// minTimestamp is the earliest plausible tick: Binance launched in July 2017.
+var minTimestamp = time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Issue kinds.
+const (
+	KindOutOfOrder   = "out_of_order"
+	KindNonPositive  = "non_positive_price"
+	KindOutlier      = "outlier_price"
+	KindOffTick      = "off_tick_price"
+	KindBadTimestamp = "bad_timestamp"
+	KindStatsDrift   = "stats_drift"
+)
+
+// Issue is one suspicious tick.
+type Issue struct {
+	Kind      string  `json:"kind"`
+	ID        int64   `json:"id"`
+	AggID     int64   `json:"agg_id"`
+	Timestamp int64   `json:"timestamp"`
+	Price     float64 `json:"price"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+// SymbolReport summarizes the checks of one symbol's ticks.
+type SymbolReport struct {
+	Symbol        string  `json:"symbol"`
+	Ticks         int64   `json:"ticks"`
+	StatsCount    int64   `json:"stats_count"`
+	StatsDrift    bool    `json:"stats_drift"` // maintained count disagrees with the scan
+	OutOfOrder    int64   `json:"out_of_order"`
+	NonPositive   int64   `json:"non_positive_prices"`
+	Outliers      int64   `json:"outlier_prices"`
+	OffTick       int64   `json:"off_tick_prices"` // not a multiple of the contract's tick size
+	BadTimestamps int64   `json:"bad_timestamps"`
+	Samples       []Issue `json:"samples,omitempty"`
+}
+
+// Problems is the number of issues found for the symbol.
+func (r SymbolReport) Problems() int64 {
+	n := r.OutOfOrder + r.NonPositive + r.Outliers + r.OffTick + r.BadTimestamps
+	if r.StatsDrift {
+		n++
+	}
+	return n
+}
+
+// Report is the machine-readable result of a verification run.
+type Report struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Integrity  []string       `json:"integrity,omitempty"` // PRAGMA integrity_check output, nil if unsupported
+	Symbols    []SymbolReport `json:"symbols"`
+	OK         bool           `json:"ok"`
+}
+
+// Options tunes the checks.
+type Options struct {
+	OutlierRatio float64
+}
+
+// Verifier checks stored ticks for corruption and implausible data.
+type Verifier struct {
+	store database.Store
+	opts  Options
+	now   func() time.Time
+}
+
+// New creates a verifier.
+func New(store database.Store, opts Options) *Verifier {
+	if opts.OutlierRatio <= 0 {
+		opts.OutlierRatio = DefaultOutlierRatio
+	}
+	return &Verifier{store: store, opts: opts, now: time.Now}
+}
+
+// Run checks storage integrity, when the backend supports it, and every
+// tick of the given symbols. Ticks are read in timestamp order, so it runs
+// alongside capture without blocking inserts.
+func (v *Verifier) Run(symbols []string) (Report, error) {
+	report := Report{StartedAt: v.now().UTC(), OK: true}
+
+	if checker, ok := v.store.(database.IntegrityChecker); ok {
+		result, err := checker.IntegrityCheck()
+		if err != nil {
+			return report, err
+		}
+		report.Integrity = result
+		if len(result) != 1 || result[0] != "ok" {
+			report.OK = false
+		}
+	}
+
+	// Without stored metadata every price counts as on tick
+	metas, err := database.MetadataBySymbol(v.store)
+	if err != nil {
+		return report, err
+	}
+
+	for _, symbol := range symbols {
+		sr, err := v.checkSymbol(symbol, metas[symbol])
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", symbol, err)
+		}
+		if sr.Problems() > 0 {
+			report.OK = false
+		}
+		report.Symbols = append(report.Symbols, sr)
+	}
+
+	report.FinishedAt = v.now().UTC()
+	return report, nil
+}
+
+func (v *Verifier) checkSymbol(symbol string, meta database.SymbolMetadata) (SymbolReport, error) {
+	sr := SymbolReport{Symbol: symbol}
+
+	// Ticks inserted during the scan are allowed for by reading the stats
+	// on both sides of it
+	before, err := v.store.GetStats(symbol)
+	if err != nil {
+		return sr, err
+	}
+
+	add := func(kind string, p database.Price, detail string) {
+		if math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+			detail = fmt.Sprint(p.Price) // not representable in JSON
+			p.Price = 0
+		}
+		if len(sr.Samples) < maxSamples {
+			sr.Samples = append(sr.Samples, Issue{
+				Kind: kind, ID: p.ID, AggID: p.AggID, Timestamp: p.Timestamp, Price: p.Price, Detail: detail,
+			})
+		}
+	}
+	maxTimestamp := v.now().Add(time.Minute).UnixMilli()
+
+	// prev and cur trail the iterator so each tick can be compared with
+	// both neighbours before it is judged an outlier.
+	var prev, cur *database.Price
+	checkOutlier := func(next *database.Price) {
+		if prev == nil || cur == nil || next == nil || cur.Price <= 0 {
+			return
+		}
+		ratio := v.opts.OutlierRatio
+		if deviates(cur.Price, prev.Price, ratio) && deviates(cur.Price, next.Price, ratio) &&
+			!deviates(prev.Price, next.Price, ratio) {
+			sr.Outliers++
+			add(KindOutlier, *cur, fmt.Sprintf("neighbours %g and %g", prev.Price, next.Price))
+		}
+	}
+
+	// last is the previous tick of any price, for the ordering check
+	var last *database.Price
+	it := database.NewPriceIterator(v.store, symbol, math.MinInt64, math.MaxInt64, 0)
+	for it.Next() {
+		p := it.Price()
+		sr.Ticks++
+
+		// In time order ids should only grow; each descent marks a tick
+		// stored out of sequence.
+		if last != nil && p.ID < last.ID {
+			sr.OutOfOrder++
+			add(KindOutOfOrder, p, fmt.Sprintf("follows id %d", last.ID))
+		}
+		last = &p
+		if p.Price <= 0 || math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+			sr.NonPositive++
+			add(KindNonPositive, p, "")
+		} else if !meta.OnTick(p.Price) {
+			sr.OffTick++
+			add(KindOffTick, p, "tick size "+meta.TickSize)
+		}
+		if p.Timestamp < minTimestamp || p.Timestamp > maxTimestamp {
+			sr.BadTimestamps++
+			add(KindBadTimestamp, p, time.UnixMilli(p.Timestamp).UTC().Format(time.RFC3339))
+		}
+
+		if p.Price > 0 {
+			checkOutlier(&p)
+			prev, cur = cur, &p
+		}
+	}
+	if err := it.Err(); err != nil {
+		return sr, err
+	}
+
+	after, err := v.store.GetStats(symbol)
+	if err != nil {
+		return sr, err
+	}
+	sr.StatsCount = after.Count
+	if sr.Ticks < before.Count || sr.Ticks > after.Count {
+		sr.StatsDrift = true
+		add(KindStatsDrift, database.Price{}, fmt.Sprintf("stats count %d, scanned %d", after.Count, sr.Ticks))
+	}
+	return sr, nil
+}
+
+// deviates reports whether a differs from b by more than ratio of b.
+func deviates(a, b, ratio float64) bool {
+	return math.Abs(a-b) > ratio*b
+}
+
+// Save writes the report as JSON, replacing path atomically.
+func Save(path string, report Report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create report dir: %w", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// Load reads a report written by Save. It returns os.ErrNotExist (wrapped)
+// when no verification has run yet.
+func Load(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("parse report: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,149 @@
+package verify
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"binance-tick-store/internal/database"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	store, err := database.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).UnixMilli()
+	ticks := []database.Price{
+		{AggID: 1, Timestamp: base, Price: 100},
+		{AggID: 2, Timestamp: base + 1000, Price: 101},
+		{AggID: 3, Timestamp: base + 2000, Price: 500}, // spike
+		{AggID: 4, Timestamp: base + 3000, Price: 102},
+		{AggID: 5, Timestamp: base + 4000, Price: 0},
+		{AggID: 6, Timestamp: base + 500, Price: 100},    // arrived late
+		{AggID: 7, Timestamp: 1705276800, Price: 103},    // seconds, not ms
+		{AggID: 8, Timestamp: base + 5000, Price: 103.5}, // regime change is not an outlier
+		{AggID: 9, Timestamp: base + 6000, Price: 150},
+		{AggID: 10, Timestamp: base + 7000, Price: 151},
+	}
+	store.EnsurePriceTable("BTCUSDT")
+	for _, p := range ticks {
+		if err := store.InsertPrice("BTCUSDT", p.AggID, p.Timestamp, p.Price); err != nil {
+			t.Fatalf("InsertPrice failed: %v", err)
+		}
+	}
+	store.EnsurePriceTable("ETHUSDT")
+	store.InsertPrice("ETHUSDT", 1, base, 2000)
+
+	v := New(store, Options{})
+	v.now = func() time.Time { return time.UnixMilli(base).Add(time.Hour) }
+	report, err := v.Run([]string{"BTCUSDT", "ETHUSDT"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(report.Integrity) != 1 || report.Integrity[0] != "ok" {
+		t.Errorf("unexpected integrity result %v", report.Integrity)
+	}
+	if report.OK {
+		t.Error("expected problems to be reported")
+	}
+
+	btc := report.Symbols[0]
+	if btc.Ticks != 10 || btc.StatsDrift {
+		t.Errorf("unexpected counts: %+v", btc)
+	}
+	// Time order by id: 7 (1970), 1, 6, 2, 3, ... -> descents 7->1 and 6->2
+	if btc.OutOfOrder != 2 {
+		t.Errorf("expected 2 out of order, got %d", btc.OutOfOrder)
+	}
+	if btc.NonPositive != 1 || btc.BadTimestamps != 1 {
+		t.Errorf("expected 1 non-positive and 1 bad timestamp, got %+v", btc)
+	}
+	if btc.Outliers != 1 {
+		t.Errorf("expected 1 outlier, got %d: %+v", btc.Outliers, btc.Samples)
+	}
+
+	if eth := report.Symbols[1]; eth.Problems() != 0 {
+		t.Errorf("expected ETHUSDT clean, got %+v", eth)
+	}
+
+	path := filepath.Join(dir, "report.json")
+	if err := Save(path, report); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.OK || len(loaded.Symbols) != 2 || loaded.Symbols[0].Outliers != 1 {
+		t.Errorf("report did not round-trip: %+v", loaded)
+	}
+}
+
+func TestRun_OutOfOrderAfterZeroPrice(t *testing.T) {
+	store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).UnixMilli()
+	store.EnsurePriceTable("BTCUSDT")
+	store.InsertPrice("BTCUSDT", 1, base, 100)
+	store.InsertPrice("BTCUSDT", 2, base+2000, 101)
+	store.InsertPrice("BTCUSDT", 3, base+1000, 0)
+
+	v := New(store, Options{})
+	v.now = func() time.Time { return time.UnixMilli(base).Add(time.Hour) }
+	report, err := v.Run([]string{"BTCUSDT"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// Time order by id: 1, 3, 2 -> the zero price tick 3 is still followed by 2
+	if btc := report.Symbols[0]; btc.OutOfOrder != 1 || btc.NonPositive != 1 {
+		t.Errorf("expected 1 out of order and 1 non-positive, got %+v", btc)
+	}
+}
+
+func TestRun_OffTickPrices(t *testing.T) {
+	store, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	meta := database.SymbolMetadata{Symbol: "BTCUSDT", ContractType: "PERPETUAL", TickSize: "0.10", StepSize: "0.001", PricePrecision: 2}
+	if err := store.(database.MetadataStore).SetSymbolMetadata(meta); err != nil {
+		t.Fatalf("SetSymbolMetadata failed: %v", err)
+	}
+
+	base := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).UnixMilli()
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		store.EnsurePriceTable(symbol)
+		for i, price := range []float64{42000.1, 42000.15, 42000.3} {
+			if err := store.InsertPrice(symbol, int64(i+1), base+int64(i)*1000, price); err != nil {
+				t.Fatalf("InsertPrice failed: %v", err)
+			}
+		}
+	}
+
+	v := New(store, Options{})
+	v.now = func() time.Time { return time.UnixMilli(base).Add(time.Hour) }
+	report, err := v.Run([]string{"BTCUSDT", "ETHUSDT"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	btc := report.Symbols[0]
+	if btc.OffTick != 1 || btc.Problems() != 1 || btc.Samples[0].Kind != KindOffTick || btc.Samples[0].Price != 42000.15 {
+		t.Errorf("expected one off-tick price, got %+v", btc)
+	}
+	// Without metadata there is no tick size to check against
+	if eth := report.Symbols[1]; eth.Problems() != 0 {
+		t.Errorf("expected ETHUSDT clean, got %+v", eth)
+	}
+}
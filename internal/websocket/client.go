@@ -3,24 +3,64 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
+// Defaults for the zero Options.
 const (
-	binanceWSURL = "wss://fstream.binance.com/ws/%s@aggTrade"
-	maxBackoff   = 30 * time.Second
+	DefaultURL        = "wss://fstream.binance.com/ws"
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+
+	DefaultRotateOverlap = 10 * time.Second
 )
 
+// maxLoggedMessage bounds the raw message logged with a panic.
+const maxLoggedMessage = 1024
+
+// ErrQuarantined is returned by Run when the client stopped after
+// panicking QuarantinePanics times.
+var ErrQuarantined = errors.New("quarantined after repeated panics")
+
+// Options configures a client. Zero fields take the defaults, except as
+// noted.
+type Options struct {
+	URL     string       // stream base URL; <symbol>@aggTrade is appended
+	Backoff *Backoff     // reconnect policy, may be shared by several clients
+	Limiter *ConnLimiter // connection rate limit shared by all clients, nil for none
+
+	// A connection is replaced after RotateAfter, ahead of Binance closing
+	// it at 24 hours. Both run for RotateOverlap, ticks delivered by both
+	// are passed on once. A zero RotateAfter never rotates.
+	RotateAfter   time.Duration
+	RotateOverlap time.Duration
+
+	// Connections is how many redundant connections capture the symbol,
+	// taking URL and then StandbyURLs in turn. Each tick is passed on once,
+	// from whichever connection delivers it first.
+	Connections int
+	StandbyURLs []string
+
+	// A panic parsing or handling a message fails the connection, which
+	// reconnects with Backoff. After QuarantinePanics panics within
+	// QuarantineWindow the client stops; zero QuarantinePanics never does.
+	QuarantinePanics int
+	QuarantineWindow time.Duration
+}
+
 // Tick represents a price update.
 type Tick struct {
 	Symbol    string
+	AggID     int64
 	Timestamp int64
 	Price     float64
+	Conn      int // the connection that delivered the tick first, from 0
 }
 
 // TickHandler processes incoming ticks.
@@ -28,7 +68,9 @@ type TickHandler func(Tick)
 
 // Dialer abstracts WebSocket connection creation (for testing).
 type Dialer interface {
-	Dial(url string) (Conn, error)
+	// Dial connects to url, giving up when ctx ends. The connection is
+	// closed when ctx ends, which fails a blocked ReadMessage.
+	Dial(ctx context.Context, url string) (Conn, error)
 }
 
 // Conn abstracts a WebSocket connection (for testing).
@@ -37,89 +79,250 @@ type Conn interface {
 	Close() error
 }
 
-// Client manages a WebSocket connection for a symbol.
+// Client manages the WebSocket connections of a symbol.
 type Client interface {
-	Run(ctx context.Context)
+	// Run captures the symbol until ctx ends or the client is quarantined,
+	// when it returns an error wrapping ErrQuarantined. It returns once
+	// every connection is closed and the handler will not be called again.
+	Run(ctx context.Context) error
+	// Connections reports on each of the client's connections.
+	Connections() []ConnectionStats
+}
+
+// ConnectionStats reports on one connection of a client.
+type ConnectionStats struct {
+	URL       string `json:"url"`
+	Connected bool   `json:"connected"`
+	First     int64  `json:"first"`            // ticks this connection delivered first
+	Panics    int64  `json:"panics,omitempty"` // recovered while parsing or handling its messages
 }
 
 type client struct {
 	symbol  string
 	dialer  Dialer
 	handler TickHandler
+	opts    Options
+
+	feeds []*feed
+
+	mu         sync.Mutex // serializes handler calls of concurrent connections and guards feeds
+	recent     recentIDs
+	duplicates int // ticks dropped as delivered already
+
+	panics     []time.Time             // within QuarantineWindow, oldest first
+	quarantine context.CancelCauseFunc // stops Run, set while it runs
+}
+
+// feed is one of a client's redundant connections, reconnected on its own.
+type feed struct {
+	index     int
+	url       string
+	connected bool
+	first     int64
+	panics    int64
 }
 
 // NewClient creates a new WebSocket client for a symbol.
-func NewClient(symbol string, dialer Dialer, handler TickHandler) Client {
-	return &client{
+func NewClient(symbol string, dialer Dialer, handler TickHandler, opts Options) Client {
+	if opts.URL == "" {
+		opts.URL = DefaultURL
+	}
+	if opts.RotateOverlap == 0 {
+		opts.RotateOverlap = DefaultRotateOverlap
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = NewBackoff(DefaultMinBackoff, DefaultMaxBackoff)
+	}
+	c := &client{
 		symbol:  symbol,
 		dialer:  dialer,
 		handler: handler,
+		opts:    opts,
+		recent:  newRecentIDs(recentSize),
+	}
+	urls := append([]string{opts.URL}, opts.StandbyURLs...)
+	for i := range max(opts.Connections, 1) {
+		c.feeds = append(c.feeds, &feed{index: i, url: urls[i%len(urls)]})
+	}
+	return c
+}
+
+func (c *client) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	c.mu.Lock()
+	c.quarantine = cancel
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, f := range c.feeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runFeed(ctx, f)
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); errors.Is(err, ErrQuarantined) {
+		return err
+	}
+	return nil
+}
+
+func (c *client) Connections() []ConnectionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]ConnectionStats, len(c.feeds))
+	for i, f := range c.feeds {
+		stats[i] = ConnectionStats{URL: f.url, Connected: f.connected, First: f.first, Panics: f.panics}
 	}
+	return stats
 }
 
-func (c *client) Run(ctx context.Context) {
-	backoff := time.Second
+// runFeed keeps one connection up until ctx ends.
+func (c *client) runFeed(ctx context.Context, f *feed) {
+	failures := 0 // attempts in a row that failed, reset by a connection
+	var delay time.Duration
 
 	for {
-		select {
-		case <-ctx.Done():
+		connected, err := c.connect(ctx, f)
+		if ctx.Err() != nil {
 			return
-		default:
 		}
+		if connected {
+			failures = 0
+		}
+		failures++
 
-		err := c.connect(ctx)
-		if err != nil {
-			slog.Error("websocket error", "symbol", c.symbol, "error", err)
+		// The policy is read on every attempt so changes apply without a restart
+		var open bool
+		delay, open = c.opts.Backoff.next(failures, delay)
+		if open {
+			slog.Warn("websocket circuit breaker open", "symbol", c.symbol, "conn", f.index, "failures", failures, "pause", delay, "error", err)
 		} else {
-			backoff = time.Second // Reset backoff on clean disconnect
+			slog.Error("websocket error", "symbol", c.symbol, "conn", f.index, "error", err, "retry_in", delay)
 		}
 
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(backoff):
-			backoff = min(backoff*2, maxBackoff)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// connect streams ticks until the connection fails or ctx ends. connected
+// reports whether the connection was established.
+func (c *client) connect(ctx context.Context, f *feed) (connected bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = c.recovered(f, r, nil)
 		}
+	}()
+
+	conn, err := c.dial(ctx, f)
+	if err != nil {
+		return false, err
 	}
+	slog.Info("websocket connected", "symbol", c.symbol, "conn", f.index)
+	c.setConnected(f, true)
+	defer c.setConnected(f, false)
+	return true, c.serve(ctx, f, conn)
 }
 
-func (c *client) connect(ctx context.Context) error {
-	url := fmt.Sprintf(binanceWSURL, strings.ToLower(c.symbol))
+func (c *client) setConnected(f *feed, connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f.connected = connected
+}
 
-	conn, err := c.dialer.Dial(url)
+// dial opens a connection once the connection limit allows it.
+func (c *client) dial(ctx context.Context, f *feed) (Conn, error) {
+	if err := c.opts.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/%s@aggTrade", strings.TrimSuffix(f.url, "/"), strings.ToLower(c.symbol))
+	conn, err := c.dialer.Dial(ctx, url)
 	if err != nil {
-		return fmt.Errorf("dial: %w", err)
+		return nil, fmt.Errorf("dial: %w", err)
 	}
+	return conn, nil
+}
 
-	// Close connection when context is cancelled
+// read delivers conn's ticks as f's until it fails or a message panics,
+// then sends the error.
+func (c *client) read(conn Conn, f *feed) <-chan error {
+	errc := make(chan error, 1)
 	go func() {
-		<-ctx.Done()
-		conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err == nil {
+				err = c.handle(f, msg)
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
 	}()
+	return errc
+}
 
-	slog.Info("websocket connected", "symbol", c.symbol)
-
-	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			if ctx.Err() != nil {
-				return nil // Clean shutdown
-			}
-			return fmt.Errorf("read: %w", err)
+// handle parses msg and delivers its tick, recovering a panic in either.
+// Messages that do not parse are skipped.
+func (c *client) handle(f *feed, msg []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = c.recovered(f, r, msg)
 		}
+	}()
+
+	tick, err := parseAggTrade(c.symbol, msg)
+	if err != nil {
+		slog.Warn("parse error", "symbol", c.symbol, "error", err)
+		return nil
+	}
+	tick.Conn = f.index
+	c.deliver(tick)
+	return nil
+}
 
-		tick, err := parseAggTrade(c.symbol, msg)
-		if err != nil {
-			slog.Warn("parse error", "symbol", c.symbol, "error", err)
-			continue
+// recovered logs and counts a panic of f, with the message being handled
+// if any, and quarantines the client if it panicked too often. It returns
+// the error that fails f's connection.
+func (c *client) recovered(f *feed, r any, msg []byte) error {
+	attrs := []any{"symbol", c.symbol, "conn", f.index, "panic", r, "stack", string(debug.Stack())}
+	if msg != nil {
+		if len(msg) > maxLoggedMessage {
+			msg = msg[:maxLoggedMessage]
 		}
+		attrs = append(attrs, "message", string(msg))
+	}
+	slog.Error("websocket client panicked", attrs...)
 
-		c.handler(tick)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f.panics++
+	now := time.Now()
+	expired := 0
+	for expired < len(c.panics) && now.Sub(c.panics[expired]) > c.opts.QuarantineWindow {
+		expired++
 	}
+	c.panics = append(c.panics[expired:], now)
+
+	err := fmt.Errorf("panic: %v", r)
+	if n := c.opts.QuarantinePanics; n > 0 && len(c.panics) >= n && c.quarantine != nil {
+		c.quarantine(fmt.Errorf("%w: %d panics within %s, last: %v", ErrQuarantined, n, c.opts.QuarantineWindow, r))
+	}
+	return err
 }
 
 // aggTrade represents Binance aggTrade message.
 type aggTrade struct {
+	AggID     int64  `json:"a"`
 	TradeTime int64  `json:"T"`
 	Price     string `json:"p"`
 }
@@ -137,30 +340,8 @@ func parseAggTrade(symbol string, data []byte) (Tick, error) {
 
 	return Tick{
 		Symbol:    symbol,
+		AggID:     at.AggID,
 		Timestamp: at.TradeTime,
 		Price:     price,
 	}, nil
 }
-
-// DefaultDialer implements Dialer using gorilla/websocket.
-type DefaultDialer struct{}
-
-func (d *DefaultDialer) Dial(url string) (Conn, error) {
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		return nil, err
-	}
-	return &connWrapper{conn}, nil
-}
-
-type connWrapper struct {
-	*websocket.Conn
-}
-
-func (c *connWrapper) ReadMessage() (int, []byte, error) {
-	return c.Conn.ReadMessage()
-}
-
-func (c *connWrapper) Close() error {
-	return c.Conn.Close()
-}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -44,7 +45,7 @@ type mockDialer struct {
 	err  error
 }
 
-func (m *mockDialer) Dial(url string) (Conn, error) {
+func (m *mockDialer) Dial(ctx context.Context, url string) (Conn, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -52,7 +53,7 @@ func (m *mockDialer) Dial(url string) (Conn, error) {
 }
 
 func TestParseAggTrade(t *testing.T) {
-	data := []byte(`{"T":1700000000000,"p":"42000.50"}`)
+	data := []byte(`{"a":26129,"T":1700000000000,"p":"42000.50"}`)
 
 	tick, err := parseAggTrade("BTCUSDT", data)
 	if err != nil {
@@ -62,6 +63,9 @@ func TestParseAggTrade(t *testing.T) {
 	if tick.Symbol != "BTCUSDT" {
 		t.Errorf("expected BTCUSDT, got %s", tick.Symbol)
 	}
+	if tick.AggID != 26129 {
+		t.Errorf("expected agg id 26129, got %d", tick.AggID)
+	}
 	if tick.Timestamp != 1700000000000 {
 		t.Errorf("expected 1700000000000, got %d", tick.Timestamp)
 	}
@@ -82,7 +86,7 @@ func TestClient_ReceivesTicks(t *testing.T) {
 		ticks = append(ticks, tick)
 	}
 
-	client := NewClient("BTCUSDT", dialer, handler)
+	client := NewClient("BTCUSDT", dialer, handler, Options{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 	defer cancel()
@@ -99,17 +103,19 @@ func TestClient_ReceivesTicks(t *testing.T) {
 type countingDialer struct {
 	err       error
 	callCount int
+	url       string
 }
 
-func (d *countingDialer) Dial(url string) (Conn, error) {
+func (d *countingDialer) Dial(ctx context.Context, url string) (Conn, error) {
 	d.callCount++
+	d.url = url
 	return nil, d.err
 }
 
 func TestClient_ReconnectsOnError(t *testing.T) {
 	dialer := &countingDialer{err: errors.New("connection failed")}
 
-	client := NewClient("BTCUSDT", dialer, func(tick Tick) {})
+	client := NewClient("BTCUSDT", dialer, func(tick Tick) {}, Options{})
 
 	// Backoff starts at 1s, so we need >1s for 2 attempts
 	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
@@ -121,3 +127,72 @@ func TestClient_ReconnectsOnError(t *testing.T) {
 		t.Errorf("expected multiple reconnection attempts, got %d", dialer.callCount)
 	}
 }
+
+func TestClient_Options(t *testing.T) {
+	dialer := &countingDialer{err: errors.New("connection failed")}
+
+	client := NewClient("BTCUSDT", dialer, func(tick Tick) {}, Options{
+		URL:     "ws://localhost:9000/ws/",
+		Backoff: NewBackoff(10*time.Millisecond, 20*time.Millisecond),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	client.Run(ctx)
+
+	if dialer.url != "ws://localhost:9000/ws/btcusdt@aggTrade" {
+		t.Errorf("unexpected url %s", dialer.url)
+	}
+	// 10ms, then 20ms per attempt
+	if dialer.callCount < 5 {
+		t.Errorf("expected backoff capped at 20ms, got %d attempts", dialer.callCount)
+	}
+}
+
+func TestClient_RecoversPanics(t *testing.T) {
+	dialer := &seqDialer{conns: []*mockConn{newMockConn(aggTrades(1, 2)), newMockConn(aggTrades(3))}}
+
+	var ids []int64
+	c := NewClient("BTCUSDT", dialer, func(tick Tick) {
+		if tick.AggID == 1 {
+			panic("bad tick")
+		}
+		ids = append(ids, tick.AggID)
+	}, Options{Backoff: NewBackoff(10*time.Millisecond, 10*time.Millisecond), QuarantinePanics: 3, QuarantineWindow: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The panic fails the connection, the next one carries on
+	if fmt.Sprint(ids) != "[3]" {
+		t.Errorf("expected the ticks of the reconnected connection, got %v", ids)
+	}
+	if stats := c.Connections(); stats[0].Panics != 1 {
+		t.Errorf("expected 1 panic, got %+v", stats)
+	}
+}
+
+func TestClient_Quarantine(t *testing.T) {
+	dialer := &seqDialer{conns: []*mockConn{newMockConn(aggTrades(1)), newMockConn(aggTrades(2)), newMockConn(aggTrades(3))}}
+
+	c := NewClient("BTCUSDT", dialer, func(tick Tick) {
+		panic("always")
+	}, Options{Backoff: NewBackoff(10*time.Millisecond, 10*time.Millisecond), QuarantinePanics: 2, QuarantineWindow: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := c.Run(ctx)
+	if !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("expected the client to be quarantined, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected Run to return before its context ended")
+	}
+	if dialer.dials != 2 {
+		t.Errorf("expected no reconnect after the quarantine, got %d dials", dialer.dials)
+	}
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultHandshakeTimeout bounds the TCP, proxy, TLS and WebSocket
+// handshakes of a connection.
+const DefaultHandshakeTimeout = 45 * time.Second
+
+// DialerOptions configures a DefaultDialer. The zero value connects
+// directly, or through the proxy named by HTTPS_PROXY and friends, trusting
+// the system's certificates.
+type DialerOptions struct {
+	Proxy            string        // http:// (CONNECT) or socks5:// proxy URL, credentials allowed
+	CAFile           string        // PEM certificates trusted in addition to the system's
+	HandshakeTimeout time.Duration // 0 takes DefaultHandshakeTimeout
+	Compression      bool          // negotiate permessage-deflate
+	Header           http.Header   // sent with every handshake
+}
+
+// DefaultDialer implements Dialer using gorilla/websocket. The zero value
+// uses gorilla's default dialer.
+type DefaultDialer struct {
+	dialer *websocket.Dialer
+	header http.Header
+}
+
+// NewDialer creates a dialer from opts.
+func NewDialer(opts DialerOptions) (*DefaultDialer, error) {
+	d := &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  opts.HandshakeTimeout,
+		EnableCompression: opts.Compression,
+	}
+	if d.HandshakeTimeout == 0 {
+		d.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "socks5" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http or socks5)", u.Scheme)
+		}
+		d.Proxy = http.ProxyURL(u)
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s: no PEM certificates found", opts.CAFile)
+		}
+		d.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &DefaultDialer{dialer: d, header: opts.Header.Clone()}, nil
+}
+
+func (d *DefaultDialer) Dial(ctx context.Context, url string) (Conn, error) {
+	dialer := d.dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.DialContext(ctx, url, d.header)
+	if err != nil {
+		return nil, err
+	}
+	return &connWrapper{Conn: conn, stop: context.AfterFunc(ctx, func() { conn.Close() })}, nil
+}
+
+type connWrapper struct {
+	*websocket.Conn
+	stop func() bool // cancels closing the connection when the dial context ends
+}
+
+func (c *connWrapper) ReadMessage() (int, []byte, error) {
+	return c.Conn.ReadMessage()
+}
+
+func (c *connWrapper) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
@@ -0,0 +1,226 @@
+package websocket
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newStreamServer serves one aggTrade message per connection and records
+// the last handshake request.
+func newStreamServer(t *testing.T, tls bool) (*httptest.Server, *atomic.Pointer[http.Request]) {
+	var last atomic.Pointer[http.Request]
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last.Store(r)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"a":1,"T":1700000000000,"p":"42000.00"}`))
+		conn.ReadMessage() // until the client closes
+	})
+
+	var srv *httptest.Server
+	if tls {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+	t.Cleanup(srv.Close)
+	return srv, &last
+}
+
+func readTick(t *testing.T, conn Conn) {
+	t.Helper()
+	defer conn.Close()
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := parseAggTrade("BTCUSDT", msg); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+}
+
+func TestDialer_CAFileAndHeaders(t *testing.T) {
+	srv, last := newStreamServer(t, true)
+	url := "wss" + strings.TrimPrefix(srv.URL, "https") + "/ws/btcusdt@aggTrade"
+
+	// The test server's certificate is not trusted by default
+	plain, err := NewDialer(DialerOptions{HandshakeTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Dial(context.Background(), url); err == nil {
+		t.Fatal("expected an untrusted certificate to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dialer, err := NewDialer(DialerOptions{
+		CAFile:      caFile,
+		Compression: true,
+		Header:      http.Header{"X-Api-Key": {"secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.Dial(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	readTick(t, conn)
+
+	r := last.Load()
+	if r.Header.Get("X-Api-Key") != "secret" {
+		t.Errorf("expected the custom header, got %v", r.Header)
+	}
+	if !strings.Contains(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("expected compression to be negotiated, got %v", r.Header)
+	}
+}
+
+func TestDialer_HTTPProxy(t *testing.T) {
+	srv, _ := newStreamServer(t, false)
+
+	var connects atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		connects.Add(1)
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		client, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, client)
+			upstream.Close()
+		}()
+		io.Copy(client, upstream)
+		client.Close()
+	}))
+	t.Cleanup(proxy.Close)
+
+	dialer, err := NewDialer(DialerOptions{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/btcusdt@aggTrade")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	readTick(t, conn)
+
+	if connects.Load() != 1 {
+		t.Errorf("expected the connection to go through the proxy, got %d CONNECTs", connects.Load())
+	}
+}
+
+func TestNewDialer_Errors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, nil, 0644)
+
+	for name, opts := range map[string]DialerOptions{
+		"proxy scheme": {Proxy: "ftp://proxy:21"},
+		"missing CA":   {CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"empty CA":     {CAFile: empty},
+	} {
+		if _, err := NewDialer(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// hangingDialer never completes a handshake.
+type hangingDialer struct{}
+
+func (hangingDialer) Dial(ctx context.Context, url string) (Conn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClient_RunReturnsWhenStopped(t *testing.T) {
+	client := NewClient("BTCUSDT", hangingDialer{}, func(tick Tick) {}, Options{Connections: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return once stopped during a handshake")
+	}
+}
+
+func TestDialer_Context(t *testing.T) {
+	// A server that accepts connections but never answers the handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	dialer, _ := NewDialer(DialerOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := dialer.Dial(ctx, "ws://"+ln.Addr().String()+"/ws"); err == nil {
+		t.Fatal("expected the handshake to be cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the dial to end with its context, took %s", elapsed)
+	}
+
+	// The connection is closed when the dial context ends
+	srv, _ := newStreamServer(t, false)
+	ctx, cancel = context.WithCancel(context.Background())
+	conn, err := dialer.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.ReadMessage()
+	cancel()
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("expected the read to fail once the context ended")
+	}
+}
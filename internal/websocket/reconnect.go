@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy computes how long a client waits before reconnecting.
+type Strategy interface {
+	// Delay returns the wait after failures consecutive failed attempts
+	// (1 for the first), given the previous wait and the current limits.
+	Delay(failures int, prev, min, max time.Duration) time.Duration
+}
+
+// Strategies are the built-in strategies by name. The jittered ones keep
+// clients that lost their connections together from reconnecting in
+// lockstep.
+var Strategies = map[string]Strategy{
+	"exponential":  exponential{},  // min doubled per failure up to max, no jitter
+	"full-jitter":  fullJitter{},   // anywhere up to the exponential delay
+	"equal-jitter": equalJitter{},  // half the exponential delay plus up to the other half
+	"decorrelated": decorrelated{}, // between min and three times the previous wait
+}
+
+// DefaultStrategy is the strategy of a Backoff created by NewBackoff.
+const DefaultStrategy = "equal-jitter"
+
+// StrategyNames lists the built-in strategies, sorted.
+func StrategyNames() []string {
+	names := make([]string, 0, len(Strategies))
+	for name := range Strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StrategyByName returns a built-in strategy.
+func StrategyByName(name string) (Strategy, error) {
+	s, ok := Strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reconnect strategy %q", name)
+	}
+	return s, nil
+}
+
+// exponentialDelay is min doubled per failure after the first, capped at
+// max.
+func exponentialDelay(failures int, min, max time.Duration) time.Duration {
+	d := min
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	return clamp(d, min, max)
+}
+
+// jitter returns a random duration in [0, d].
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+func clamp(d, min, max time.Duration) time.Duration {
+	if d > max {
+		d = max
+	}
+	if d < min {
+		d = min
+	}
+	return d
+}
+
+type exponential struct{}
+
+func (exponential) Delay(failures int, prev, min, max time.Duration) time.Duration {
+	return exponentialDelay(failures, min, max)
+}
+
+type fullJitter struct{}
+
+func (fullJitter) Delay(failures int, prev, min, max time.Duration) time.Duration {
+	return jitter(exponentialDelay(failures, min, max))
+}
+
+type equalJitter struct{}
+
+func (equalJitter) Delay(failures int, prev, min, max time.Duration) time.Duration {
+	d := exponentialDelay(failures, min, max)
+	return d/2 + jitter(d-d/2)
+}
+
+type decorrelated struct{}
+
+func (decorrelated) Delay(failures int, prev, min, max time.Duration) time.Duration {
+	if failures <= 1 || prev < min {
+		prev = min
+	}
+	return clamp(min+jitter(3*prev-min), min, max)
+}
+
+// Backoff is the reconnect policy shared by clients: the strategy and its
+// delay limits, and a circuit breaker that pauses a client for a while once
+// it failed a number of times in a row, then lets one attempt through per
+// pause until one succeeds. All of it can be changed while clients run and
+// applies from their next reconnect.
+type Backoff struct {
+	min, max        atomic.Int64
+	strategy        atomic.Value // *Strategy
+	breakerFailures atomic.Int64 // 0 disables the breaker
+	breakerPause    atomic.Int64
+}
+
+// NewBackoff creates a reconnect policy with DefaultStrategy and the
+// breaker disabled.
+func NewBackoff(min, max time.Duration) *Backoff {
+	b := &Backoff{}
+	b.Set(min, max)
+	b.SetStrategy(Strategies[DefaultStrategy])
+	return b
+}
+
+// Set changes the delay limits.
+func (b *Backoff) Set(min, max time.Duration) {
+	b.min.Store(int64(min))
+	b.max.Store(int64(max))
+}
+
+// Limits returns the current delay limits.
+func (b *Backoff) Limits() (min, max time.Duration) {
+	return time.Duration(b.min.Load()), time.Duration(b.max.Load())
+}
+
+// SetStrategy changes the strategy.
+func (b *Backoff) SetStrategy(s Strategy) {
+	b.strategy.Store(&s)
+}
+
+// SetBreaker opens the circuit breaker after failures consecutive failures,
+// pausing the client for pause; 0 failures disables it.
+func (b *Backoff) SetBreaker(failures int, pause time.Duration) {
+	b.breakerFailures.Store(int64(failures))
+	b.breakerPause.Store(int64(pause))
+}
+
+// next returns the wait after failures consecutive failures and whether
+// the breaker is open.
+func (b *Backoff) next(failures int, prev time.Duration) (time.Duration, bool) {
+	if n := b.breakerFailures.Load(); n > 0 && int64(failures) >= n {
+		return time.Duration(b.breakerPause.Load()), true
+	}
+	min, max := b.Limits()
+	s := Strategies[DefaultStrategy]
+	if p, ok := b.strategy.Load().(*Strategy); ok {
+		s = *p
+	}
+	return s.Delay(failures, prev, min, max), false
+}
+
+// ConnLimiter limits how many connections all clients sharing it open in a
+// sliding window, as Binance limits new connections per IP. The zero value
+// and nil do not limit.
+type ConnLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	opened []time.Time // oldest first, at most limit
+}
+
+// NewConnLimiter allows limit connections per window; 0 does not limit.
+func NewConnLimiter(limit int, window time.Duration) *ConnLimiter {
+	l := &ConnLimiter{}
+	l.Set(limit, window)
+	return l
+}
+
+// Set changes the limit.
+func (l *ConnLimiter) Set(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.window = limit, window
+	if limit > 0 && len(l.opened) > limit {
+		l.opened = l.opened[len(l.opened)-limit:]
+	}
+}
+
+// Wait blocks until another connection may be opened and counts it. It
+// returns ctx's error if ctx ends first.
+func (l *ConnLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		wait := l.reserve(time.Now())
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve counts a connection at now if the window allows it, or returns
+// how long until it does.
+func (l *ConnLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit <= 0 {
+		return 0
+	}
+	if len(l.opened) >= l.limit {
+		if wait := l.opened[0].Add(l.window).Sub(now); wait > 0 {
+			return wait
+		}
+		l.opened = l.opened[1:]
+	}
+	l.opened = append(l.opened, now)
+	return 0
+}
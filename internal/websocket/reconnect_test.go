@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	min, max := 100*time.Millisecond, 2*time.Second
+
+	for _, name := range StrategyNames() {
+		s, err := StrategyByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prev time.Duration
+		for failures := 1; failures <= 10; failures++ {
+			for i := 0; i < 50; i++ {
+				d := s.Delay(failures, prev, min, max)
+				upper := min << (failures - 1)
+				if name == "decorrelated" {
+					upper = max
+				}
+				upper = clamp(upper, min, max)
+				lower := time.Duration(0)
+				switch name {
+				case "exponential":
+					lower = upper
+				case "equal-jitter":
+					lower = upper / 2
+				case "decorrelated":
+					lower = min
+				}
+				if d < lower || d > upper {
+					t.Fatalf("%s: failure %d: delay %s outside [%s, %s]", name, failures, d, lower, upper)
+				}
+				prev = d
+			}
+		}
+	}
+
+	if _, err := StrategyByName("linear"); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
+
+func TestStrategies_Jitter(t *testing.T) {
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		seen[Strategies["equal-jitter"].Delay(3, 0, time.Second, time.Minute)] = true
+	}
+	if len(seen) < 2 {
+		t.Error("expected jittered delays to differ")
+	}
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	dialer := &countingDialer{err: errors.New("connection failed")}
+	backoff := NewBackoff(time.Millisecond, time.Millisecond)
+	backoff.SetBreaker(3, time.Hour)
+
+	client := NewClient("BTCUSDT", dialer, func(tick Tick) {}, Options{Backoff: backoff})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	client.Run(ctx)
+
+	if dialer.callCount != 3 {
+		t.Errorf("expected the breaker to pause after 3 attempts, got %d", dialer.callCount)
+	}
+}
+
+func TestConnLimiter(t *testing.T) {
+	l := NewConnLimiter(2, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if l.reserve(now) != 0 || l.reserve(now.Add(time.Second)) != 0 {
+		t.Fatal("expected the first 2 connections to be allowed")
+	}
+	if wait := l.reserve(now.Add(2 * time.Second)); wait != 58*time.Second {
+		t.Errorf("expected to wait until the first leaves the window, got %s", wait)
+	}
+	if wait := l.reserve(now.Add(time.Minute)); wait != 0 {
+		t.Errorf("expected a connection once the window moved, got wait %s", wait)
+	}
+
+	l.Set(0, 0)
+	if wait := l.reserve(now.Add(time.Minute)); wait != 0 {
+		t.Errorf("expected no limit, got wait %s", wait)
+	}
+
+	// Clients share the limiter
+	l = NewConnLimiter(1, time.Hour)
+	dialer := &countingDialer{err: errors.New("connection failed")}
+	opts := Options{Backoff: NewBackoff(time.Millisecond, time.Millisecond), Limiter: l}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		go func() {
+			NewClient(symbol, dialer, func(tick Tick) {}, opts).Run(ctx)
+			done <- struct{}{}
+		}()
+	}
+	<-done
+	<-done
+	if dialer.callCount != 1 {
+		t.Errorf("expected 1 connection across clients, got %d", dialer.callCount)
+	}
+}
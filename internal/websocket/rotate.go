@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// recentSize is how many aggregate trade ids a client remembers to drop
+// ticks that several connections deliver. It covers one connection lagging
+// another by seconds on the busiest symbols.
+const recentSize = 1 << 14
+
+// serve reads conn until it fails or ctx ends. Every RotateAfter it opens a
+// replacement, reads both for RotateOverlap and closes the old one, so the
+// stream has no gap when Binance drops connections at 24 hours. A failed
+// replacement keeps the current connection and is retried with Backoff.
+func (c *client) serve(ctx context.Context, f *feed, conn Conn) error {
+	errc := c.read(conn, f)
+	rotate := c.rotateAfter()
+
+	failures := 0 // replacements in a row that failed
+	var delay time.Duration
+	retry := func() {
+		failures++
+		delay, _ = c.opts.Backoff.next(failures, delay)
+		rotate = time.After(delay)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			<-errc
+			return nil
+
+		case err := <-errc:
+			conn.Close()
+			return fmt.Errorf("read: %w", err)
+
+		case <-rotate:
+			rotate = nil // until the replacement takes over
+			next, err := c.dial(ctx, f)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("websocket rotation failed, keeping the connection", "symbol", c.symbol, "conn", f.index, "error", err)
+				}
+				retry()
+				continue
+			}
+			nextErrc := c.read(next, f)
+			before := c.duplicateCount()
+
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				next.Close()
+				<-errc
+				<-nextErrc
+				return nil
+			case err := <-nextErrc:
+				next.Close()
+				slog.Warn("websocket rotation failed, keeping the connection", "symbol", c.symbol, "conn", f.index, "error", err)
+				retry()
+				continue
+			case <-errc: // the old connection closed first
+				conn.Close()
+			case <-time.After(c.opts.RotateOverlap):
+				conn.Close()
+				<-errc
+			}
+
+			conn, errc = next, nextErrc
+			rotate = c.rotateAfter()
+			failures, delay = 0, 0
+			slog.Info("websocket connection rotated", "symbol", c.symbol, "conn", f.index, "duplicates", c.duplicateCount()-before)
+		}
+	}
+}
+
+// rotateAfter returns when the current connection is due for replacement,
+// or nil if connections are not rotated.
+func (c *client) rotateAfter() <-chan time.Time {
+	if c.opts.RotateAfter <= 0 {
+		return nil
+	}
+	return time.After(c.opts.RotateAfter)
+}
+
+// deliver passes tick to the handler unless it was delivered already, by
+// another connection or by both sides of a rotation. Ticks without an
+// aggregate trade id are always passed on.
+func (c *client) deliver(tick Tick) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tick.AggID != 0 && !c.recent.add(tick.AggID) {
+		c.duplicates++
+		return
+	}
+	c.feeds[tick.Conn].first++
+	c.handler(tick)
+}
+
+func (c *client) duplicateCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duplicates
+}
+
+// recentIDs is a set of the last ids added to it.
+type recentIDs struct {
+	ids  map[int64]struct{}
+	ring []int64 // in order added; the oldest is overwritten
+	next int
+}
+
+func newRecentIDs(size int) recentIDs {
+	return recentIDs{ids: make(map[int64]struct{}, size), ring: make([]int64, 0, size)}
+}
+
+// add adds id and reports whether it was not in the set.
+func (r *recentIDs) add(id int64) bool {
+	if _, ok := r.ids[id]; ok {
+		return false
+	}
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, id)
+	} else {
+		delete(r.ids, r.ring[r.next])
+		r.ring[r.next] = id
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.ids[id] = struct{}{}
+	return true
+}
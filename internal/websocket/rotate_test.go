@@ -0,0 +1,160 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// seqDialer hands out its connections in turn, then fails. A nil
+// connection fails that dial.
+type seqDialer struct {
+	mu    sync.Mutex
+	conns []*mockConn
+	dials int
+}
+
+func (d *seqDialer) Dial(ctx context.Context, url string) (Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	if len(d.conns) == 0 {
+		return nil, errors.New("no more connections")
+	}
+	conn := d.conns[0]
+	d.conns = d.conns[1:]
+	if conn == nil {
+		return nil, errors.New("dial failed")
+	}
+	return conn, nil
+}
+
+func aggTrades(ids ...int64) [][]byte {
+	var msgs [][]byte
+	for _, id := range ids {
+		msgs = append(msgs, []byte(fmt.Sprintf(`{"a":%d,"T":1700000000000,"p":"42000.00"}`, id)))
+	}
+	return msgs
+}
+
+func TestClient_RotatesWithoutGaps(t *testing.T) {
+	old, replacement := newMockConn(aggTrades(1, 2, 3)), newMockConn(aggTrades(2, 3, 4))
+	dialer := &seqDialer{conns: []*mockConn{old, replacement}}
+
+	var ids []int64
+	c := NewClient("BTCUSDT", dialer, func(tick Tick) {
+		ids = append(ids, tick.AggID)
+	}, Options{RotateAfter: 20 * time.Millisecond, RotateOverlap: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c.Run(ctx)
+
+	if fmt.Sprint(ids) != "[1 2 3 4]" {
+		t.Errorf("expected each tick once, got %v", ids)
+	}
+	select {
+	case <-old.closeCh:
+	default:
+		t.Error("expected the old connection to be closed")
+	}
+	if dialer.dials < 3 {
+		t.Errorf("expected the replacement to be rotated in turn, got %d dials", dialer.dials)
+	}
+	if n := c.(*client).duplicateCount(); n != 2 {
+		t.Errorf("expected 2 duplicates dropped, got %d", n)
+	}
+}
+
+func TestClient_RetriesFailedRotation(t *testing.T) {
+	old, replacement := newMockConn(aggTrades(1, 2)), newMockConn(aggTrades(2, 3))
+	dialer := &seqDialer{conns: []*mockConn{old, nil, replacement}}
+
+	var ids []int64
+	c := NewClient("BTCUSDT", dialer, func(tick Tick) {
+		ids = append(ids, tick.AggID)
+	}, Options{
+		Backoff:       NewBackoff(10*time.Millisecond, 10*time.Millisecond),
+		RotateAfter:   20 * time.Millisecond,
+		RotateOverlap: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	c.Run(ctx)
+
+	// The first replacement fails to dial, the retry takes over
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("expected the retried replacement's ticks, got %v", ids)
+	}
+	select {
+	case <-old.closeCh:
+	default:
+		t.Error("expected the old connection to be replaced")
+	}
+}
+
+func TestRecentIDs(t *testing.T) {
+	r := newRecentIDs(2)
+	for _, id := range []int64{1, 2} {
+		if !r.add(id) {
+			t.Fatalf("expected %d to be new", id)
+		}
+	}
+	if r.add(1) {
+		t.Error("expected 1 to be seen")
+	}
+	r.add(3) // evicts 1
+	if !r.add(1) || r.add(3) {
+		t.Error("expected only the last ids to be remembered")
+	}
+}
+
+func TestClient_RedundantConnections(t *testing.T) {
+	dialer := &seqDialer{conns: []*mockConn{
+		newMockConn(aggTrades(1, 2, 3)),
+		newMockConn(aggTrades(2, 3, 4)),
+	}}
+
+	var mu sync.Mutex
+	seen := make(map[int64]int)
+	c := NewClient("BTCUSDT", dialer, func(tick Tick) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[tick.AggID]++
+	}, Options{
+		URL:         "ws://primary/ws",
+		StandbyURLs: []string{"ws://standby/ws"},
+		Connections: 2,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go c.Run(ctx)
+	time.Sleep(30 * time.Millisecond)
+
+	stats := c.Connections()
+	if len(stats) != 2 || stats[0].URL != "ws://primary/ws" || stats[1].URL != "ws://standby/ws" {
+		t.Fatalf("unexpected connections %+v", stats)
+	}
+	if !stats[0].Connected || !stats[1].Connected {
+		t.Errorf("expected both connections up, got %+v", stats)
+	}
+	if first := stats[0].First + stats[1].First; first != 4 {
+		t.Errorf("expected 4 ticks delivered first, got %d", first)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 4 {
+		t.Errorf("expected 4 distinct ticks, got %v", seen)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("tick %d passed on %d times", id, n)
+		}
+	}
+}